@@ -3,24 +3,41 @@ package roselite
 import (
 	"context"
 	"crypto/tls"
-	"fmt"
 	"io"
-	"net"
-	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/getsentry/sentry-go"
-	"github.com/teknologi-umum/roselite/internal/sentryhttpclient"
 )
 
 type Agent struct {
-	wg                     *sync.WaitGroup
-	shutdownCtx            context.Context
-	shutdownCancel         context.CancelFunc
-	httpClient             *http.Client
-	upstreamRequestHeaders map[string]string
-	upstreamKumaAddress    string
+	wg             *sync.WaitGroup
+	shutdownCtx    context.Context
+	shutdownCancel context.CancelFunc
+	checksCtx      context.Context
+	checksCancel   context.CancelFunc
+	upstream       atomic.Pointer[upstreamClient]
+	region         string
+	identifier     string
+	monitorStore   *MonitorStore
+	maintenance    *Maintenance
+	startedAt      time.Time
+	version        string
+	// lastUpstreamError is the last failure to push a heartbeat to the upstream instance, reported to the server the
+	// agent is registered with.
+	lastUpstreamError atomic.Pointer[upstreamError]
+	// upstreamPushes and upstreamPushFailures count the heartbeats pushed to the upstream instance, for the self
+	// monitor.
+	upstreamPushes       atomic.Int64
+	upstreamPushFailures atomic.Int64
+
+	// mutex guards the monitors, the schedules, and the shutdown of the agent so no schedule is started once it is
+	// shut down.
+	mutex              sync.Mutex
+	monitors           []Monitor
+	discoveredMonitors map[string][]Monitor
+	schedules          map[string]*monitorSchedule
 }
 
 type AgentOptions struct {
@@ -29,110 +46,107 @@ type AgentOptions struct {
 	UpstreamRequestHeaders map[string]string
 	UpstreamTLSConfig      *tls.Config
 	RegionIdentifier       string
+	// AgentIdentifier identifies this agent to the relays and the upstream instance. Defaults to the hostname.
+	AgentIdentifier string
+	// MonitorStore keeps the last heartbeats of every monitor. Defaults to a new store.
+	MonitorStore *MonitorStore
+	// Version is the version of roselite, reported to the server the agent is registered with.
+	Version string
+	// Maintenance holds the maintenance windows and the silences the checks are subject to. Share the same value with
+	// a Server for its silences to apply to the agent too. Defaults to no maintenance.
+	Maintenance *Maintenance
 }
 
 var _ io.Closer = (*Agent)(nil)
 
 func NewAgent(options AgentOptions) *Agent {
-	httpClientTransport := &http.Transport{
-		// Adapted from http.DefaultTransport
-		Proxy: http.ProxyFromEnvironment,
-		DialContext: (&net.Dialer{
-			Timeout:   30 * time.Second,
-			KeepAlive: 30 * time.Second,
-		}).DialContext,
-		ForceAttemptHTTP2:     true,
-		MaxIdleConns:          100,
-		IdleConnTimeout:       90 * time.Second,
-		TLSHandshakeTimeout:   10 * time.Second,
-		ExpectContinueTimeout: 1 * time.Second,
-		TLSClientConfig:       options.UpstreamTLSConfig,
-	}
-
-	httpClient := &http.Client{
-		Transport: sentryhttpclient.NewSentryRoundTripper(httpClientTransport),
-		Timeout:   time.Minute * 3,
-	}
-
 	wg := new(sync.WaitGroup)
 	ctx, cancel := context.WithCancel(sentry.SetHubOnContext(context.Background(), sentry.CurrentHub()))
+	// In-progress checks are only cancelled once the grace period of a shutdown is over, hence a separate context.
+	checksCtx, checksCancel := context.WithCancel(sentry.SetHubOnContext(context.Background(), sentry.CurrentHub()))
 
-	upstreamRequestHeaders := make(map[string]string)
-	if options.UpstreamRequestHeaders != nil {
-		for key, value := range options.UpstreamRequestHeaders {
-			upstreamRequestHeaders[key] = value
-		}
-	}
 	region := options.RegionIdentifier
 	if region == "" {
 		region = "default"
 	}
 
-	upstreamRequestHeaders["X-Roselite-Region"] = region
-	a := &Agent{
-		upstreamKumaAddress:    options.UpstreamKumaAddress,
-		upstreamRequestHeaders: upstreamRequestHeaders,
-		httpClient:             httpClient,
-		wg:                     wg,
-		shutdownCtx:            ctx,
-		shutdownCancel:         cancel,
+	agentIdentifier := options.AgentIdentifier
+	if agentIdentifier == "" {
+		agentIdentifier = defaultInstanceIdentifier()
+	}
+
+	monitorStore := options.MonitorStore
+	if monitorStore == nil {
+		monitorStore = NewMonitorStore(0)
+	}
+
+	maintenance := options.Maintenance
+	if maintenance == nil {
+		maintenance = NewMaintenance(nil)
 	}
 
-	for _, monitor := range options.Monitors {
-		wg.Add(1)
-		go func(monitor Monitor) {
-			defer wg.Done()
-			select {
-			case <-a.shutdownCtx.Done():
-				return
-			case <-time.After(monitor.Interval):
-				ctx, cancel := context.WithTimeout(a.shutdownCtx, time.Minute*5)
-				span := sentry.StartSpan(ctx, "function", sentry.WithDescription("Agent.Start.monitor.loop"))
-				span.SetData("roselite.monitor.id", monitor.ID)
-				span.SetData("roselite.monitor.type", monitor.MonitorType.String())
-
-				var heartbeat Heartbeat
-				var err error
-				switch monitor.MonitorType {
-				case MonitorTypeHTTP:
-					httpCaller := &HttpCaller{}
-					heartbeat, err = httpCaller.Call(ctx, monitor)
-					break
-				case MonitorTypeICMP:
-					icmpCaller := &IcmpCaller{Privileged: false}
-					heartbeat, err = icmpCaller.Call(ctx, monitor)
-					break
-				default:
-					err = fmt.Errorf("unknown monitor type: %s", monitor.MonitorType.String())
-					break
-				}
-
-				// Although it may be an error, the Heartbeat struct must not be empty, we must still send it to
-				// the upstream instance.
-				if err != nil {
-					sentry.GetHubFromContext(ctx).CaptureException(err)
-				}
-
-				err = callKumaEndpoint(ctx, a.upstreamKumaAddress, a.upstreamRequestHeaders, a.httpClient, monitor.ID, heartbeat)
-				if err != nil {
-					sentry.GetHubFromContext(ctx).CaptureException(err)
-				}
-				span.Finish()
-				cancel()
-			}
-		}(monitor)
+	a := &Agent{
+		wg:                 wg,
+		shutdownCtx:        ctx,
+		shutdownCancel:     cancel,
+		checksCtx:          checksCtx,
+		checksCancel:       checksCancel,
+		region:             region,
+		identifier:         agentIdentifier,
+		monitorStore:       monitorStore,
+		maintenance:        maintenance,
+		startedAt:          time.Now(),
+		version:            options.Version,
+		schedules:          make(map[string]*monitorSchedule),
+		discoveredMonitors: make(map[string][]Monitor),
 	}
 
+	a.SetUpstream(options.UpstreamKumaAddress, options.UpstreamRequestHeaders, options.UpstreamTLSConfig)
+	a.UpdateMonitors(options.Monitors)
+
 	return a
 }
 
+// Start blocks until the agent is shut down and every check is completed.
 func (a *Agent) Start() error {
+	<-a.shutdownCtx.Done()
 	a.wg.Wait()
 	return nil
 }
 
+// Close stops the agent immediately, cancelling every in-progress check.
 func (a *Agent) Close() error {
-	a.shutdownCancel()
+	a.stopScheduling()
+	a.checksCancel()
 
 	return nil
 }
+
+// Shutdown stops scheduling new checks, then waits for the in-progress checks to complete. If the context is done
+// before that, the remaining checks are cancelled and the context's error is returned.
+func (a *Agent) Shutdown(ctx context.Context) error {
+	a.stopScheduling()
+
+	done := make(chan struct{})
+	go func() {
+		a.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		a.checksCancel()
+		return nil
+	case <-ctx.Done():
+		a.checksCancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (a *Agent) stopScheduling() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.shutdownCancel()
+}
@@ -0,0 +1,151 @@
+package roselite
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// defaultRegistrationInterval is how often an agent sends its self-heartbeat if RegistrationOptions.Interval is not
+// set.
+const defaultRegistrationInterval = time.Second * 30
+
+// upstreamError is a failure to push a heartbeat to the upstream instance.
+type upstreamError struct {
+	message string
+	at      time.Time
+}
+
+// AgentHeartbeat is the self-heartbeat an agent sends to the server it is registered with.
+type AgentHeartbeat struct {
+	Region    string    `json:"region"`
+	Version   string    `json:"version,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+	// IntervalSeconds is how often the agent sends its self-heartbeat, the server considers it silent after missing a
+	// few of them.
+	IntervalSeconds float64 `json:"interval_seconds"`
+	// Monitors is the amount of monitors the agent is checking.
+	Monitors int `json:"monitors"`
+	// SchedulerLagSeconds is the longest delay of a check past its scheduled time.
+	SchedulerLagSeconds float64    `json:"scheduler_lag_seconds"`
+	LastUpstreamError   string     `json:"last_upstream_error,omitempty"`
+	LastUpstreamErrorAt *time.Time `json:"last_upstream_error_at,omitempty"`
+}
+
+// RegistrationOptions configures the registration of an agent with a roselite server.
+type RegistrationOptions struct {
+	// ServerAddress is the base URL of the roselite server.
+	ServerAddress string
+	// Token authenticates the agent to the server.
+	Token     string
+	TLSConfig *tls.Config
+	// Interval is how often the self-heartbeat is sent. Defaults to 30 seconds.
+	Interval time.Duration
+}
+
+// RunRegistration registers the agent with a roselite server, then sends its self-heartbeat on every interval until
+// the context is done or the agent is shut down. The server tracks the agent on its fleet, and reports it once the
+// self-heartbeats stop. Failures are logged, and retried on the next interval.
+func (a *Agent) RunRegistration(ctx context.Context, options RegistrationOptions) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(a.shutdownCtx, cancel)
+	defer stop()
+
+	interval := options.Interval
+	if interval <= 0 {
+		interval = defaultRegistrationInterval
+	}
+
+	endpoint, err := url.JoinPath(options.ServerAddress, "/api/agents", url.PathEscape(a.identifier), "heartbeat")
+	if err != nil {
+		slog.Warn("invalid registration server address", slog.String("error", err.Error()))
+		return
+	}
+
+	transport := &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: options.TLSConfig}
+	defer transport.CloseIdleConnections()
+	httpClient := &http.Client{Transport: transport, Timeout: interval}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	failing := false
+	for {
+		err := a.sendAgentHeartbeat(ctx, httpClient, endpoint, options.Token, interval)
+		switch {
+		case ctx.Err() != nil:
+			return
+		case err != nil && !failing:
+			slog.Warn("failed to send the agent heartbeat, retrying on every interval",
+				slog.String("server", options.ServerAddress),
+				slog.String("error", err.Error()))
+		case err == nil && failing:
+			slog.Info("agent heartbeat sent again", slog.String("server", options.ServerAddress))
+		}
+		failing = err != nil
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// AgentHeartbeat returns the current self-heartbeat of the agent.
+func (a *Agent) AgentHeartbeat(interval time.Duration) AgentHeartbeat {
+	status := a.SchedulerStatus()
+	heartbeat := AgentHeartbeat{
+		Region:              a.region,
+		Version:             a.version,
+		StartedAt:           a.startedAt,
+		IntervalSeconds:     interval.Seconds(),
+		Monitors:            status.Monitors,
+		SchedulerLagSeconds: max(status.Lag, 0).Seconds(),
+	}
+
+	if lastUpstreamError := a.lastUpstreamError.Load(); lastUpstreamError != nil {
+		heartbeat.LastUpstreamError = lastUpstreamError.message
+		heartbeat.LastUpstreamErrorAt = &lastUpstreamError.at
+	}
+
+	return heartbeat
+}
+
+func (a *Agent) sendAgentHeartbeat(ctx context.Context, httpClient *http.Client, endpoint string, token string, interval time.Duration) error {
+	body, err := json.Marshal(a.AgentHeartbeat(interval))
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = response.Body.Close() }()
+
+	if response.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return fmt.Errorf("server responded with status %d: %s", response.StatusCode, strings.TrimSpace(string(message)))
+	}
+
+	return nil
+}
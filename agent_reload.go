@@ -0,0 +1,163 @@
+package roselite
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+)
+
+// SetUpstream replaces the upstream instance and its settings. Checks that are already in progress still push their
+// heartbeat with the previous settings.
+func (a *Agent) SetUpstream(upstreamKumaAddress string, upstreamRequestHeaders map[string]string, upstreamTLSConfig *tls.Config) {
+	upstream := newUpstreamClient(upstreamKumaAddress, upstreamRequestHeaders, upstreamTLSConfig)
+	upstream.requestHeaders[regionHeader] = a.region
+	upstream.requestHeaders[agentHeader] = a.identifier
+
+	previous := a.upstream.Swap(upstream)
+	if previous != nil {
+		previous.close()
+	}
+}
+
+// UpdateMonitors replaces the set of monitors being checked, besides the discovered ones, matching them by ID. New
+// monitors are scheduled, removed monitors are stopped, and changed monitors are restarted. Monitors that did not
+// change keep their schedule. Checks that are already in progress are allowed to complete.
+func (a *Agent) UpdateMonitors(monitors []Monitor) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.monitors = monitors
+	a.applyMonitors()
+}
+
+// UpdateDiscoveredMonitors replaces the monitors found on a discovery source, the same way UpdateMonitors does.
+// Monitors that cannot be checked are skipped.
+func (a *Agent) UpdateDiscoveredMonitors(source string, monitors []Monitor) {
+	valid := make([]Monitor, 0, len(monitors))
+	for _, monitor := range monitors {
+		if err := validateDiscoveredMonitor(monitor); err != nil {
+			slog.Warn("skipping discovered monitor",
+				slog.String("source", source),
+				slog.String("monitor_id", monitor.ID),
+				slog.String("error", err.Error()))
+			continue
+		}
+
+		valid = append(valid, monitor)
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.discoveredMonitors[source] = valid
+	a.applyMonitors()
+}
+
+// applyMonitors schedules the monitors given to UpdateMonitors, then the discovered ones by source name. A monitor
+// ID that is already taken is skipped. The caller must hold the mutex.
+func (a *Agent) applyMonitors() {
+	if a.shutdownCtx.Err() != nil {
+		return
+	}
+
+	now := time.Now()
+	ids := make(map[string]string, len(a.monitors))
+	apply := func(source string, monitor Monitor) {
+		if taken, ok := ids[monitor.ID]; ok {
+			slog.Warn("skipping monitor with a duplicate id",
+				slog.String("source", source),
+				slog.String("monitor_id", monitor.ID),
+				slog.String("taken_by", taken))
+			return
+		}
+		ids[monitor.ID] = source
+
+		schedule, ok := a.schedules[monitor.ID]
+		if ok {
+			if monitorEqual(schedule.monitor, monitor) {
+				return
+			}
+
+			schedule.stop()
+		}
+
+		a.startSchedule(monitor, now)
+	}
+
+	for _, monitor := range a.monitors {
+		apply("configuration", monitor)
+	}
+	for _, source := range slices.Sorted(maps.Keys(a.discoveredMonitors)) {
+		for _, monitor := range a.discoveredMonitors[source] {
+			apply(source, monitor)
+		}
+	}
+
+	for id, schedule := range a.schedules {
+		if _, ok := ids[id]; ok {
+			continue
+		}
+
+		schedule.stop()
+		delete(a.schedules, id)
+	}
+}
+
+// startSchedule registers the monitor and starts its scheduling loop. The caller must hold the mutex.
+func (a *Agent) startSchedule(monitor Monitor, now time.Time) {
+	region := a.region
+	if monitor.Region != "" {
+		region = monitor.Region
+	}
+	a.monitorStore.RegisterMonitor(monitor, region, a.identifier)
+
+	ctx, cancel := context.WithCancel(a.shutdownCtx)
+	schedule := &monitorSchedule{
+		monitor:     monitor,
+		ctx:         ctx,
+		stop:        cancel,
+		nextCheckAt: now.Add(monitor.Interval),
+	}
+	a.schedules[monitor.ID] = schedule
+
+	a.wg.Add(1)
+	go a.runSchedule(schedule)
+}
+
+// monitorEqual reports whether both monitors would be checked the same way.
+func monitorEqual(a Monitor, b Monitor) bool {
+	return a.ID == b.ID &&
+		a.MonitorType == b.MonitorType &&
+		a.PushURL == b.PushURL &&
+		a.MonitorTarget == b.MonitorTarget &&
+		maps.Equal(a.RequestHeaders, b.RequestHeaders) &&
+		tlsConfigEqual(a.TLSConfig, b.TLSConfig) &&
+		a.Interval == b.Interval &&
+		a.Timeout == b.Timeout &&
+		a.Region == b.Region &&
+		a.EnableSentrySampling == b.EnableSentrySampling &&
+		slices.Equal(a.Tags, b.Tags)
+}
+
+// tlsConfigEqual compares the parts of a TLS config that are set from the configuration file.
+func tlsConfigEqual(a *tls.Config, b *tls.Config) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	if a.InsecureSkipVerify != b.InsecureSkipVerify || a.ServerName != b.ServerName {
+		return false
+	}
+
+	if (a.RootCAs == nil) != (b.RootCAs == nil) || (a.RootCAs != nil && !a.RootCAs.Equal(b.RootCAs)) {
+		return false
+	}
+
+	return slices.EqualFunc(a.Certificates, b.Certificates, func(x tls.Certificate, y tls.Certificate) bool {
+		return slices.EqualFunc(x.Certificate, y.Certificate, bytes.Equal)
+	})
+}
@@ -0,0 +1,193 @@
+package roselite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+)
+
+const (
+	// schedulerLagTolerance is how late a check can be, past its scheduled time or its timeout, before the
+	// scheduler is considered unhealthy.
+	schedulerLagTolerance = time.Second * 30
+)
+
+// monitorSchedule tracks the scheduling loop of a single monitor.
+type monitorSchedule struct {
+	monitor Monitor
+	// ctx is done once the monitor is stopped, either by a configuration reload or by the agent shutting down.
+	ctx  context.Context
+	stop context.CancelFunc
+
+	mutex          sync.Mutex
+	nextCheckAt    time.Time
+	checkStartedAt time.Time
+}
+
+func (m *monitorSchedule) begin(now time.Time) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.checkStartedAt = now
+}
+
+func (m *monitorSchedule) finish(now time.Time) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.checkStartedAt = time.Time{}
+	m.nextCheckAt = now.Add(m.monitor.Interval)
+}
+
+// runSchedule checks the monitor on every interval until the monitor is stopped. A monitor that is no longer
+// configured is unregistered from the store once its last check is completed.
+func (a *Agent) runSchedule(schedule *monitorSchedule) {
+	defer a.wg.Done()
+	defer func() {
+		a.mutex.Lock()
+		defer a.mutex.Unlock()
+
+		if _, ok := a.schedules[schedule.monitor.ID]; !ok {
+			a.monitorStore.UnregisterMonitor(schedule.monitor.ID)
+		}
+	}()
+
+	ctx := sentry.SetHubOnContext(a.checksCtx, sentry.CurrentHub().Clone())
+	timer := time.NewTimer(schedule.monitor.Interval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-schedule.ctx.Done():
+			return
+		case <-timer.C:
+			schedule.begin(time.Now())
+			a.check(ctx, schedule.monitor)
+			schedule.finish(time.Now())
+			timer.Reset(schedule.monitor.Interval)
+		}
+	}
+}
+
+// check calls the monitor target, then pushes the resulting heartbeat to the upstream instance. A monitor under
+// maintenance is either skipped, or pushed with the maintenance status.
+func (a *Agent) check(ctx context.Context, monitor Monitor) {
+	period, underMaintenance := a.maintenance.Period(monitor.ID, monitor.Tags, time.Now())
+	if underMaintenance && period.Mode == MaintenanceModeSkip {
+		return
+	}
+
+	span := sentry.StartSpan(ctx, "function", sentry.WithDescription("Agent.Start.monitor.loop"))
+	span.SetData("roselite.monitor.id", monitor.ID)
+	span.SetData("roselite.monitor.type", monitor.MonitorType.String())
+	defer span.Finish()
+	ctx = span.Context()
+
+	var heartbeat Heartbeat
+	caller, err := CallerFor(monitor.MonitorType)
+	if err == nil {
+		heartbeat, err = caller.Call(ctx, monitor)
+	} else {
+		heartbeat = Heartbeat{Status: HeartbeatStatusDown}
+	}
+
+	// Although it may be an error, the Heartbeat struct must not be empty, we must still send it to
+	// the upstream instance.
+	if err != nil {
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+	}
+
+	if underMaintenance {
+		heartbeat = period.apply(heartbeat)
+	}
+
+	upstream := a.upstream.Load()
+	requestHeaders := upstream.requestHeaders
+	if monitor.Region != "" && monitor.Region != requestHeaders[regionHeader] {
+		requestHeaders = maps.Clone(requestHeaders)
+		requestHeaders[regionHeader] = monitor.Region
+	}
+	upstreamErr := callKumaEndpoint(ctx, upstream.address, requestHeaders, upstream.httpClient, monitor.ID, heartbeat)
+	a.upstreamPushes.Add(1)
+	if upstreamErr != nil {
+		sentry.GetHubFromContext(ctx).CaptureException(upstreamErr)
+		a.upstreamPushFailures.Add(1)
+		a.lastUpstreamError.Store(&upstreamError{message: upstreamErr.Error(), at: time.Now()})
+	}
+	a.monitorStore.Record(monitor.ID, "", "", heartbeat, errors.Join(err, upstreamErr))
+}
+
+// SchedulerStatus describes the health of the agent's scheduler.
+type SchedulerStatus struct {
+	// Monitors is the amount of monitors being scheduled.
+	Monitors int
+	// Lag is the longest delay of a check past its scheduled time.
+	Lag time.Duration
+	// LaggingMonitors are the monitors whose check is late by more than the tolerance.
+	LaggingMonitors []string
+	// StuckMonitors are the monitors whose check is running longer than its timeout.
+	StuckMonitors []string
+}
+
+// SchedulerStatus returns the current health of the scheduler.
+func (a *Agent) SchedulerStatus() SchedulerStatus {
+	a.mutex.Lock()
+	schedules := slices.SortedFunc(maps.Values(a.schedules), func(x *monitorSchedule, y *monitorSchedule) int {
+		return strings.Compare(x.monitor.ID, y.monitor.ID)
+	})
+	a.mutex.Unlock()
+
+	now := time.Now()
+	status := SchedulerStatus{Monitors: len(schedules)}
+	for _, schedule := range schedules {
+		schedule.mutex.Lock()
+		nextCheckAt, checkStartedAt := schedule.nextCheckAt, schedule.checkStartedAt
+		schedule.mutex.Unlock()
+
+		if !checkStartedAt.IsZero() {
+			if now.Sub(checkStartedAt) > schedule.monitor.CheckTimeout()+upstreamPushTimeout+schedulerLagTolerance {
+				status.StuckMonitors = append(status.StuckMonitors, schedule.monitor.ID)
+			}
+			continue
+		}
+
+		lag := now.Sub(nextCheckAt)
+		status.Lag = max(status.Lag, lag)
+		if lag > schedulerLagTolerance {
+			status.LaggingMonitors = append(status.LaggingMonitors, schedule.monitor.ID)
+		}
+	}
+
+	return status
+}
+
+// CheckScheduler reports whether the scheduler is ticking. It is meant to be used as a HealthCheck. The monitor IDs are
+// push tokens, so the error only counts the monitors, and the IDs are logged.
+func (a *Agent) CheckScheduler(context.Context) (string, error) {
+	if a.shutdownCtx.Err() != nil {
+		return "", errors.New("agent is shut down")
+	}
+
+	status := a.SchedulerStatus()
+	if len(status.StuckMonitors) > 0 {
+		slog.Warn("checks are stuck", slog.String("monitor_ids", strings.Join(status.StuckMonitors, ", ")))
+		return "", fmt.Errorf("checks are stuck for %d monitors", len(status.StuckMonitors))
+	}
+
+	if len(status.LaggingMonitors) > 0 {
+		slog.Warn("scheduler is lagging",
+			slog.Duration("lag", status.Lag.Round(time.Second)),
+			slog.String("monitor_ids", strings.Join(status.LaggingMonitors, ", ")))
+		return "", fmt.Errorf("scheduler is lagging by %s for %d monitors", status.Lag.Round(time.Second), len(status.LaggingMonitors))
+	}
+
+	return fmt.Sprintf("%d monitors are scheduled", status.Monitors), nil
+}
@@ -0,0 +1,439 @@
+package roselite_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/teknologi-umum/roselite"
+)
+
+func TestAgent(t *testing.T) {
+	var targetCalls atomic.Int64
+	targetServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		targetCalls.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(targetServer.Close)
+
+	upstreamHeaders := make(chan http.Header, 10)
+	kumaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case upstreamHeaders <- r.Header.Clone():
+		default:
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(kumaServer.Close)
+
+	monitorStore := roselite.NewMonitorStore(10)
+	agent := roselite.NewAgent(roselite.AgentOptions{
+		Monitors: []roselite.Monitor{
+			{
+				ID:            "target",
+				MonitorType:   roselite.MonitorTypeHTTP,
+				MonitorTarget: targetServer.URL,
+				Interval:      time.Millisecond * 50,
+			},
+		},
+		UpstreamKumaAddress: kumaServer.URL,
+		RegionIdentifier:    "ap-southeast-1",
+		AgentIdentifier:     "agent-1",
+		MonitorStore:        monitorStore,
+	})
+
+	done := make(chan struct{})
+	go func() {
+		_ = agent.Start()
+		close(done)
+	}()
+
+	time.Sleep(time.Millisecond * 300)
+
+	if targetCalls.Load() < 2 {
+		t.Errorf("expected the target to be checked repeatedly, got %d checks", targetCalls.Load())
+	}
+
+	headers := <-upstreamHeaders
+	if headers.Get("X-Roselite-Region") != "ap-southeast-1" || headers.Get("X-Roselite-Agent") != "agent-1" {
+		t.Errorf("unexpected upstream headers: %v", headers)
+	}
+
+	summary, heartbeats, ok := monitorStore.Monitor("target", 0)
+	if !ok {
+		t.Fatalf("expected monitor to be registered")
+	}
+	if summary.Region != "ap-southeast-1" || len(heartbeats) < 2 {
+		t.Errorf("unexpected monitor state: %+v with %d heartbeats", summary, len(heartbeats))
+	}
+
+	if message, err := agent.CheckScheduler(t.Context()); err != nil {
+		t.Errorf("expected scheduler to be healthy, got %v", err)
+	} else if message == "" {
+		t.Errorf("expected scheduler message to be set")
+	}
+
+	if err := agent.Close(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatalf("expected agent to stop after being closed")
+	}
+
+	if _, err := agent.CheckScheduler(t.Context()); err == nil {
+		t.Errorf("expected scheduler to be unhealthy after the agent is closed")
+	}
+}
+
+func TestAgent_Shutdown(t *testing.T) {
+	testCases := []struct {
+		name           string
+		gracePeriod    time.Duration
+		expectError    bool
+		expectedStatus roselite.HeartbeatStatus
+	}{
+		{
+			name:           "Check completes within the grace period",
+			gracePeriod:    time.Second * 5,
+			expectError:    false,
+			expectedStatus: roselite.HeartbeatStatusUp,
+		},
+		{
+			name:           "Check is cancelled after the grace period",
+			gracePeriod:    time.Millisecond * 50,
+			expectError:    true,
+			expectedStatus: roselite.HeartbeatStatusDown,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			checkStarted := make(chan struct{}, 1)
+			targetServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case checkStarted <- struct{}{}:
+				default:
+				}
+
+				select {
+				case <-r.Context().Done():
+				case <-time.After(time.Millisecond * 500):
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			t.Cleanup(targetServer.Close)
+
+			kumaServer := KumaServer()
+			t.Cleanup(kumaServer.Close)
+
+			monitorStore := roselite.NewMonitorStore(10)
+			agent := roselite.NewAgent(roselite.AgentOptions{
+				Monitors: []roselite.Monitor{
+					{
+						ID:            "slow-target",
+						MonitorType:   roselite.MonitorTypeHTTP,
+						MonitorTarget: targetServer.URL,
+						Interval:      time.Millisecond * 10,
+						Timeout:       time.Second * 5,
+					},
+				},
+				UpstreamKumaAddress: kumaServer.URL,
+				MonitorStore:        monitorStore,
+			})
+			go func() {
+				_ = agent.Start()
+			}()
+
+			select {
+			case <-checkStarted:
+			case <-time.After(time.Second * 5):
+				t.Fatalf("expected a check to be started")
+			}
+
+			ctx, cancel := context.WithTimeout(t.Context(), testCase.gracePeriod)
+			defer cancel()
+
+			err := agent.Shutdown(ctx)
+			if testCase.expectError != (err != nil) {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			_, heartbeats, _ := monitorStore.Monitor("slow-target", 0)
+			if len(heartbeats) != 1 {
+				t.Fatalf("expected exactly 1 heartbeat, got %d", len(heartbeats))
+			}
+			if heartbeats[0].Status != testCase.expectedStatus {
+				t.Errorf("expected status %s, got %s", testCase.expectedStatus, heartbeats[0].Status)
+			}
+		})
+	}
+}
+
+func TestAgent_UpdateMonitors(t *testing.T) {
+	var targetCalls sync.Map
+	targetServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		counter, _ := targetCalls.LoadOrStore(r.URL.Path, new(atomic.Int64))
+		counter.(*atomic.Int64).Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(targetServer.Close)
+	calls := func(path string) int64 {
+		counter, ok := targetCalls.Load(path)
+		if !ok {
+			return 0
+		}
+		return counter.(*atomic.Int64).Load()
+	}
+
+	var firstUpstreamPushes, secondUpstreamPushes atomic.Int64
+	firstKumaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		firstUpstreamPushes.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(firstKumaServer.Close)
+	secondKumaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secondUpstreamPushes.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(secondKumaServer.Close)
+
+	stable := roselite.Monitor{
+		ID:            "stable",
+		MonitorType:   roselite.MonitorTypeHTTP,
+		MonitorTarget: targetServer.URL + "/stable",
+		Interval:      time.Millisecond * 150,
+	}
+	removed := roselite.Monitor{
+		ID:            "removed",
+		MonitorType:   roselite.MonitorTypeHTTP,
+		MonitorTarget: targetServer.URL + "/removed",
+		Interval:      time.Millisecond * 20,
+	}
+	added := roselite.Monitor{
+		ID:            "added",
+		MonitorType:   roselite.MonitorTypeHTTP,
+		MonitorTarget: targetServer.URL + "/added",
+		Interval:      time.Millisecond * 20,
+	}
+
+	monitorStore := roselite.NewMonitorStore(10)
+	agent := roselite.NewAgent(roselite.AgentOptions{
+		Monitors:            []roselite.Monitor{stable, removed},
+		UpstreamKumaAddress: firstKumaServer.URL,
+		MonitorStore:        monitorStore,
+	})
+	t.Cleanup(func() {
+		_ = agent.Close()
+	})
+
+	time.Sleep(time.Millisecond * 100)
+	if calls("/removed") == 0 {
+		t.Fatalf("expected the removed monitor to be checked before the update")
+	}
+
+	agent.SetUpstream(secondKumaServer.URL, nil, nil)
+	agent.UpdateMonitors([]roselite.Monitor{stable, added})
+	firstUpstreamPushesAfterUpdate := firstUpstreamPushes.Load()
+	removedCallsAfterUpdate := calls("/removed")
+
+	// Updating with the same monitors must not restart the stable monitor, otherwise it is never checked.
+	for range 8 {
+		time.Sleep(time.Millisecond * 50)
+		agent.UpdateMonitors([]roselite.Monitor{stable, added})
+	}
+
+	if calls("/stable") == 0 {
+		t.Errorf("expected the unchanged monitor to keep its schedule")
+	}
+	if calls("/added") == 0 {
+		t.Errorf("expected the added monitor to be checked")
+	}
+	// A check of the removed monitor may have been in progress during the update.
+	if calls("/removed") > removedCallsAfterUpdate+1 {
+		t.Errorf("expected the removed monitor to be stopped, got %d checks after the update", calls("/removed")-removedCallsAfterUpdate)
+	}
+	if _, _, ok := monitorStore.Monitor("removed", 0); ok {
+		t.Errorf("expected the removed monitor to be unregistered")
+	}
+	if firstUpstreamPushes.Load() > firstUpstreamPushesAfterUpdate+1 {
+		t.Errorf("expected pushes to go to the new upstream, got %d pushes to the previous upstream", firstUpstreamPushes.Load()-firstUpstreamPushesAfterUpdate)
+	}
+	if secondUpstreamPushes.Load() == 0 {
+		t.Errorf("expected pushes to go to the new upstream")
+	}
+
+	if status := agent.SchedulerStatus(); status.Monitors != 2 {
+		t.Errorf("expected 2 scheduled monitors, got %d", status.Monitors)
+	}
+}
+
+func TestAgent_Maintenance(t *testing.T) {
+	targetServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(targetServer.Close)
+
+	var mutex sync.Mutex
+	pushes := make(map[string][]url.Values)
+	kumaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		id := strings.TrimPrefix(r.URL.Path, "/api/push/")
+		pushes[id] = append(pushes[id], r.URL.Query())
+		mutex.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(kumaServer.Close)
+
+	pushesOf := func(id string) []url.Values {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return pushes[id]
+	}
+
+	// A window that starts every minute and lasts an hour is always in effect.
+	schedule, err := roselite.ParseCronSchedule("* * * * *")
+	if err != nil {
+		t.Fatalf("failed to parse schedule: %v", err)
+	}
+	maintenance := roselite.NewMaintenance([]roselite.MaintenanceWindow{
+		{
+			Name:                "nightly-backup",
+			Schedule:            schedule,
+			Duration:            time.Hour,
+			MaintenanceSelector: roselite.MaintenanceSelector{Tags: []string{"database"}},
+		},
+	})
+	_, _ = maintenance.AddSilence(roselite.Silence{
+		MaintenanceSelector: roselite.MaintenanceSelector{Monitors: []string{"cache"}},
+		StartsAt:            time.Now(),
+		EndsAt:              time.Now().Add(time.Hour),
+		Mode:                roselite.MaintenanceModeSkip,
+	})
+
+	agent := roselite.NewAgent(roselite.AgentOptions{
+		Monitors: []roselite.Monitor{
+			{ID: "orders-db", MonitorType: roselite.MonitorTypeHTTP, MonitorTarget: targetServer.URL, Interval: time.Millisecond * 20, Tags: []string{"database"}},
+			{ID: "cache", MonitorType: roselite.MonitorTypeHTTP, MonitorTarget: targetServer.URL, Interval: time.Millisecond * 20},
+			{ID: "web", MonitorType: roselite.MonitorTypeHTTP, MonitorTarget: targetServer.URL, Interval: time.Millisecond * 20},
+		},
+		UpstreamKumaAddress: kumaServer.URL,
+		Maintenance:         maintenance,
+	})
+	go func() {
+		_ = agent.Start()
+	}()
+	t.Cleanup(func() { _ = agent.Close() })
+
+	waitFor(t, "the monitors to be pushed", func() bool {
+		return len(pushesOf("orders-db")) > 0 && len(pushesOf("web")) > 0
+	})
+
+	if push := pushesOf("orders-db")[0]; push.Get("status") != "up" || !strings.Contains(push.Get("msg"), "under maintenance (nightly-backup)") {
+		t.Errorf("expected the monitor under the window to be pushed with the maintenance status, got %v", push)
+	}
+	if push := pushesOf("web")[0]; push.Get("status") != "down" {
+		t.Errorf("expected the monitor outside of the window to be pushed down, got %v", push)
+	}
+	if relayed := pushesOf("cache"); len(relayed) != 0 {
+		t.Errorf("expected the silenced monitor to be skipped, got %v", relayed)
+	}
+}
+
+func TestAgent_UpstreamErrorWithoutPushToken(t *testing.T) {
+	targetServer := KumaServer()
+	t.Cleanup(targetServer.Close)
+
+	// A closed server refuses the connection, which fails the push with an error holding the push URL.
+	kumaServer := KumaServer()
+	kumaServer.Close()
+
+	agent := roselite.NewAgent(roselite.AgentOptions{
+		Monitors: []roselite.Monitor{
+			{ID: "SECRETTOKEN", MonitorType: roselite.MonitorTypeHTTP, MonitorTarget: targetServer.URL, Interval: time.Millisecond * 50},
+		},
+		UpstreamKumaAddress: kumaServer.URL,
+	})
+	go func() {
+		_ = agent.Start()
+	}()
+	t.Cleanup(func() {
+		_ = agent.Close()
+	})
+
+	waitFor(t, "the push to fail", func() bool {
+		return agent.AgentHeartbeat(time.Second).LastUpstreamError != ""
+	})
+
+	// The last upstream error is sent to the server, which lists it on /api/agents.
+	if message := agent.AgentHeartbeat(time.Second).LastUpstreamError; strings.Contains(message, "SECRETTOKEN") {
+		t.Errorf("expected the upstream error not to expose the push token, got %q", message)
+	}
+}
+
+func TestAgent_CheckErrorWithoutTargetSecret(t *testing.T) {
+	// A closed server refuses the connection, which fails the check with an error holding the target URL.
+	targetServer := KumaServer()
+	targetServer.Close()
+
+	kumaServer := KumaServer()
+	t.Cleanup(kumaServer.Close)
+
+	// The target holds a secret, as interpolated from an environment variable by the configuration.
+	monitorStore := roselite.NewMonitorStore(10)
+	agent := roselite.NewAgent(roselite.AgentOptions{
+		Monitors: []roselite.Monitor{
+			{ID: "target", MonitorType: roselite.MonitorTypeHTTP, MonitorTarget: targetServer.URL + "/health?api_key=SECRETKEY", Interval: time.Millisecond * 50},
+		},
+		UpstreamKumaAddress: kumaServer.URL,
+		MonitorStore:        monitorStore,
+	})
+	go func() {
+		_ = agent.Start()
+	}()
+	t.Cleanup(func() {
+		_ = agent.Close()
+	})
+
+	server := roselite.NewServer(roselite.ServerOptions{
+		UpstreamKumaAddress: kumaServer.URL,
+		MonitorStore:        monitorStore,
+		Status:              &roselite.StatusOptions{},
+	})
+	httpServer := httptest.NewServer(server.Handler())
+	t.Cleanup(httpServer.Close)
+
+	waitFor(t, "the check to fail", func() bool {
+		summary, _, ok := monitorStore.Monitor("target", 0)
+		return ok && summary.LastError != ""
+	})
+
+	for _, path := range []string{"/api/monitors", "/status"} {
+		response, err := http.Get(httpServer.URL + path)
+		if err != nil {
+			t.Fatalf("failed to perform request: %v", err)
+		}
+
+		body, err := io.ReadAll(response.Body)
+		_ = response.Body.Close()
+		if err != nil {
+			t.Fatalf("failed to read response body: %v", err)
+		}
+
+		if !strings.Contains(string(body), "connection refused") {
+			t.Errorf("expected %s to report the failed check, got %s", path, body)
+		}
+		if strings.Contains(string(body), "SECRETKEY") {
+			t.Errorf("expected %s not to expose the secret of the target, got %s", path, body)
+		}
+	}
+}
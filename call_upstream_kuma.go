@@ -2,6 +2,8 @@ package roselite
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -10,21 +12,53 @@ import (
 	"github.com/getsentry/sentry-go"
 )
 
+// upstreamPushTimeout is the maximum duration of a single push to the upstream instance.
+const upstreamPushTimeout = time.Minute * 5
+
+// KumaPushURL returns the URL on the upstream instance the heartbeat of the monitor is pushed to.
+func KumaPushURL(upstreamKumaAddress string, id string, heartbeat Heartbeat) (string, error) {
+	requestUrl, err := url.JoinPath(upstreamKumaAddress, "/api/push/"+id)
+	if err != nil {
+		return "", fmt.Errorf("joining path: %w", err)
+	}
+
+	return requestUrl + "?" + heartbeat.ToQuery().Encode(), nil
+}
+
+// PushHeartbeat pushes a single heartbeat of the monitor to the upstream instance, outside an Agent or a Server.
+func PushHeartbeat(ctx context.Context, upstreamKumaAddress string, upstreamRequestHeaders map[string]string, upstreamTLSConfig *tls.Config, id string, heartbeat Heartbeat) error {
+	upstream := newUpstreamClient(upstreamKumaAddress, upstreamRequestHeaders, upstreamTLSConfig)
+	defer upstream.close()
+
+	return callKumaEndpoint(ctx, upstream.address, upstream.requestHeaders, upstream.httpClient, id, heartbeat)
+}
+
+// withoutURL removes the request URL from the error. The push URL holds the push token, and the target of a monitor
+// may hold an interpolated secret, neither must end up in the logs, the status API, the readiness endpoint, Sentry or
+// the self-heartbeat of an agent.
+func withoutURL(err error) error {
+	var urlError *url.Error
+	if errors.As(err, &urlError) {
+		return fmt.Errorf("%s: %w", urlError.Op, urlError.Err)
+	}
+
+	return err
+}
+
 func callKumaEndpoint(ctx context.Context, upstreamKumaAddress string, upstreamRequestHeaders map[string]string, httpClient *http.Client, id string, heartbeat Heartbeat) error {
 	span := sentry.StartSpan(ctx, "function", sentry.WithDescription("callKumaEndpoint"))
-	ctx, cancel := context.WithTimeout(span.Context(), time.Minute*5)
+	ctx, cancel := context.WithTimeout(span.Context(), upstreamPushTimeout)
 	defer cancel()
 	defer span.Finish()
 
-	requestUrl, err := url.JoinPath(upstreamKumaAddress, "/api/push/"+id)
+	requestUrl, err := KumaPushURL(upstreamKumaAddress, id, heartbeat)
 	if err != nil {
-		return fmt.Errorf("joining path: %w", err)
+		return err
 	}
-	requestUrl = requestUrl + "?" + heartbeat.ToQuery().Encode()
 
 	request, err := http.NewRequestWithContext(ctx, http.MethodGet, requestUrl, nil)
 	if err != nil {
-		return fmt.Errorf("creating request: %w", err)
+		return fmt.Errorf("creating request: %w", withoutURL(err))
 	}
 
 	// Custom user agent. It does not matter if it got overwritten by the user.
@@ -36,7 +70,7 @@ func callKumaEndpoint(ctx context.Context, upstreamKumaAddress string, upstreamR
 
 	response, err := httpClient.Do(request)
 	if err != nil {
-		return fmt.Errorf("performing request: %w", err)
+		return fmt.Errorf("performing request: %w", withoutURL(err))
 	}
 	defer func() {
 		if response.Body != nil {
@@ -1,9 +1,24 @@
 package roselite
 
-import "context"
+import (
+	"context"
+	"fmt"
+)
 
 type Caller interface {
 	// Call performs a heartbeat call to the monitor endpoint.
 	// The implementation of this method should be thread-safe.
 	Call(context.Context, Monitor) (Heartbeat, error)
 }
+
+// CallerFor returns the Caller that checks monitors of the given type.
+func CallerFor(monitorType MonitorType) (Caller, error) {
+	switch monitorType {
+	case MonitorTypeHTTP:
+		return &HttpCaller{}, nil
+	case MonitorTypeICMP:
+		return &IcmpCaller{Privileged: false}, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrMonitorTypeInvalid, monitorType.String())
+	}
+}
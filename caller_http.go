@@ -19,11 +19,13 @@ type HttpCaller struct {
 // Call implements Caller.
 func (h *HttpCaller) Call(ctx context.Context, monitor Monitor) (Heartbeat, error) {
 	span := sentry.StartSpan(ctx, "function", sentry.WithDescription("HttpCaller.Call"))
-	ctx = span.Context()
+	ctx, cancel := context.WithTimeout(span.Context(), monitor.CheckTimeout())
+	defer cancel()
 	defer span.Finish()
 
 	request, err := http.NewRequestWithContext(ctx, http.MethodGet, monitor.MonitorTarget, nil)
 	if err != nil {
+		err = withoutURL(err)
 		return Heartbeat{
 			Status:            HeartbeatStatusDown,
 			AdditionalMessage: null.StringFrom(err.Error()),
@@ -66,6 +68,7 @@ func (h *HttpCaller) Call(ctx context.Context, monitor Monitor) (Heartbeat, erro
 	currentInstant := time.Now()
 	response, err := client.Do(request)
 	if err != nil {
+		err = withoutURL(err)
 		elapsed := time.Since(currentInstant)
 		var httpProtocol, tlsVersion, tlsCipherName string
 		var tlsExpiryDate time.Time
@@ -1,6 +1,8 @@
 package roselite_test
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
@@ -73,3 +75,37 @@ func TestHttpCaller(t *testing.T) {
 		}
 	})
 }
+
+func TestHttpCaller_Timeout(t *testing.T) {
+	targetServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second * 5):
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(targetServer.Close)
+
+	caller := roselite.HttpCaller{}
+	monitor := roselite.Monitor{
+		ID:            "slow",
+		MonitorType:   roselite.MonitorTypeHTTP,
+		MonitorTarget: targetServer.URL,
+		Interval:      time.Minute,
+		Timeout:       time.Millisecond * 100,
+	}
+
+	startedAt := time.Now()
+	heartbeat, err := caller.Call(t.Context(), monitor)
+	if err == nil {
+		t.Errorf("expected error, got nil")
+	}
+
+	if elapsed := time.Since(startedAt); elapsed > time.Second {
+		t.Errorf("expected the check to time out after 100ms, took %s", elapsed)
+	}
+
+	if heartbeat.Status != roselite.HeartbeatStatusDown {
+		t.Errorf("expected status to be down, got %s", heartbeat.Status)
+	}
+}
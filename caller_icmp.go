@@ -15,7 +15,8 @@ type IcmpCaller struct {
 // Call implements Caller.
 func (i *IcmpCaller) Call(ctx context.Context, monitor Monitor) (Heartbeat, error) {
 	span := sentry.StartSpan(ctx, "function", sentry.WithDescription("IcmpCaller.Call"))
-	ctx = span.Context()
+	ctx, cancel := context.WithTimeout(span.Context(), monitor.CheckTimeout())
+	defer cancel()
 	defer span.Finish()
 
 	pinger, err := probing.NewPinger(monitor.MonitorTarget)
@@ -26,7 +27,8 @@ func (i *IcmpCaller) Call(ctx context.Context, monitor Monitor) (Heartbeat, erro
 		}, err
 	}
 	pinger.SetPrivileged(i.Privileged)
-	pinger.Count = 3
+	pinger.Count = icmpPacketCount
+	pinger.Timeout = monitor.CheckTimeout()
 	err = pinger.RunWithContext(ctx) // Blocks until finished.
 	if err != nil {
 		return Heartbeat{
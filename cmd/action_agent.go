@@ -4,47 +4,84 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
-	"os"
-	"os/signal"
 
-	"github.com/jinzhu/configor"
 	"github.com/teknologi-umum/roselite"
 	"github.com/urfave/cli/v3"
 )
 
 func AgentAction(ctx context.Context, c *cli.Command) error {
-	var configuration Configuration
-	err := configor.New(&configor.Config{}).Load(&configuration, c.String("config"))
+	configuration, err := loadConfiguration(c.String("config"), c.String("monitors-dir"))
 	if err != nil {
-		return fmt.Errorf("loading configuration: %w", err)
+		return err
 	}
 
-	monitors := make([]roselite.Monitor, len(configuration.Monitors))
-	for i, monitor := range configuration.Monitors {
-		monitors[i] = monitor.ToRoseliteMonitor()
+	if err := validateConfiguration(configuration); err != nil {
+		return err
 	}
 
+	monitors := configuration.AgentMonitors()
+
 	upstreamTLSConfig, err := configuration.UpstreamConfig.TLSConfig.ToTLSConfig()
 	if err != nil {
 		return fmt.Errorf("creating TLS config: %w", err)
 	}
 
+	maintenanceWindows, err := configuration.MaintenanceWindows()
+	if err != nil {
+		return err
+	}
+	maintenance := roselite.NewMaintenance(maintenanceWindows)
+
 	agent := roselite.NewAgent(roselite.AgentOptions{
 		Monitors:               monitors,
 		UpstreamKumaAddress:    configuration.UpstreamConfig.BaseUrl,
 		UpstreamRequestHeaders: configuration.UpstreamConfig.RequestHeaders,
 		UpstreamTLSConfig:      upstreamTLSConfig,
 		RegionIdentifier:       configuration.Region,
+		AgentIdentifier:        configuration.AgentId,
+		Version:                version,
+		Maintenance:            maintenance,
 	})
 
-	exitSignal := make(chan os.Signal, 1)
-	signal.Notify(exitSignal, os.Interrupt)
+	for _, discoverer := range configuration.Discoverers() {
+		go agent.RunDiscovery(ctx, discoverer)
+	}
+
+	if configuration.Registration.Enabled {
+		go agent.RunRegistration(ctx, configuration.RegistrationOptions())
+	}
+
+	if selfMonitor := configuration.SelfMonitor(agent, nil); selfMonitor != nil {
+		go selfMonitor.Run(ctx)
+	}
+
+	configurationStatus := NewConfigurationStatus()
+	go newConfigurationReloader(c, configuration, configurationStatus, func(configuration Configuration) error {
+		upstreamTLSConfig, err := configuration.UpstreamConfig.TLSConfig.ToTLSConfig()
+		if err != nil {
+			return fmt.Errorf("creating TLS config: %w", err)
+		}
+
+		maintenanceWindows, err := configuration.MaintenanceWindows()
+		if err != nil {
+			return err
+		}
+
+		maintenance.SetWindows(maintenanceWindows)
+		agent.SetUpstream(configuration.UpstreamConfig.BaseUrl, configuration.UpstreamConfig.RequestHeaders, upstreamTLSConfig)
+		agent.UpdateMonitors(configuration.AgentMonitors())
+		return nil
+	}).Run(ctx)
 
 	go func() {
-		<-exitSignal
-		err := agent.Close()
+		<-ctx.Done()
+
+		shutdownCtx, cancel := shutdownGracePeriodContext(c)
+		defer cancel()
+
+		err := agent.Shutdown(shutdownCtx)
 		if err != nil {
-			slog.Warn("closing agent", slog.String("error", err.Error()))
+			slog.Warn("shutting down agent", slog.String("error", err.Error()))
 		}
 	}()
 
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/teknologi-umum/roselite"
+	"github.com/urfave/cli/v3"
+)
+
+// checkResult is the outcome of checking a single monitor once.
+type checkResult struct {
+	ID          string             `json:"id"`
+	MonitorType string             `json:"monitor_type"`
+	Target      string             `json:"monitor_target"`
+	Heartbeat   roselite.Heartbeat `json:"heartbeat"`
+	Error       string             `json:"error,omitempty"`
+	UpstreamURL string             `json:"upstream_url,omitempty"`
+	Pushed      bool               `json:"pushed"`
+	PushError   string             `json:"push_error,omitempty"`
+}
+
+// redacted returns the result without the interpolated configuration values, which may be part of the target, the
+// errors or the upstream URL.
+func (r checkResult) redacted() checkResult {
+	r.Target = redact(r.Target)
+	r.Error = redact(r.Error)
+	r.UpstreamURL = redact(r.UpstreamURL)
+	r.PushError = redact(r.PushError)
+	if r.Heartbeat.AdditionalMessage.Valid {
+		r.Heartbeat.AdditionalMessage.String = redact(r.Heartbeat.AdditionalMessage.String)
+	}
+
+	return r
+}
+
+// CheckAction checks the monitors given as arguments, or every monitor if there is none, once. It fails if any of
+// them is not up, or if the heartbeat could not be pushed to the upstream instance.
+func CheckAction(ctx context.Context, c *cli.Command) error {
+	if output := c.String("output"); output != "table" && output != "json" {
+		return fmt.Errorf("unknown output format %q, expected table or json", output)
+	}
+
+	configuration, err := loadConfiguration(c.String("config"), c.String("monitors-dir"))
+	if err != nil {
+		return err
+	}
+
+	if err := validateConfiguration(configuration); err != nil {
+		return err
+	}
+
+	monitors, err := selectMonitors(configuration.ToRoseliteMonitors(), c.Args().Slice())
+	if err != nil {
+		return err
+	}
+
+	upstreamTLSConfig, err := configuration.UpstreamConfig.TLSConfig.ToTLSConfig()
+	if err != nil {
+		return fmt.Errorf("creating TLS config: %w", err)
+	}
+
+	results := make([]checkResult, len(monitors))
+	var wg sync.WaitGroup
+	for i, monitor := range monitors {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			result := checkResult{ID: monitor.ID, MonitorType: monitor.MonitorType.String(), Target: monitor.MonitorTarget}
+			caller, err := roselite.CallerFor(monitor.MonitorType)
+			if err == nil {
+				result.Heartbeat, err = caller.Call(ctx, monitor)
+			} else {
+				result.Heartbeat = roselite.Heartbeat{Status: roselite.HeartbeatStatusDown}
+			}
+			if err != nil {
+				result.Error = err.Error()
+			}
+
+			if c.Bool("dry-run") {
+				upstreamURL, err := roselite.KumaPushURL(configuration.UpstreamConfig.BaseUrl, monitor.ID, result.Heartbeat)
+				if err != nil {
+					result.PushError = err.Error()
+				}
+				result.UpstreamURL = upstreamURL
+			} else if c.Bool("push") {
+				err := roselite.PushHeartbeat(ctx, configuration.UpstreamConfig.BaseUrl, configuration.UpstreamConfig.RequestHeaders, upstreamTLSConfig, monitor.ID, result.Heartbeat)
+				if err != nil {
+					result.PushError = err.Error()
+				}
+				result.Pushed = err == nil
+			}
+
+			results[i] = result.redacted()
+		}()
+	}
+	wg.Wait()
+
+	switch c.String("output") {
+	case "json":
+		encoder := json.NewEncoder(c.Root().Writer)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(results)
+	default:
+		err = writeCheckResults(c.Root().Writer, results, c.Bool("dry-run"), c.Bool("push"))
+	}
+	if err != nil {
+		return fmt.Errorf("writing results: %w", err)
+	}
+
+	var failed int
+	for _, result := range results {
+		if result.Heartbeat.Status != roselite.HeartbeatStatusUp || result.PushError != "" {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d monitors are not up or failed to be pushed", failed, len(results))
+	}
+
+	return nil
+}
+
+// selectMonitors returns the monitors with the given IDs in the given order, or every monitor if no ID is given.
+func selectMonitors(monitors []roselite.Monitor, ids []string) ([]roselite.Monitor, error) {
+	if len(ids) == 0 {
+		return monitors, nil
+	}
+
+	byID := make(map[string]roselite.Monitor, len(monitors))
+	for _, monitor := range monitors {
+		byID[monitor.ID] = monitor
+	}
+
+	selected := make([]roselite.Monitor, 0, len(ids))
+	for _, id := range ids {
+		monitor, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("monitor %q is not configured", id)
+		}
+		selected = append(selected, monitor)
+	}
+
+	return selected, nil
+}
+
+func writeCheckResults(w io.Writer, results []checkResult, dryRun bool, push bool) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	columns := []string{"ID", "TYPE", "TARGET", "STATUS", "PING", "TLS EXPIRY", "MESSAGE"}
+	switch {
+	case dryRun:
+		columns = append(columns, "UPSTREAM URL")
+	case push:
+		columns = append(columns, "PUSHED")
+	}
+	_, _ = fmt.Fprintln(tw, strings.Join(columns, "\t"))
+
+	for _, result := range results {
+		tlsExpiry := "-"
+		if result.Heartbeat.TLSExpiryDate.Valid {
+			tlsExpiry = result.Heartbeat.TLSExpiryDate.Time.UTC().Format(time.DateOnly)
+		}
+
+		message := result.Heartbeat.AdditionalMessage.ValueOrZero()
+		if message == "" {
+			message = result.Error
+		}
+		if message == "" {
+			message = "-"
+		}
+
+		status := result.Heartbeat.Status.String()
+		if status == "" {
+			status = "unknown"
+		}
+
+		row := []string{result.ID, result.MonitorType, result.Target, status, fmt.Sprint(result.Heartbeat.Latency), tlsExpiry, message}
+		switch {
+		case dryRun:
+			row = append(row, result.UpstreamURL)
+		case push && result.Pushed:
+			row = append(row, "yes")
+		case push:
+			row = append(row, "no: "+result.PushError)
+		}
+		_, _ = fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+
+	return tw.Flush()
+}
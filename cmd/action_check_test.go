@@ -0,0 +1,119 @@
+package main_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	main "github.com/teknologi-umum/roselite/cmd"
+	"github.com/urfave/cli/v3"
+)
+
+func TestCheckAction(t *testing.T) {
+	targetServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(targetServer.Close)
+
+	var upstreamPushes atomic.Int64
+	kumaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamPushes.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(kumaServer.Close)
+
+	configurationPath := filepath.Join(t.TempDir(), "roselite.toml")
+	configuration := fmt.Sprintf(`[upstream]
+base_url = %q
+
+[[monitors]]
+id = "up"
+monitor_type = "HTTP"
+monitor_target = %q
+
+[[monitors]]
+id = "down"
+monitor_type = "HTTP"
+monitor_target = %q
+`, kumaServer.URL, targetServer.URL+"/up", targetServer.URL+"/down")
+	if err := os.WriteFile(configurationPath, []byte(configuration), 0o600); err != nil {
+		t.Fatalf("failed to write configuration: %v", err)
+	}
+
+	run := func(args ...string) (string, error) {
+		var output bytes.Buffer
+		cmd := &cli.Command{
+			Name:   "roselite",
+			Writer: &output,
+			Flags:  []cli.Flag{&cli.StringFlag{Name: "config"}},
+			Commands: []*cli.Command{
+				{
+					Name:   "check",
+					Action: main.CheckAction,
+					Flags: []cli.Flag{
+						&cli.StringFlag{Name: "output", Value: "table"},
+						&cli.BoolFlag{Name: "push"},
+						&cli.BoolFlag{Name: "dry-run"},
+					},
+				},
+			},
+		}
+		err := cmd.Run(t.Context(), append([]string{"roselite", "--config", configurationPath, "check"}, args...))
+		return output.String(), err
+	}
+
+	t.Run("Single monitor pushed upstream", func(t *testing.T) {
+		output, err := run("--push", "--output", "json", "up")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var results []struct {
+			ID        string `json:"id"`
+			Heartbeat struct {
+				Status string `json:"status"`
+			} `json:"heartbeat"`
+			Pushed bool `json:"pushed"`
+		}
+		if err := json.Unmarshal([]byte(output), &results); err != nil {
+			t.Fatalf("failed to decode output: %v", err)
+		}
+		if len(results) != 1 || results[0].ID != "up" || results[0].Heartbeat.Status != "up" || !results[0].Pushed {
+			t.Errorf("unexpected results: %s", output)
+		}
+		if upstreamPushes.Load() != 1 {
+			t.Errorf("expected 1 upstream push, got %d", upstreamPushes.Load())
+		}
+	})
+
+	t.Run("Every monitor as a dry run", func(t *testing.T) {
+		output, err := run("--dry-run")
+		if err == nil {
+			t.Errorf("expected an error as a monitor is down")
+		}
+
+		if !strings.Contains(output, kumaServer.URL+"/api/push/down?http_protocol=HTTP%2F1.1&ping=0&status=down") {
+			t.Errorf("expected the upstream URL to be printed, got %s", output)
+		}
+		if upstreamPushes.Load() != 1 {
+			t.Errorf("expected no upstream push on a dry run, got %d", upstreamPushes.Load()-1)
+		}
+	})
+
+	t.Run("Unknown monitor", func(t *testing.T) {
+		if _, err := run("unknown"); err == nil {
+			t.Errorf("expected an error for an unknown monitor")
+		}
+	})
+}
@@ -6,27 +6,24 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
-	"os"
-	"os/signal"
-	"time"
+	"sync"
 
-	"github.com/jinzhu/configor"
 	"github.com/teknologi-umum/roselite"
 	"github.com/urfave/cli/v3"
 )
 
 func DefaultAction(ctx context.Context, c *cli.Command) error {
-	var configuration Configuration
-	err := configor.New(&configor.Config{}).Load(&configuration, c.String("config"))
+	configuration, err := loadConfiguration(c.String("config"), c.String("monitors-dir"))
 	if err != nil {
-		return fmt.Errorf("loading configuration: %w", err)
+		return err
 	}
 
-	monitors := make([]roselite.Monitor, len(configuration.Monitors))
-	for i, monitor := range configuration.Monitors {
-		monitors[i] = monitor.ToRoseliteMonitor()
+	if err := validateConfiguration(configuration); err != nil {
+		return err
 	}
 
+	monitors := configuration.AgentMonitors()
+
 	upstreamTLSConfig, err := configuration.UpstreamConfig.TLSConfig.ToTLSConfig()
 	if err != nil {
 		return fmt.Errorf("creating TLS config: %w", err)
@@ -37,46 +34,138 @@ func DefaultAction(ctx context.Context, c *cli.Command) error {
 		return fmt.Errorf("creating TLS config: %w", err)
 	}
 
-	server := roselite.NewServer(roselite.ServerOptions{
-		ListeningAddress:       configuration.ServerConfig.ListenAddress,
+	monitorDistribution, err := configuration.MonitorDistribution()
+	if err != nil {
+		return err
+	}
+
+	maintenanceWindows, err := configuration.MaintenanceWindows()
+	if err != nil {
+		return err
+	}
+	// The agent and the server share the maintenance, so the silences created on the server apply to the checks too.
+	maintenance := roselite.NewMaintenance(maintenanceWindows)
+
+	configurationStatus := NewConfigurationStatus()
+	monitorStore := roselite.NewMonitorStore(configuration.ServerConfig.HeartbeatHistorySize)
+
+	agent := roselite.NewAgent(roselite.AgentOptions{
+		Monitors:               monitors,
 		UpstreamKumaAddress:    configuration.UpstreamConfig.BaseUrl,
 		UpstreamRequestHeaders: configuration.UpstreamConfig.RequestHeaders,
 		UpstreamTLSConfig:      upstreamTLSConfig,
-		ServerTLSConfig:        serverTLSConfig,
+		RegionIdentifier:       configuration.Region,
+		AgentIdentifier:        configuration.AgentId,
+		Version:                version,
+		MonitorStore:           monitorStore,
+		Maintenance:            maintenance,
 	})
 
-	agent := roselite.NewAgent(roselite.AgentOptions{
-		Monitors:               monitors,
+	for _, discoverer := range configuration.Discoverers() {
+		go agent.RunDiscovery(ctx, discoverer)
+	}
+
+	if configuration.Registration.Enabled {
+		go agent.RunRegistration(ctx, configuration.RegistrationOptions())
+	}
+
+	server := roselite.NewServer(roselite.ServerOptions{
+		ListeningAddress:       configuration.ServerConfig.ListenAddress,
 		UpstreamKumaAddress:    configuration.UpstreamConfig.BaseUrl,
 		UpstreamRequestHeaders: configuration.UpstreamConfig.RequestHeaders,
 		UpstreamTLSConfig:      upstreamTLSConfig,
-		RegionIdentifier:       configuration.Region,
+		ServerTLSConfig:        serverTLSConfig,
+		MonitorAliases:         configuration.ServerConfig.ToRoseliteMonitorAliases(),
+		StrictMonitorAliases:   configuration.ServerConfig.StrictMonitorAliases,
+		PerMonitorRateLimit:    configuration.ServerConfig.RateLimit.PerMonitor.ToRoseliteRateLimit(),
+		PerClientRateLimit:     configuration.ServerConfig.RateLimit.PerClient.ToRoseliteRateLimit(),
+		GlobalRateLimit:        configuration.ServerConfig.RateLimit.Global.ToRoseliteRateLimit(),
+		DeduplicationWindow:    configuration.ServerConfig.RateLimit.DeduplicationWindow.Duration(),
+		BatchConcurrency:       configuration.ServerConfig.BatchConcurrency,
+		AsyncForwarding:        configuration.ServerConfig.ForwardingQueue.Enabled,
+		ForwardingQueueSize:    configuration.ServerConfig.ForwardingQueue.Size,
+		ForwardingWorkers:      configuration.ServerConfig.ForwardingQueue.Workers,
+		InstanceIdentifier:     configuration.ServerConfig.InstanceId,
+		MaxHops:                configuration.ServerConfig.MaxHops,
+		MonitorStore:           monitorStore,
+		MonitorDistribution:    monitorDistribution,
+		Fleet:                  configuration.FleetOptions(),
+		Maintenance:            maintenance,
+		Silences:               configuration.SilenceOptions(),
+		Status:                 configuration.ServerConfig.StatusOptions(),
+		HealthChecks: []roselite.HealthCheck{
+			{Name: "configuration", Check: configurationStatus.Check},
+			{Name: "scheduler", Check: agent.CheckScheduler},
+		},
 	})
 
-	exitSignal := make(chan os.Signal, 1)
-	signal.Notify(exitSignal, os.Interrupt)
+	go server.WatchFleet(ctx)
+
+	if selfMonitor := configuration.SelfMonitor(agent, server); selfMonitor != nil {
+		go selfMonitor.Run(ctx)
+	}
+
+	go newConfigurationReloader(c, configuration, configurationStatus, func(configuration Configuration) error {
+		upstreamTLSConfig, err := configuration.UpstreamConfig.TLSConfig.ToTLSConfig()
+		if err != nil {
+			return fmt.Errorf("creating TLS config: %w", err)
+		}
+
+		monitorDistribution, err := configuration.MonitorDistribution()
+		if err != nil {
+			return err
+		}
 
+		maintenanceWindows, err := configuration.MaintenanceWindows()
+		if err != nil {
+			return err
+		}
+
+		maintenance.SetWindows(maintenanceWindows)
+		server.SetMonitorDistribution(monitorDistribution)
+		server.SetFleet(configuration.FleetOptions())
+		server.SetSilences(configuration.SilenceOptions())
+		server.SetStatus(configuration.ServerConfig.StatusOptions())
+		server.SetUpstream(configuration.UpstreamConfig.BaseUrl, configuration.UpstreamConfig.RequestHeaders, upstreamTLSConfig)
+		agent.SetUpstream(configuration.UpstreamConfig.BaseUrl, configuration.UpstreamConfig.RequestHeaders, upstreamTLSConfig)
+		agent.UpdateMonitors(configuration.AgentMonitors())
+		return nil
+	}).Run(ctx)
+
+	agentDone := make(chan struct{})
 	go func() {
-		<-exitSignal
-		err := server.Shutdown(ctx)
+		defer close(agentDone)
+		err := agent.Start()
 		if err != nil {
-			slog.Warn("closing agent", slog.String("error", err.Error()))
+			slog.Warn("starting agent", slog.String("error", err.Error()))
 		}
 	}()
 
+	shutdownDone := make(chan struct{})
 	go func() {
-		select {
-		case <-ctx.Done():
-			return
-		default:
-			err := agent.Start()
+		defer close(shutdownDone)
+		<-ctx.Done()
+
+		shutdownCtx, cancel := shutdownGracePeriodContext(c)
+		defer cancel()
+
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			err := server.Shutdown(shutdownCtx)
 			if err != nil {
-				slog.Warn("starting agent", slog.String("error", err.Error()))
+				slog.Warn("shutting down server", slog.String("error", err.Error()))
 			}
-
-			slog.Debug("agent process: sleeping for 5 seconds")
-			time.Sleep(time.Second * 5)
-		}
+		}()
+		go func() {
+			defer wg.Done()
+			err := agent.Shutdown(shutdownCtx)
+			if err != nil {
+				slog.Warn("shutting down agent", slog.String("error", err.Error()))
+			}
+		}()
+		wg.Wait()
 	}()
 
 	if configuration.ServerConfig.TLSConfig.CertificateFile != "" && configuration.ServerConfig.TLSConfig.PrivateKeyFile != "" {
@@ -88,5 +177,7 @@ func DefaultAction(ctx context.Context, c *cli.Command) error {
 		return fmt.Errorf("failed to start server: %v", err)
 	}
 
+	<-shutdownDone
+	<-agentDone
 	return nil
 }
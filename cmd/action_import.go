@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/configor"
+	"github.com/teknologi-umum/roselite"
+	"github.com/urfave/cli/v3"
+)
+
+// kumaBackup is the part of an Uptime Kuma JSON backup that is relevant to roselite.
+type kumaBackup struct {
+	Version     string        `json:"version"`
+	MonitorList []kumaMonitor `json:"monitorList"`
+}
+
+type kumaMonitor struct {
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	PushToken string    `json:"pushToken"`
+	Interval  int       `json:"interval"`
+	Tags      []kumaTag `json:"tags"`
+}
+
+type kumaTag struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// ImportMapping tells the target and type of the imported push monitors, as the backup only knows their push tokens.
+type ImportMapping struct {
+	// Monitors is keyed by the name of the monitor on Uptime Kuma, or by its push token.
+	Monitors map[string]ImportTarget `json:"monitors" toml:"monitors" yaml:"monitors"`
+}
+
+// ImportTarget is the check roselite runs for an imported push monitor.
+type ImportTarget struct {
+	MonitorType   string `json:"monitor_type" toml:"monitor_type" yaml:"monitor_type"`
+	MonitorTarget string `json:"monitor_target" toml:"monitor_target" yaml:"monitor_target"`
+	Template      string `json:"template" toml:"template" yaml:"template"`
+}
+
+// importedMonitor is a monitor written to the generated configuration.
+type importedMonitor struct {
+	name     string
+	id       string
+	target   ImportTarget
+	interval time.Duration
+	tags     []string
+}
+
+// ImportKumaBackupAction converts the push monitors of an Uptime Kuma JSON backup to a roselite configuration, with
+// their push tokens as IDs. The targets come from a mapping file or the --target flags, and the monitors without
+// one are written with an empty target to be filled in by hand.
+func ImportKumaBackupAction(ctx context.Context, c *cli.Command) error {
+	if c.Args().Len() != 1 {
+		return fmt.Errorf("expected the path to the backup file as the only argument, got %d arguments", c.Args().Len())
+	}
+
+	backupPath := c.Args().First()
+	content, err := os.ReadFile(backupPath)
+	if err != nil {
+		return fmt.Errorf("reading backup: %w", err)
+	}
+
+	var backup kumaBackup
+	if err := json.Unmarshal(content, &backup); err != nil {
+		return fmt.Errorf("parsing backup: %w", err)
+	}
+
+	mapping := ImportMapping{Monitors: make(map[string]ImportTarget)}
+	if path := c.String("mapping"); path != "" {
+		if err := configor.New(&configor.Config{}).Load(&mapping, path); err != nil {
+			return fmt.Errorf("loading mapping: %w", err)
+		}
+	}
+
+	for _, flag := range c.StringSlice("target") {
+		key, target, ok := strings.Cut(flag, "=")
+		if !ok || key == "" || target == "" {
+			return fmt.Errorf("invalid target %q, expected <monitor name or push token>=<target>", flag)
+		}
+
+		entry := mapping.Monitors[key]
+		entry.MonitorTarget = target
+		mapping.Monitors[key] = entry
+	}
+
+	defaultType, err := roselite.MonitorTypeFromString(c.String("type"))
+	if err != nil {
+		return err
+	}
+
+	monitors, unused := importKumaMonitors(backup, mapping, defaultType.String())
+	for _, key := range unused {
+		_, _ = fmt.Fprintf(c.Root().ErrWriter, "warning: %q on the mapping does not match the name or push token of any push monitor\n", key)
+	}
+
+	var missingTargets int
+	for _, monitor := range monitors {
+		if monitor.target.MonitorTarget == "" {
+			missingTargets++
+		}
+	}
+	if missingTargets > 0 {
+		_, _ = fmt.Fprintf(c.Root().ErrWriter, "warning: %d of %d monitors have no target, set their monitor_target before using the configuration\n", missingTargets, len(monitors))
+	}
+
+	var output bytes.Buffer
+	writeImportedConfiguration(&output, backupPath, backup.Version, c.String("upstream-base-url"), monitors)
+
+	if path := c.String("output"); path != "" && path != "-" {
+		// Never overwrite an existing configuration by accident.
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			return fmt.Errorf("creating output: %w", err)
+		}
+		defer func() {
+			_ = file.Close()
+		}()
+
+		if _, err := output.WriteTo(file); err != nil {
+			return fmt.Errorf("writing output: %w", err)
+		}
+
+		_, _ = fmt.Fprintf(c.Root().ErrWriter, "imported %d push monitors to %s\n", len(monitors), path)
+		return nil
+	}
+
+	if _, err := output.WriteTo(c.Root().Writer); err != nil {
+		return fmt.Errorf("writing output: %w", err)
+	}
+
+	return nil
+}
+
+// importKumaMonitors returns the push monitors of the backup, matched against the mapping by push token first, then
+// by name. It also returns the mapping keys that matched no push monitor, sorted.
+func importKumaMonitors(backup kumaBackup, mapping ImportMapping, defaultType string) ([]importedMonitor, []string) {
+	used := make(map[string]bool, len(mapping.Monitors))
+	var monitors []importedMonitor
+	for _, monitor := range backup.MonitorList {
+		if monitor.Type != "push" || monitor.PushToken == "" {
+			continue
+		}
+
+		key := monitor.PushToken
+		target, ok := mapping.Monitors[key]
+		if !ok {
+			key = monitor.Name
+			target, ok = mapping.Monitors[key]
+		}
+		if ok {
+			used[key] = true
+		}
+		if target.MonitorType == "" && target.Template == "" {
+			target.MonitorType = defaultType
+		}
+
+		imported := importedMonitor{
+			name:     monitor.Name,
+			id:       monitor.PushToken,
+			target:   target,
+			interval: time.Duration(monitor.Interval) * time.Second,
+		}
+		for _, tag := range monitor.Tags {
+			if tag.Value != "" {
+				imported.tags = append(imported.tags, tag.Name+":"+tag.Value)
+			} else {
+				imported.tags = append(imported.tags, tag.Name)
+			}
+		}
+
+		monitors = append(monitors, imported)
+	}
+
+	var unused []string
+	for key := range mapping.Monitors {
+		if !used[key] {
+			unused = append(unused, key)
+		}
+	}
+	slices.Sort(unused)
+
+	return monitors, unused
+}
+
+func writeImportedConfiguration(w io.Writer, backupPath string, version string, upstreamBaseUrl string, monitors []importedMonitor) {
+	_, _ = fmt.Fprintf(w, "# Imported from the Uptime Kuma %s backup %s. Run `roselite validate` to check this file.\n\n", version, backupPath)
+
+	_, _ = fmt.Fprintln(w, "[upstream]")
+	if upstreamBaseUrl != "" {
+		_, _ = fmt.Fprintf(w, "base_url = %s\n", tomlString(upstreamBaseUrl))
+	} else {
+		_, _ = fmt.Fprintln(w, `base_url = "https://your-uptime-kuma.com"`)
+	}
+
+	for _, monitor := range monitors {
+		_, _ = fmt.Fprintf(w, "\n# %s\n", strings.ReplaceAll(monitor.name, "\n", " "))
+		_, _ = fmt.Fprintln(w, "[[monitors]]")
+		_, _ = fmt.Fprintf(w, "id = %s\n", tomlString(monitor.id))
+		if monitor.target.Template != "" {
+			_, _ = fmt.Fprintf(w, "template = %s\n", tomlString(monitor.target.Template))
+		}
+		if monitor.target.MonitorType != "" {
+			_, _ = fmt.Fprintf(w, "monitor_type = %s\n", tomlString(monitor.target.MonitorType))
+		}
+		if monitor.target.MonitorTarget == "" {
+			_, _ = fmt.Fprintln(w, "# TODO: the backup does not know what this monitor checks, set the target.")
+		}
+		_, _ = fmt.Fprintf(w, "monitor_target = %s\n", tomlString(monitor.target.MonitorTarget))
+		if monitor.interval > 0 {
+			_, _ = fmt.Fprintf(w, "interval = %s\n", tomlString(Duration(monitor.interval).String()))
+		}
+		if len(monitor.tags) > 0 {
+			tags := make([]string, len(monitor.tags))
+			for i, tag := range monitor.tags {
+				tags[i] = tomlString(tag)
+			}
+			_, _ = fmt.Fprintf(w, "tags = [%s]\n", strings.Join(tags, ", "))
+		}
+	}
+}
+
+// tomlString quotes s as a TOML basic string, escaping ${ so it is not interpolated. Every JSON string is a valid
+// TOML basic string.
+func tomlString(s string) string {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(strings.ReplaceAll(s, "${", "$${"))
+
+	return strings.TrimSuffix(buffer.String(), "\n")
+}
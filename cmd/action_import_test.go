@@ -0,0 +1,122 @@
+package main_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	main "github.com/teknologi-umum/roselite/cmd"
+	"github.com/urfave/cli/v3"
+)
+
+const kumaBackup = `{
+  "version": "1.23.11",
+  "notificationList": [],
+  "monitorList": [
+    {"id": 1, "name": "Billing API", "type": "push", "pushToken": "Eq15E23yc3", "interval": 60, "url": "https://", "tags": [{"tag_id": 1, "name": "production", "value": ""}, {"tag_id": 2, "name": "team", "value": "billing"}]},
+    {"id": 2, "name": "Website", "type": "http", "pushToken": null, "interval": 60, "url": "https://example.com", "tags": []},
+    {"id": 3, "name": "Database host", "type": "push", "pushToken": "Ab12Cd34Ef", "interval": 120, "tags": []},
+    {"id": 4, "name": "Cron job", "type": "push", "pushToken": "Zz98Yy76Xx", "interval": 3600, "tags": []}
+  ]
+}`
+
+func TestImportKumaBackupAction(t *testing.T) {
+	dir := t.TempDir()
+	backupPath := filepath.Join(dir, "backup.json")
+	if err := os.WriteFile(backupPath, []byte(kumaBackup), 0o600); err != nil {
+		t.Fatalf("failed to write backup: %v", err)
+	}
+
+	mappingPath := filepath.Join(dir, "mapping.yaml")
+	mapping := `monitors:
+  Ab12Cd34Ef:
+    monitor_type: ICMP
+    monitor_target: db.internal
+  Unknown monitor:
+    monitor_target: https://unknown.example.com
+`
+	if err := os.WriteFile(mappingPath, []byte(mapping), 0o600); err != nil {
+		t.Fatalf("failed to write mapping: %v", err)
+	}
+
+	run := func(args ...string) (string, string, error) {
+		var output, errOutput bytes.Buffer
+		cmd := &cli.Command{
+			Name:      "roselite",
+			Writer:    &output,
+			ErrWriter: &errOutput,
+			Flags:     []cli.Flag{&cli.StringFlag{Name: "config"}},
+			Commands: []*cli.Command{
+				{Name: "validate", Action: main.ValidateAction},
+				{
+					Name: "import",
+					Commands: []*cli.Command{
+						{
+							Name:   "kuma-backup",
+							Action: main.ImportKumaBackupAction,
+							Flags: []cli.Flag{
+								&cli.StringFlag{Name: "mapping"},
+								&cli.StringSliceFlag{Name: "target"},
+								&cli.StringFlag{Name: "type", Value: "HTTP"},
+								&cli.StringFlag{Name: "upstream-base-url"},
+								&cli.StringFlag{Name: "output"},
+							},
+						},
+					},
+				},
+			},
+		}
+		err := cmd.Run(t.Context(), append([]string{"roselite"}, args...))
+		return output.String(), errOutput.String(), err
+	}
+
+	configurationPath := filepath.Join(dir, "roselite.toml")
+	_, warnings, err := run("import", "kuma-backup",
+		"--mapping", mappingPath,
+		"--target", "Billing API=https://billing.example.com/health",
+		"--upstream-base-url", "https://kuma.example.com",
+		"--output", configurationPath,
+		backupPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, expected := range []string{
+		`"Unknown monitor" on the mapping does not match`,
+		"1 of 3 monitors have no target",
+	} {
+		if !strings.Contains(warnings, expected) {
+			t.Errorf("expected %q in warnings, got %q", expected, warnings)
+		}
+	}
+
+	content, err := os.ReadFile(configurationPath)
+	if err != nil {
+		t.Fatalf("failed to read generated configuration: %v", err)
+	}
+	for _, expected := range []string{
+		"base_url = \"https://kuma.example.com\"",
+		"# Billing API\n[[monitors]]\nid = \"Eq15E23yc3\"\nmonitor_type = \"HTTP\"\nmonitor_target = \"https://billing.example.com/health\"\ninterval = \"1m0s\"\ntags = [\"production\", \"team:billing\"]\n",
+		"id = \"Ab12Cd34Ef\"\nmonitor_type = \"ICMP\"\nmonitor_target = \"db.internal\"\ninterval = \"2m0s\"\n",
+		"id = \"Zz98Yy76Xx\"\nmonitor_type = \"HTTP\"\n# TODO",
+	} {
+		if !strings.Contains(string(content), expected) {
+			t.Errorf("expected %q in generated configuration, got:\n%s", expected, content)
+		}
+	}
+	if strings.Contains(string(content), "Website") {
+		t.Errorf("expected monitors that are not push monitors to be skipped, got:\n%s", content)
+	}
+
+	// The monitor without a target is the only problem on the generated configuration.
+	output, _, err := run("--config", configurationPath, "validate")
+	if err == nil || !strings.Contains(output, `(id "Zz98Yy76Xx").monitor_target`) || strings.Count(output, "\n") != 1 {
+		t.Errorf("expected a single problem on the monitor without a target, got %v:\n%s", err, output)
+	}
+
+	if _, _, err := run("import", "kuma-backup", "--output", configurationPath, backupPath); err == nil {
+		t.Error("expected an error when the output file exists, got nil")
+	}
+}
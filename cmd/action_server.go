@@ -6,24 +6,19 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
-	"os"
-	"os/signal"
 
-	"github.com/jinzhu/configor"
 	"github.com/teknologi-umum/roselite"
 	"github.com/urfave/cli/v3"
 )
 
 func ServerAction(ctx context.Context, c *cli.Command) error {
-	var configuration Configuration
-	err := configor.New(&configor.Config{}).Load(&configuration, c.String("config"))
+	configuration, err := loadConfiguration(c.String("config"), c.String("monitors-dir"))
 	if err != nil {
-		return fmt.Errorf("loading configuration: %w", err)
+		return err
 	}
 
-	monitors := make([]roselite.Monitor, len(configuration.Monitors))
-	for i, monitor := range configuration.Monitors {
-		monitors[i] = monitor.ToRoseliteMonitor()
+	if err := validateConfiguration(configuration); err != nil {
+		return err
 	}
 
 	upstreamTLSConfig, err := configuration.UpstreamConfig.TLSConfig.ToTLSConfig()
@@ -36,22 +31,91 @@ func ServerAction(ctx context.Context, c *cli.Command) error {
 		return fmt.Errorf("creating TLS config: %w", err)
 	}
 
+	monitorDistribution, err := configuration.MonitorDistribution()
+	if err != nil {
+		return err
+	}
+
+	maintenanceWindows, err := configuration.MaintenanceWindows()
+	if err != nil {
+		return err
+	}
+	maintenance := roselite.NewMaintenance(maintenanceWindows)
+
+	configurationStatus := NewConfigurationStatus()
+	monitorStore := roselite.NewMonitorStore(configuration.ServerConfig.HeartbeatHistorySize)
+
 	server := roselite.NewServer(roselite.ServerOptions{
 		ListeningAddress:       configuration.ServerConfig.ListenAddress,
 		UpstreamKumaAddress:    configuration.UpstreamConfig.BaseUrl,
 		UpstreamRequestHeaders: configuration.UpstreamConfig.RequestHeaders,
 		UpstreamTLSConfig:      upstreamTLSConfig,
 		ServerTLSConfig:        serverTLSConfig,
+		MonitorAliases:         configuration.ServerConfig.ToRoseliteMonitorAliases(),
+		StrictMonitorAliases:   configuration.ServerConfig.StrictMonitorAliases,
+		PerMonitorRateLimit:    configuration.ServerConfig.RateLimit.PerMonitor.ToRoseliteRateLimit(),
+		PerClientRateLimit:     configuration.ServerConfig.RateLimit.PerClient.ToRoseliteRateLimit(),
+		GlobalRateLimit:        configuration.ServerConfig.RateLimit.Global.ToRoseliteRateLimit(),
+		DeduplicationWindow:    configuration.ServerConfig.RateLimit.DeduplicationWindow.Duration(),
+		BatchConcurrency:       configuration.ServerConfig.BatchConcurrency,
+		AsyncForwarding:        configuration.ServerConfig.ForwardingQueue.Enabled,
+		ForwardingQueueSize:    configuration.ServerConfig.ForwardingQueue.Size,
+		ForwardingWorkers:      configuration.ServerConfig.ForwardingQueue.Workers,
+		InstanceIdentifier:     configuration.ServerConfig.InstanceId,
+		MaxHops:                configuration.ServerConfig.MaxHops,
+		MonitorStore:           monitorStore,
+		MonitorDistribution:    monitorDistribution,
+		Fleet:                  configuration.FleetOptions(),
+		Maintenance:            maintenance,
+		Silences:               configuration.SilenceOptions(),
+		Status:                 configuration.ServerConfig.StatusOptions(),
+		HealthChecks: []roselite.HealthCheck{
+			{Name: "configuration", Check: configurationStatus.Check},
+		},
 	})
 
-	exitSignal := make(chan os.Signal, 1)
-	signal.Notify(exitSignal, os.Interrupt)
+	go server.WatchFleet(ctx)
+
+	if selfMonitor := configuration.SelfMonitor(nil, server); selfMonitor != nil {
+		go selfMonitor.Run(ctx)
+	}
 
+	go newConfigurationReloader(c, configuration, configurationStatus, func(configuration Configuration) error {
+		upstreamTLSConfig, err := configuration.UpstreamConfig.TLSConfig.ToTLSConfig()
+		if err != nil {
+			return fmt.Errorf("creating TLS config: %w", err)
+		}
+
+		monitorDistribution, err := configuration.MonitorDistribution()
+		if err != nil {
+			return err
+		}
+
+		maintenanceWindows, err := configuration.MaintenanceWindows()
+		if err != nil {
+			return err
+		}
+
+		maintenance.SetWindows(maintenanceWindows)
+		server.SetMonitorDistribution(monitorDistribution)
+		server.SetFleet(configuration.FleetOptions())
+		server.SetSilences(configuration.SilenceOptions())
+		server.SetStatus(configuration.ServerConfig.StatusOptions())
+		server.SetUpstream(configuration.UpstreamConfig.BaseUrl, configuration.UpstreamConfig.RequestHeaders, upstreamTLSConfig)
+		return nil
+	}).Run(ctx)
+
+	shutdownDone := make(chan struct{})
 	go func() {
-		<-exitSignal
-		err := server.Shutdown(ctx)
+		defer close(shutdownDone)
+		<-ctx.Done()
+
+		shutdownCtx, cancel := shutdownGracePeriodContext(c)
+		defer cancel()
+
+		err := server.Shutdown(shutdownCtx)
 		if err != nil {
-			slog.Warn("closing agent", slog.String("error", err.Error()))
+			slog.Warn("shutting down server", slog.String("error", err.Error()))
 		}
 	}()
 
@@ -64,5 +128,6 @@ func ServerAction(ctx context.Context, c *cli.Command) error {
 		return fmt.Errorf("failed to start server: %v", err)
 	}
 
+	<-shutdownDone
 	return nil
 }
@@ -5,8 +5,9 @@ import (
 	"crypto/x509"
 	"fmt"
 	"log/slog"
+	"maps"
 	"os"
-	"time"
+	"slices"
 
 	"github.com/teknologi-umum/roselite"
 )
@@ -38,6 +39,11 @@ type TLSConfig struct {
 	SkipTLSVerify bool `json:"skip_tls_verify" toml:"skip_tls_verify" yaml:"skip_tls_verify"`
 }
 
+// hasFiles reports whether the configuration reads a certificate authority, a certificate or a private key file.
+func (t TLSConfig) hasFiles() bool {
+	return t.CertificateAuthorityFile != "" || t.CertificateFile != "" || t.PrivateKeyFile != ""
+}
+
 // ToTLSConfig generates a *tls.Config based on the TLSConfig struct, including certificates and verification settings.
 func (t TLSConfig) ToTLSConfig() (*tls.Config, error) {
 	var certificates []tls.Certificate = nil
@@ -85,6 +91,125 @@ type ServerConfig struct {
 	//
 	// Deprecated: Specify UpstreamConfig.BaseUrl instead. The value of this option will be ignored.
 	UpstreamKuma string `json:"upstream_kuma" toml:"upstream_kuma" yaml:"upstream_kuma" env:"UPSTREAM_KUMA"`
+
+	// MonitorAliases maps a local monitor ID received on the push endpoint to a push token on the upstream instance.
+	MonitorAliases map[string]MonitorAlias `json:"monitor_aliases" toml:"monitor_aliases" yaml:"monitor_aliases"`
+
+	// StrictMonitorAliases rejects pushes for monitor IDs that are not listed on MonitorAliases.
+	StrictMonitorAliases bool `json:"strict_monitor_aliases" toml:"strict_monitor_aliases" yaml:"strict_monitor_aliases" env:"STRICT_MONITOR_ALIASES"`
+
+	// RateLimit configures the rate limits and the deduplication of the push endpoint.
+	RateLimit RateLimitConfig `json:"rate_limit" toml:"rate_limit" yaml:"rate_limit"`
+
+	// BatchConcurrency is the amount of heartbeats from a single batch push that are forwarded upstream at the same time.
+	BatchConcurrency int `json:"batch_concurrency" toml:"batch_concurrency" yaml:"batch_concurrency" env:"BATCH_CONCURRENCY" default:"8"`
+
+	// InstanceId identifies this server on the hops header when relays are chained. It must be unique across the chain,
+	// and defaults to the hostname.
+	InstanceId string `json:"instance_id" toml:"instance_id" yaml:"instance_id" env:"INSTANCE_ID"`
+
+	// MaxHops is the maximum amount of relays a heartbeat can pass through before it is rejected.
+	MaxHops int `json:"max_hops" toml:"max_hops" yaml:"max_hops" env:"MAX_HOPS" default:"8"`
+
+	// HeartbeatHistorySize is the amount of heartbeats kept in memory for every monitor, shown on the status API.
+	HeartbeatHistorySize int `json:"heartbeat_history_size" toml:"heartbeat_history_size" yaml:"heartbeat_history_size" env:"HEARTBEAT_HISTORY_SIZE" default:"20"`
+
+	// ForwardingQueue configures the asynchronous forwarding of pushes to the upstream instance.
+	ForwardingQueue ForwardingQueueConfig `json:"forwarding_queue" toml:"forwarding_queue" yaml:"forwarding_queue"`
+
+	// Distribution hands out the monitors of this configuration to the agents that select them.
+	Distribution DistributionConfig `json:"distribution" toml:"distribution" yaml:"distribution"`
+
+	// Fleet tracks the agents registered with this server, and reports the ones that go silent.
+	Fleet FleetConfig `json:"fleet" toml:"fleet" yaml:"fleet"`
+
+	// Status enables the status API and the status page of the monitors.
+	Status StatusConfig `json:"status" toml:"status" yaml:"status"`
+}
+
+// ForwardingQueueConfig defines the in-memory queue used to forward pushes to the upstream instance asynchronously.
+type ForwardingQueueConfig struct {
+	// Enabled acknowledges pushes with 202 Accepted as soon as they are queued, instead of waiting for the upstream.
+	Enabled bool `json:"enabled" toml:"enabled" yaml:"enabled" env:"FORWARDING_QUEUE_ENABLED"`
+
+	// Size is the maximum amount of heartbeats waiting in the queue. Pushes are rejected once the queue is full.
+	Size int `json:"size" toml:"size" yaml:"size" env:"FORWARDING_QUEUE_SIZE" default:"1024"`
+
+	// Workers is the amount of workers forwarding the queued heartbeats to the upstream instance.
+	Workers int `json:"workers" toml:"workers" yaml:"workers" env:"FORWARDING_QUEUE_WORKERS" default:"4"`
+}
+
+// StatusConfig configures the /api/monitors endpoints and the /status page.
+type StatusConfig struct {
+	// Enabled serves the status of the monitors. The push tokens of the monitors that are not aliases are masked.
+	Enabled bool `json:"enabled" toml:"enabled" yaml:"enabled" env:"STATUS_ENABLED"`
+
+	// Tokens are the bearer tokens allowed to read the status. Leaving it empty lets any client do so.
+	Tokens []string `json:"tokens" toml:"tokens" yaml:"tokens"`
+}
+
+// RateLimitConfig holds the token bucket limits of the push endpoint, and the window for collapsing identical heartbeats.
+type RateLimitConfig struct {
+	// PerMonitor limits the amount of pushes for every monitor ID.
+	PerMonitor RateLimit `json:"per_monitor" toml:"per_monitor" yaml:"per_monitor"`
+
+	// PerClient limits the amount of pushes for every client IP address.
+	PerClient RateLimit `json:"per_client" toml:"per_client" yaml:"per_client"`
+
+	// Global limits the amount of pushes the server accepts in total.
+	Global RateLimit `json:"global" toml:"global" yaml:"global"`
+
+	// DeduplicationWindow specifies the window in which heartbeats with the same status and message for the same
+	// monitor ID are collapsed into a single upstream push. Zero disables the deduplication.
+	DeduplicationWindow Duration `json:"deduplication_window" toml:"deduplication_window" yaml:"deduplication_window"`
+}
+
+// RateLimit defines a token bucket. Leaving RequestsPerSecond empty disables the limit.
+type RateLimit struct {
+	// RequestsPerSecond is the rate in which the bucket is refilled.
+	RequestsPerSecond float64 `json:"requests_per_second" toml:"requests_per_second" yaml:"requests_per_second"`
+
+	// Burst is the maximum amount of requests that can be made at once.
+	Burst int `json:"burst" toml:"burst" yaml:"burst"`
+}
+
+// ToRoseliteRateLimit converts a RateLimit instance to a roselite.RateLimit.
+func (r RateLimit) ToRoseliteRateLimit() roselite.RateLimit {
+	return roselite.RateLimit{
+		RequestsPerSecond: r.RequestsPerSecond,
+		Burst:             r.Burst,
+	}
+}
+
+// MonitorAlias defines the upstream push token, and optionally the upstream instance, a local monitor ID points to.
+type MonitorAlias struct {
+	// Token is the push token of the monitor on the upstream instance.
+	Token string `json:"token" toml:"token" yaml:"token"`
+
+	// UpstreamBaseUrl overrides UpstreamConfig.BaseUrl for this alias. Leave it empty to use the default upstream.
+	UpstreamBaseUrl string `json:"upstream_base_url" toml:"upstream_base_url" yaml:"upstream_base_url"`
+}
+
+// ToRoseliteMonitorAliases converts the configured monitor aliases into a map of roselite.MonitorAlias.
+func (s ServerConfig) ToRoseliteMonitorAliases() map[string]roselite.MonitorAlias {
+	monitorAliases := make(map[string]roselite.MonitorAlias, len(s.MonitorAliases))
+	for id, alias := range s.MonitorAliases {
+		monitorAliases[id] = roselite.MonitorAlias{
+			UpstreamID:          alias.Token,
+			UpstreamKumaAddress: alias.UpstreamBaseUrl,
+		}
+	}
+
+	return monitorAliases
+}
+
+// StatusOptions returns the options of the status API and the status page, or nil if they are disabled.
+func (s ServerConfig) StatusOptions() *roselite.StatusOptions {
+	if !s.Status.Enabled {
+		return nil
+	}
+
+	return &roselite.StatusOptions{Tokens: s.Status.Tokens}
 }
 
 // UpstreamConfig defines the configuration for upstream communication, including base URL, request headers, and TLS settings.
@@ -126,11 +251,115 @@ type Monitor struct {
 	// TLSConfig represents the TLS-related settings, including certificate paths and skip verification options.
 	TLSConfig TLSConfig `json:"tls_config" toml:"tls_config" yaml:"tls_config"`
 
-	// Interval specifies the interval in seconds for how often the monitor performs its checks.
-	Interval int `json:"interval" toml:"interval" yaml:"interval"`
+	// Defaults to the template, then to Configuration.Defaults. It must be at least roselite.MinimumMonitorInterval.
+	// Defaults to the template, then to Configuration.Defaults.
+	Interval Duration `json:"interval" toml:"interval" yaml:"interval"`
+
+	// Timeout is the maximum duration of a single check, such as "10s" or an integer number of seconds.
+	// Defaults to the template, then to Configuration.Defaults.
+	Timeout Duration `json:"timeout" toml:"timeout" yaml:"timeout"`
 
 	// EnableSentrySampling indicates whether Sentry sampling is enabled for reporting errors or monitoring.
 	EnableSentrySampling bool `json:"enable_sentry_sampling" toml:"enable_sentry_sampling" yaml:"enable_sentry_sampling"`
+
+	// Tags groups the monitor with other monitors on the status page.
+	Tags []string `json:"tags" toml:"tags" yaml:"tags"`
+
+	// Template is the name of an entry on Configuration.Templates the monitor takes its unset options from.
+	Template string `json:"template" toml:"template" yaml:"template"`
+
+	// Regions limits the agents that check the monitor to the ones in these regions. Defaults to every region.
+	Regions []string `json:"regions" toml:"regions" yaml:"regions"`
+
+	// Agents limits the agents that check the monitor to the ones with these identifiers. Defaults to every agent.
+	Agents []string `json:"agents" toml:"agents" yaml:"agents"`
+
+	// location is where the monitor is defined, set for the monitors loaded from an included file.
+	location string
+}
+
+// MonitorTemplate holds the options shared by many monitors. It is used for the top-level defaults, and for the named
+// templates that a monitor references with its template option.
+type MonitorTemplate struct {
+	// MonitorType is used by the monitors that do not set their own type.
+	MonitorType string `json:"monitor_type" toml:"monitor_type" yaml:"monitor_type"`
+
+	// RequestHeaders are merged with the request headers of the monitors. A header set by a monitor takes precedence.
+	RequestHeaders map[string]string `json:"request_headers" toml:"request_headers" yaml:"request_headers"`
+
+	// TLSConfig fills every TLS option the monitors do not set. SkipTLSVerify can only be enabled this way.
+	TLSConfig TLSConfig `json:"tls_config" toml:"tls_config" yaml:"tls_config"`
+
+	// Interval specifies how often the monitors perform their checks. Defaults to 30 seconds.
+	Interval Duration `json:"interval" toml:"interval" yaml:"interval"`
+
+	// Timeout is the maximum duration of a single check. Defaults to roselite.DefaultMonitorTimeout, or the interval if
+	// shorter.
+	Timeout Duration `json:"timeout" toml:"timeout" yaml:"timeout"`
+
+	// Tags are added to the tags of the monitors.
+	Tags []string `json:"tags" toml:"tags" yaml:"tags"`
+}
+
+// WithTemplate returns the monitor with the options it does not set taken from the template. Request headers are
+// merged, and tags are combined.
+func (m Monitor) WithTemplate(template MonitorTemplate) Monitor {
+	if m.MonitorType == "" {
+		m.MonitorType = template.MonitorType
+	}
+
+	if len(template.RequestHeaders) > 0 {
+		requestHeaders := maps.Clone(template.RequestHeaders)
+		maps.Copy(requestHeaders, m.RequestHeaders)
+		m.RequestHeaders = requestHeaders
+	}
+
+	m.TLSConfig = m.TLSConfig.withTemplate(template.TLSConfig)
+
+	if m.Interval == 0 {
+		m.Interval = template.Interval
+	}
+
+	if m.Timeout == 0 {
+		m.Timeout = template.Timeout
+	}
+
+	if len(template.Tags) > 0 {
+		tags := slices.Clone(template.Tags)
+		for _, tag := range m.Tags {
+			if !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+		m.Tags = tags
+	}
+
+	return m
+}
+
+func (t TLSConfig) withTemplate(template TLSConfig) TLSConfig {
+	if t.CertificateAuthorityFile == "" {
+		t.CertificateAuthorityFile = template.CertificateAuthorityFile
+	}
+
+	// The certificate and its private key always come from the same place.
+	if t.CertificateFile == "" && t.PrivateKeyFile == "" {
+		t.CertificateFile = template.CertificateFile
+		t.PrivateKeyFile = template.PrivateKeyFile
+	}
+
+	t.SkipTLSVerify = t.SkipTLSVerify || template.SkipTLSVerify
+	return t
+}
+
+// ResolveMonitor returns the monitor with its template and the defaults applied, in that order of precedence.
+// An unknown template is ignored here, and reported by Validate.
+func (c Configuration) ResolveMonitor(monitor Monitor) Monitor {
+	if template, ok := c.Templates[monitor.Template]; ok && monitor.Template != "" {
+		monitor = monitor.WithTemplate(template)
+	}
+
+	return monitor.WithTemplate(c.Defaults)
 }
 
 // ToRoseliteMonitor converts a Monitor instance to a roselite.Monitor, applying necessary transformations and defaults.
@@ -145,20 +374,22 @@ func (m Monitor) ToRoseliteMonitor() roselite.Monitor {
 		slog.Warn(fmt.Sprintf("invalid TLS config: %s", err))
 	}
 
-	var interval = time.Duration(m.Interval) * time.Second
+	var interval = m.Interval.Duration()
 	if interval <= 0 {
-		interval = time.Second * 30
+		interval = roselite.DefaultMonitorInterval
 	}
 
 	return roselite.Monitor{
-		ID:                   m.MonitorTarget,
+		ID:                   m.Id,
 		MonitorType:          monitorType,
 		PushURL:              m.PushURL,
 		MonitorTarget:        m.MonitorTarget,
 		RequestHeaders:       m.RequestHeaders,
 		TLSConfig:            tlsConfig,
 		Interval:             interval,
+		Timeout:              m.Timeout.Duration(),
 		EnableSentrySampling: false,
+		Tags:                 m.Tags,
 	}
 }
 
@@ -175,6 +406,47 @@ type Configuration struct {
 	// Region is the region identifier for the monitor.
 	Region string `json:"region" toml:"region" yaml:"region"`
 
+	// AgentId identifies this agent to the relays and the upstream instance, defaults to the hostname.
+	AgentId string `json:"agent_id" toml:"agent_id" yaml:"agent_id"`
+
+	// Registration registers this agent with a roselite server, which reports it once it goes silent.
+	Registration RegistrationConfig `json:"registration" toml:"registration" yaml:"registration"`
+
+	// Self pushes the health of roselite itself to a dedicated upstream monitor.
+	Self SelfConfig `json:"self" toml:"self" yaml:"self"`
+
+	// Maintenance holds the recurring maintenance windows and the ad-hoc silences, during which monitors are skipped or
+	// pushed with the maintenance status.
+	Maintenance MaintenanceConfig `json:"maintenance" toml:"maintenance" yaml:"maintenance"`
+
+	// Defaults holds the options used by every monitor that does not set them, nor gets them from its template.
+	Defaults MonitorTemplate `json:"defaults" toml:"defaults" yaml:"defaults"`
+
+	// Templates holds named sets of options, referenced by a monitor with its template option.
+	Templates map[string]MonitorTemplate `json:"templates" toml:"templates" yaml:"templates"`
+
 	// Monitors defines a list of monitoring configurations, specifying individual monitor properties and settings.
 	Monitors []Monitor `json:"monitors" toml:"monitors" yaml:"monitors"`
+
+	// Discovery holds the dynamic sources the agent discovers more monitors from.
+	Discovery DiscoveryConfig `json:"discovery" toml:"discovery" yaml:"discovery"`
+
+	// Include lists files, directories or glob patterns whose monitors are appended to Monitors. Relative paths are
+	// resolved from the directory of the configuration file.
+	Include []string `json:"include" toml:"include" yaml:"include"`
+
+	// sources are the files and directories the configuration is loaded from.
+	sources []string
+	// secrets are the values resolved from ${...} references, redacted wherever the configuration is logged.
+	secrets []string
+}
+
+// ToRoseliteMonitors converts every configured monitor to a roselite.Monitor, applying the templates and the defaults.
+func (c Configuration) ToRoseliteMonitors() []roselite.Monitor {
+	monitors := make([]roselite.Monitor, len(c.Monitors))
+	for i, monitor := range c.Monitors {
+		monitors[i] = c.ResolveMonitor(monitor).ToRoseliteMonitor()
+	}
+
+	return monitors
 }
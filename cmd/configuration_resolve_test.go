@@ -0,0 +1,153 @@
+package main_test
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	main "github.com/teknologi-umum/roselite/cmd"
+)
+
+func TestConfiguration_ResolveMonitor(t *testing.T) {
+	configuration := main.Configuration{
+		Defaults: main.MonitorTemplate{
+			MonitorType:    "HTTP",
+			RequestHeaders: map[string]string{"User-Agent": "roselite", "X-Team": "platform"},
+			Interval:       main.Duration(time.Minute),
+			Timeout:        main.Duration(time.Second * 10),
+			Tags:           []string{"production"},
+		},
+		Templates: map[string]main.MonitorTemplate{
+			"internal-https": {
+				RequestHeaders: map[string]string{"Authorization": "Bearer internal", "X-Team": "payments"},
+				TLSConfig: main.TLSConfig{
+					CertificateAuthorityFile: "/etc/ssl/internal-ca.pem",
+					CertificateFile:          "/etc/ssl/roselite.crt",
+					PrivateKeyFile:           "/etc/ssl/roselite.key",
+				},
+				Interval: main.Duration(time.Second * 30),
+				Tags:     []string{"internal"},
+			},
+		},
+	}
+
+	t.Run("Template and defaults", func(t *testing.T) {
+		monitor := configuration.ResolveMonitor(main.Monitor{
+			Id:             "billing",
+			MonitorTarget:  "https://billing.internal/healthz",
+			RequestHeaders: map[string]string{"Authorization": "Bearer billing"},
+			TLSConfig:      main.TLSConfig{CertificateAuthorityFile: "/etc/ssl/billing-ca.pem"},
+			Tags:           []string{"billing", "internal"},
+			Template:       "internal-https",
+		})
+
+		expectedHeaders := map[string]string{"User-Agent": "roselite", "X-Team": "payments", "Authorization": "Bearer billing"}
+		if !maps.Equal(monitor.RequestHeaders, expectedHeaders) {
+			t.Errorf("expected request headers %v, got %v", expectedHeaders, monitor.RequestHeaders)
+		}
+		if monitor.MonitorType != "HTTP" {
+			t.Errorf("expected monitor type HTTP, got %s", monitor.MonitorType)
+		}
+		if monitor.TLSConfig.CertificateAuthorityFile != "/etc/ssl/billing-ca.pem" || monitor.TLSConfig.CertificateFile != "/etc/ssl/roselite.crt" {
+			t.Errorf("unexpected TLS config: %+v", monitor.TLSConfig)
+		}
+		if monitor.Interval.Duration() != time.Second*30 || monitor.Timeout.Duration() != time.Second*10 {
+			t.Errorf("unexpected interval %s and timeout %s", monitor.Interval, monitor.Timeout)
+		}
+		if expectedTags := []string{"production", "internal", "billing"}; !slices.Equal(monitor.Tags, expectedTags) {
+			t.Errorf("expected tags %v, got %v", expectedTags, monitor.Tags)
+		}
+	})
+
+	t.Run("Defaults only", func(t *testing.T) {
+		monitor := configuration.ResolveMonitor(main.Monitor{
+			Id:            "blog",
+			MonitorTarget: "https://blog.teknologiumum.com",
+			Interval:      main.Duration(time.Minute * 5),
+		})
+
+		if !maps.Equal(monitor.RequestHeaders, configuration.Defaults.RequestHeaders) {
+			t.Errorf("expected request headers %v, got %v", configuration.Defaults.RequestHeaders, monitor.RequestHeaders)
+		}
+		if monitor.Interval.Duration() != time.Minute*5 {
+			t.Errorf("expected interval 5m, got %s", monitor.Interval)
+		}
+		if monitor.TLSConfig.CertificateFile != "" {
+			t.Errorf("expected no certificate, got %s", monitor.TLSConfig.CertificateFile)
+		}
+	})
+
+	t.Run("Defaults are not modified", func(t *testing.T) {
+		_ = configuration.ResolveMonitor(main.Monitor{Id: "a", RequestHeaders: map[string]string{"X-Team": "a"}, Tags: []string{"a"}})
+
+		if configuration.Defaults.RequestHeaders["X-Team"] != "platform" || len(configuration.Defaults.Tags) != 1 {
+			t.Errorf("expected the defaults to be left as is, got %+v", configuration.Defaults)
+		}
+	})
+
+	t.Run("Unknown template", func(t *testing.T) {
+		configuration := configuration
+		configuration.Templates = nil
+		configuration.UpstreamConfig.BaseUrl = "https://kuma.example.com"
+		configuration.Monitors = []main.Monitor{{Id: "a", MonitorTarget: "https://example.com", Template: "missing"}}
+
+		problems := configuration.Validate()
+		if len(problems) != 1 || problems[0].Location != `monitors[0] (id "a").template` {
+			t.Errorf("expected an unknown template problem, got %v", problems)
+		}
+	})
+}
+
+func TestMonitor_ToRoseliteMonitor(t *testing.T) {
+	// The ID is the push token the heartbeats of the monitor are pushed to, not the checked target.
+	monitor := main.Monitor{
+		Id:            "Eq15E23yc3",
+		MonitorType:   "HTTP",
+		MonitorTarget: "https://github.com/healthz",
+		Interval:      main.Duration(time.Minute),
+	}.ToRoseliteMonitor()
+
+	if monitor.ID != "Eq15E23yc3" {
+		t.Errorf("expected the monitor to be pushed to its ID, got %q", monitor.ID)
+	}
+	if monitor.MonitorTarget != "https://github.com/healthz" {
+		t.Errorf("unexpected target: %q", monitor.MonitorTarget)
+	}
+}
+
+func TestConfiguration_DefaultCheckTimeout(t *testing.T) {
+	// Configurations written before the timeout setting allowed every check 5 minutes, unless the interval is shorter.
+	path := filepath.Join(t.TempDir(), "conf.toml")
+	err := os.WriteFile(path, []byte(`
+[[monitors]]
+id = "slow"
+monitor_type = "HTTP"
+monitor_target = "https://example.com/export"
+interval = "10m"
+
+[[monitors]]
+id = "fast"
+monitor_type = "HTTP"
+monitor_target = "https://example.com/healthz"
+interval = "30s"
+`), 0o600)
+	if err != nil {
+		t.Fatalf("failed to write configuration: %v", err)
+	}
+
+	configuration, err := main.LoadConfiguration(path, "")
+	if err != nil {
+		t.Fatalf("failed to load configuration: %v", err)
+	}
+
+	monitors := configuration.ToRoseliteMonitors()
+	if timeout := monitors[0].CheckTimeout(); timeout != time.Minute*5 {
+		t.Errorf("expected the slow monitor to time out after 5m, got %s", timeout)
+	}
+	if timeout := monitors[1].CheckTimeout(); timeout != time.Second*30 {
+		t.Errorf("expected the fast monitor to time out after its interval, got %s", timeout)
+	}
+}
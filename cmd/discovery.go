@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/teknologi-umum/roselite"
+)
+
+// DiscoveryConfig holds the dynamic sources the agent discovers monitors from, besides the configuration file.
+// Discovered monitors take their unset options from Configuration.Defaults. Changes to this block require a restart.
+type DiscoveryConfig struct {
+	// Docker discovers monitors from the labels of the running containers.
+	Docker DockerDiscoveryConfig `json:"docker" toml:"docker" yaml:"docker"`
+
+	// Kubernetes discovers monitors from the annotations of the services and the pods of a cluster.
+	Kubernetes KubernetesDiscoveryConfig `json:"kubernetes" toml:"kubernetes" yaml:"kubernetes"`
+
+	// FileSD discovers monitors from target files in the format of Prometheus' file_sd.
+	FileSD FileSDDiscoveryConfig `json:"file_sd" toml:"file_sd" yaml:"file_sd"`
+
+	// Server receives the monitors of the agent from a roselite server with the distribution enabled.
+	Server ServerDiscoveryConfig `json:"server" toml:"server" yaml:"server"`
+}
+
+// DockerDiscoveryConfig configures the discovery of monitors from the labels of the running containers.
+type DockerDiscoveryConfig struct {
+	// Enabled turns the discovery on.
+	Enabled bool `json:"enabled" toml:"enabled" yaml:"enabled"`
+
+	// Host is the unix socket of the Docker Engine API, such as unix:///var/run/docker.sock, which is the default.
+	Host string `json:"host" toml:"host" yaml:"host" env:"DOCKER_HOST"`
+
+	// Network is the container network whose IP address replaces {{ip}}. Defaults to the first network.
+	Network string `json:"network" toml:"network" yaml:"network"`
+
+	// LabelPrefix is the prefix of the container labels, defaults to "roselite".
+	LabelPrefix string `json:"label_prefix" toml:"label_prefix" yaml:"label_prefix"`
+}
+
+// KubernetesDiscoveryConfig configures the discovery of monitors from the roselite.teknologiumum.com/* annotations of
+// the services and the pods of a cluster.
+type KubernetesDiscoveryConfig struct {
+	// Enabled turns the discovery on.
+	Enabled bool `json:"enabled" toml:"enabled" yaml:"enabled"`
+
+	// Kubeconfig is the path to a kubeconfig file. Defaults to the service account of the pod when running in a
+	// cluster, then to $KUBECONFIG and ~/.kube/config.
+	Kubeconfig string `json:"kubeconfig" toml:"kubeconfig" yaml:"kubeconfig"`
+
+	// Context is the kubeconfig context to use, defaults to the current context.
+	Context string `json:"context" toml:"context" yaml:"context"`
+
+	// Namespace limits the discovery to a namespace, defaults to every namespace.
+	Namespace string `json:"namespace" toml:"namespace" yaml:"namespace"`
+}
+
+// FileSDDiscoveryConfig configures the discovery of monitors from the __roselite_* labels of target files in the
+// format of Prometheus' file_sd. The discovery is enabled when Files is set.
+type FileSDDiscoveryConfig struct {
+	// Files are the paths or the glob patterns of the JSON or YAML target files. Relative paths are relative to the
+	// directory of the configuration file.
+	Files []string `json:"files" toml:"files" yaml:"files"`
+
+	// RefreshInterval is how often the files are checked for modifications, defaults to 30 seconds.
+	RefreshInterval Duration `json:"refresh_interval" toml:"refresh_interval" yaml:"refresh_interval"`
+}
+
+// ServerDiscoveryConfig configures the monitors received from a roselite server, selected by Configuration.Region
+// and Configuration.AgentId. The upstream TLS config is used to reach the server.
+type ServerDiscoveryConfig struct {
+	// Enabled turns the discovery on.
+	Enabled bool `json:"enabled" toml:"enabled" yaml:"enabled" env:"SERVER_DISCOVERY_ENABLED"`
+
+	// Url is the base URL of the server, defaults to UpstreamConfig.BaseUrl.
+	Url string `json:"url" toml:"url" yaml:"url" env:"SERVER_DISCOVERY_URL"`
+
+	// Token is one of the tokens of the server's distribution.
+	Token string `json:"token" toml:"token" yaml:"token" env:"SERVER_DISCOVERY_TOKEN"`
+
+	// CacheFile keeps the last monitors received, which are checked on the next start until the server is reachable.
+	CacheFile string `json:"cache_file" toml:"cache_file" yaml:"cache_file" env:"SERVER_DISCOVERY_CACHE_FILE"`
+
+	// Wait is how long the server holds a request if the monitors did not change, defaults to 30 seconds.
+	Wait Duration `json:"wait" toml:"wait" yaml:"wait"`
+}
+
+// resolvePaths makes the relative paths of the target files relative to the directory of the configuration file.
+func (f *FileSDDiscoveryConfig) resolvePaths(configurationPath string) {
+	for i, file := range f.Files {
+		if !filepath.IsAbs(file) {
+			f.Files[i] = filepath.Join(filepath.Dir(configurationPath), file)
+		}
+	}
+}
+
+// Discoverers returns the enabled discovery sources.
+func (c Configuration) Discoverers() []roselite.Discoverer {
+	defaults := c.discoveryDefaults()
+
+	var discoverers []roselite.Discoverer
+	if c.Discovery.Docker.Enabled {
+		discoverers = append(discoverers, &roselite.DockerDiscovery{
+			Host:        c.Discovery.Docker.Host,
+			Network:     c.Discovery.Docker.Network,
+			LabelPrefix: c.Discovery.Docker.LabelPrefix,
+			Defaults:    defaults,
+		})
+	}
+
+	if c.Discovery.Kubernetes.Enabled {
+		discoverers = append(discoverers, &roselite.KubernetesDiscovery{
+			Kubeconfig: c.Discovery.Kubernetes.Kubeconfig,
+			Context:    c.Discovery.Kubernetes.Context,
+			Namespace:  c.Discovery.Kubernetes.Namespace,
+			Defaults:   defaults,
+		})
+	}
+
+	if c.Discovery.Server.Enabled {
+		serverUrl := c.Discovery.Server.Url
+		if serverUrl == "" {
+			serverUrl = c.UpstreamConfig.BaseUrl
+		}
+
+		tlsConfig, err := c.UpstreamConfig.TLSConfig.ToTLSConfig()
+		if err != nil {
+			slog.Warn(fmt.Sprintf("invalid TLS config: %s", err))
+		}
+
+		discoverers = append(discoverers, &roselite.ServerDiscovery{
+			ServerAddress:   serverUrl,
+			Token:           c.Discovery.Server.Token,
+			TLSConfig:       tlsConfig,
+			Region:          c.Region,
+			AgentIdentifier: c.AgentId,
+			Wait:            c.Discovery.Server.Wait.Duration(),
+			CacheFile:       c.Discovery.Server.CacheFile,
+			Decode:          c.decodeDistributedMonitor,
+		})
+	}
+
+	if len(c.Discovery.FileSD.Files) > 0 {
+		discoverers = append(discoverers, &roselite.FileDiscovery{
+			Files:           c.Discovery.FileSD.Files,
+			RefreshInterval: c.Discovery.FileSD.RefreshInterval.Duration(),
+			Defaults:        defaults,
+		})
+	}
+
+	return discoverers
+}
+
+// discoveryDefaults returns the options of the discovered monitors that their source does not set.
+func (c Configuration) discoveryDefaults() roselite.Monitor {
+	defaults := c.ResolveMonitor(Monitor{})
+	if defaults.MonitorType == "" {
+		defaults.MonitorType = roselite.MonitorTypeHTTP.String()
+	}
+
+	return defaults.ToRoseliteMonitor()
+}
+
+func (c Configuration) validateDiscovery(problems *ConfigurationProblems) {
+	d := c.Discovery
+
+	if d.Docker.Enabled && d.Docker.Host != "" {
+		if host, ok := strings.CutPrefix(d.Docker.Host, "unix://"); !ok && strings.Contains(host, "://") {
+			problems.add("discovery.docker.host", "%q is not a unix socket, only unix sockets are supported", d.Docker.Host)
+		}
+	}
+
+	if d.Kubernetes.Enabled && d.Kubernetes.Kubeconfig != "" {
+		if _, err := os.Stat(d.Kubernetes.Kubeconfig); err != nil {
+			problems.add("discovery.kubernetes.kubeconfig", "%s", err)
+		}
+	}
+
+	for i, file := range d.FileSD.Files {
+		if _, err := filepath.Glob(file); err != nil {
+			problems.add(fmt.Sprintf("discovery.file_sd.files[%d]", i), "%q is not a valid pattern: %s", file, err)
+		}
+	}
+
+	if d.FileSD.RefreshInterval.Duration() < 0 {
+		problems.add("discovery.file_sd.refresh_interval", "must not be negative")
+	}
+
+	if d.Server.Enabled {
+		switch {
+		case d.Server.Url != "":
+			if err := validateHttpUrl(d.Server.Url); err != nil {
+				problems.add("discovery.server.url", "%s", err)
+			}
+		case c.UpstreamConfig.BaseUrl == "":
+			problems.add("discovery.server.url", "missing, and upstream.base_url is not set either")
+		}
+
+		if d.Server.Wait.Duration() < 0 {
+			problems.add("discovery.server.wait", "must not be negative")
+		}
+	}
+}
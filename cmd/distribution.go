@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/teknologi-umum/roselite"
+)
+
+// DistributionConfig configures the distribution of the monitors of this configuration to the agents. An agent
+// receives the monitors whose regions and agents select it, and checks them along with its own.
+type DistributionConfig struct {
+	// Enabled serves the monitors on the /api/agent/monitors endpoint.
+	Enabled bool `json:"enabled" toml:"enabled" yaml:"enabled" env:"DISTRIBUTION_ENABLED"`
+
+	// Tokens are the bearer tokens the agents authenticate with. The monitors include their request headers, so
+	// leaving it empty lets any client read them.
+	Tokens []string `json:"tokens" toml:"tokens" yaml:"tokens"`
+}
+
+// MonitorDistribution returns the monitors handed out to the agents, with their templates and the defaults applied,
+// or nil if the distribution is disabled. The agents would look for the TLS files on their own host, so a monitor
+// using them is an error.
+func (c Configuration) MonitorDistribution() (*roselite.MonitorDistribution, error) {
+	if !c.ServerConfig.Distribution.Enabled {
+		return nil, nil
+	}
+
+	distribution := &roselite.MonitorDistribution{
+		Monitors: make([]roselite.DistributedMonitor, 0, len(c.Monitors)),
+		Tokens:   c.ServerConfig.Distribution.Tokens,
+	}
+	for _, monitor := range c.Monitors {
+		resolved := c.ResolveMonitor(monitor)
+		resolved.Template = ""
+		if resolved.TLSConfig.hasFiles() {
+			return nil, fmt.Errorf("monitor %q: TLS files are on this host, they cannot be distributed to the agents", monitor.Id)
+		}
+
+		definition, err := json.Marshal(resolved)
+		if err != nil {
+			return nil, fmt.Errorf("encoding monitor %q: %w", monitor.Id, err)
+		}
+
+		distribution.Monitors = append(distribution.Monitors, roselite.DistributedMonitor{
+			AgentSelector: roselite.AgentSelector{Regions: monitor.Regions, Agents: monitor.Agents},
+			Definition:    definition,
+		})
+	}
+
+	return distribution, nil
+}
+
+// AgentMonitors returns the monitors checked by this agent, which are the configured monitors that select its region
+// and its identifier.
+func (c Configuration) AgentMonitors() []roselite.Monitor {
+	region, agent := c.agentIdentity()
+
+	var monitors []roselite.Monitor
+	for _, monitor := range c.Monitors {
+		selector := roselite.AgentSelector{Regions: monitor.Regions, Agents: monitor.Agents}
+		if selector.Selects(region, agent) {
+			monitors = append(monitors, c.ResolveMonitor(monitor).ToRoseliteMonitor())
+		}
+	}
+
+	return monitors
+}
+
+// agentIdentity returns the region and the identifier of this agent, with the same defaults as roselite.NewAgent.
+func (c Configuration) agentIdentity() (string, string) {
+	region := c.Region
+	if region == "" {
+		region = "default"
+	}
+
+	agent := c.AgentId
+	if agent == "" {
+		hostname, err := os.Hostname()
+		if err != nil || hostname == "" {
+			hostname = "roselite"
+		}
+		agent = hostname
+	}
+
+	return region, agent
+}
+
+// decodeDistributedMonitor converts a monitor handed out by the server. The options the server leaves unset are taken
+// from the defaults of this configuration.
+func (c Configuration) decodeDistributedMonitor(definition json.RawMessage) (roselite.Monitor, error) {
+	var monitor Monitor
+	if err := json.Unmarshal(definition, &monitor); err != nil {
+		return roselite.Monitor{}, err
+	}
+
+	resolved := c.ResolveMonitor(monitor)
+	var problems ConfigurationProblems
+	resolved.validate(fmt.Sprintf("monitor %q", monitor.Id), &problems)
+	if err := problems.Err(); err != nil {
+		return roselite.Monitor{}, err
+	}
+
+	return resolved.ToRoseliteMonitor(), nil
+}
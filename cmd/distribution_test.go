@@ -0,0 +1,77 @@
+package main_test
+
+import (
+	"encoding/json"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	main "github.com/teknologi-umum/roselite/cmd"
+)
+
+func TestConfiguration_MonitorDistribution(t *testing.T) {
+	configuration := main.Configuration{
+		ServerConfig: main.ServerConfig{
+			Distribution: main.DistributionConfig{Enabled: true, Tokens: []string{"agent-token"}},
+		},
+		Region:  "jakarta",
+		AgentId: "relay",
+		Defaults: main.MonitorTemplate{
+			MonitorType: "HTTP",
+			Interval:    main.Duration(time.Minute),
+		},
+		Templates: map[string]main.MonitorTemplate{
+			"internal": {RequestHeaders: map[string]string{"Authorization": "Bearer internal"}},
+		},
+		Monitors: []main.Monitor{
+			{Id: "everywhere", MonitorTarget: "https://example.com", Template: "internal"},
+			{Id: "jakarta", MonitorTarget: "https://jakarta.example.com", Regions: []string{"jakarta"}},
+			{Id: "edge", MonitorTarget: "https://edge.example.com", Agents: []string{"edge-1", "edge-2"}},
+		},
+	}
+
+	distribution, err := configuration.MonitorDistribution()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(distribution.Tokens, []string{"agent-token"}) {
+		t.Errorf("expected the configured tokens, got %v", distribution.Tokens)
+	}
+	if len(distribution.Monitors) != 3 {
+		t.Fatalf("expected 3 distributed monitors, got %d", len(distribution.Monitors))
+	}
+
+	var everywhere main.Monitor
+	if err := json.Unmarshal(distribution.Monitors[0].Definition, &everywhere); err != nil {
+		t.Fatalf("failed to decode the definition: %v", err)
+	}
+	if everywhere.Template != "" || everywhere.Interval != main.Duration(time.Minute) ||
+		!maps.Equal(everywhere.RequestHeaders, map[string]string{"Authorization": "Bearer internal"}) {
+		t.Errorf("expected the monitor to be distributed with its template and the defaults applied, got %+v", everywhere)
+	}
+
+	if selector := distribution.Monitors[2].AgentSelector; !selector.Selects("surabaya", "edge-2") || selector.Selects("surabaya", "edge-3") {
+		t.Errorf("unexpected agent selector %+v", selector)
+	}
+
+	// The local agent only checks the monitors selecting it as well.
+	var ids []string
+	for _, monitor := range configuration.AgentMonitors() {
+		ids = append(ids, monitor.ID)
+	}
+	if expected := []string{"everywhere", "jakarta"}; !slices.Equal(ids, expected) {
+		t.Errorf("expected the agent to check %v, got %v", expected, ids)
+	}
+
+	// The agents would look for the files on their own host.
+	configuration.Templates["internal"] = main.MonitorTemplate{TLSConfig: main.TLSConfig{CertificateAuthorityFile: "/etc/ssl/internal-ca.pem"}}
+	if _, err := configuration.MonitorDistribution(); err == nil {
+		t.Error("expected an error for a distributed monitor using TLS files")
+	}
+
+	configuration.ServerConfig.Distribution.Enabled = false
+	if distribution, _ := configuration.MonitorDistribution(); distribution != nil {
+		t.Error("expected no distribution once it is disabled")
+	}
+}
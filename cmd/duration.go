@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/teknologi-umum/roselite"
+)
+
+// Duration is a duration in the configuration file. It is written as a Go duration string such as "30s" or "2m",
+// or as an integer number of seconds for backward compatibility.
+type Duration time.Duration
+
+// parseDuration parses a Go duration string, or an integer number of seconds.
+func parseDuration(value string) (Duration, error) {
+	duration, err := roselite.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+
+	return Duration(duration), nil
+}
+
+func durationFromValue(value any) (Duration, error) {
+	switch value := value.(type) {
+	case string:
+		return parseDuration(value)
+	case int:
+		return Duration(time.Duration(value) * time.Second), nil
+	case int64:
+		return Duration(time.Duration(value) * time.Second), nil
+	case uint64:
+		return Duration(time.Duration(value) * time.Second), nil
+	case float64:
+		return Duration(value * float64(time.Second)), nil
+	default:
+		return 0, fmt.Errorf("invalid duration %v, expected a duration such as \"30s\" or a number of seconds", value)
+	}
+}
+
+// Duration returns the value as a time.Duration.
+func (d Duration) Duration() time.Duration {
+	return time.Duration(d)
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	parsed, err := durationFromValue(value)
+	if err != nil {
+		return err
+	}
+
+	*d = parsed
+	return nil
+}
+
+// UnmarshalTOML implements toml.Unmarshaler.
+func (d *Duration) UnmarshalTOML(value any) error {
+	parsed, err := durationFromValue(value)
+	if err != nil {
+		return err
+	}
+
+	*d = parsed
+	return nil
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface of gopkg.in/yaml.
+func (d *Duration) UnmarshalYAML(unmarshal func(any) error) error {
+	var value any
+	if err := unmarshal(&value); err != nil {
+		return err
+	}
+
+	parsed, err := durationFromValue(value)
+	if err != nil {
+		return err
+	}
+
+	*d = parsed
+	return nil
+}
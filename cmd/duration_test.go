@@ -0,0 +1,112 @@
+package main_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/configor"
+	main "github.com/teknologi-umum/roselite/cmd"
+)
+
+func TestDuration(t *testing.T) {
+	testCases := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{
+			name: "TOML",
+			file: "roselite.toml",
+			content: `[server.rate_limit]
+deduplication_window = "10s"
+
+[defaults]
+interval = "2m"
+timeout = 15
+
+[[monitors]]
+id = "seconds"
+interval = 30
+
+[[monitors]]
+id = "string"
+interval = "1m30s"
+timeout = "10s"
+`,
+		},
+		{
+			name: "YAML",
+			file: "roselite.yaml",
+			content: `server:
+  rate_limit:
+    deduplication_window: 10
+defaults:
+  interval: 2m
+  timeout: 15
+monitors:
+  - id: seconds
+    interval: 30
+  - id: string
+    interval: 1m30s
+    timeout: 10s
+`,
+		},
+		{
+			name: "JSON",
+			file: "roselite.json",
+			content: `{
+  "server": {"rate_limit": {"deduplication_window": "10s"}},
+  "defaults": {"interval": "2m", "timeout": 15},
+  "monitors": [
+    {"id": "seconds", "interval": 30},
+    {"id": "string", "interval": "1m30s", "timeout": "10s"}
+  ]
+}`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), testCase.file)
+			if err := os.WriteFile(path, []byte(testCase.content), 0o600); err != nil {
+				t.Fatalf("failed to write configuration: %v", err)
+			}
+
+			var configuration main.Configuration
+			if err := configor.New(&configor.Config{}).Load(&configuration, path); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			monitors := configuration.ToRoseliteMonitors()
+			if len(monitors) != 2 {
+				t.Fatalf("expected 2 monitors, got %d", len(monitors))
+			}
+
+			if monitors[0].Interval != time.Second*30 || monitors[0].Timeout != time.Second*15 {
+				t.Errorf("unexpected interval %s and timeout %s", monitors[0].Interval, monitors[0].Timeout)
+			}
+
+			if monitors[1].Interval != time.Second*90 || monitors[1].Timeout != time.Second*10 {
+				t.Errorf("unexpected interval %s and timeout %s", monitors[1].Interval, monitors[1].Timeout)
+			}
+
+			if window := configuration.ServerConfig.RateLimit.DeduplicationWindow.Duration(); window != time.Second*10 {
+				t.Errorf("unexpected deduplication window %s", window)
+			}
+		})
+	}
+
+	t.Run("Invalid duration", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "roselite.toml")
+		if err := os.WriteFile(path, []byte("[defaults]\ninterval = \"soon\"\n"), 0o600); err != nil {
+			t.Fatalf("failed to write configuration: %v", err)
+		}
+
+		var configuration main.Configuration
+		if err := configor.New(&configor.Config{}).Load(&configuration, path); err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+}
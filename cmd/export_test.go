@@ -0,0 +1,13 @@
+package main
+
+// LoadConfiguration loads the configuration file and its included monitors, for the tests of the main_test package.
+var LoadConfiguration = loadConfiguration
+
+// ConfigurationFingerprint returns the fingerprint of the sources of the configuration, which the reloader compares to
+// notice a modification.
+func ConfigurationFingerprint(configuration Configuration) string {
+	return (&configurationReloader{sources: configuration.sources}).currentFingerprint()
+}
+
+// RestartRequiredChanges returns the settings changed by a reload that only take effect on a restart.
+var RestartRequiredChanges = restartRequiredChanges
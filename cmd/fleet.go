@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/teknologi-umum/roselite"
+)
+
+// FleetConfig configures the tracking of the agents registered with the server.
+type FleetConfig struct {
+	// Enabled accepts the self-heartbeats of the agents, and lists them on /api/agents and /metrics.
+	Enabled bool `json:"enabled" toml:"enabled" yaml:"enabled" env:"FLEET_ENABLED"`
+
+	// Tokens are the bearer tokens the agents authenticate with, also required to list and remove the agents. Leaving
+	// it empty lets any client register an agent.
+	Tokens []string `json:"tokens" toml:"tokens" yaml:"tokens"`
+
+	// SilenceTimeout is how long an agent can go without a self-heartbeat before it is reported, defaults to three
+	// times the interval of the agent.
+	SilenceTimeout Duration `json:"silence_timeout" toml:"silence_timeout" yaml:"silence_timeout"`
+
+	// PushId is the push token of an upstream monitor for the whole fleet, pushed down while any agent is silent.
+	PushId string `json:"push_id" toml:"push_id" yaml:"push_id"`
+
+	// AgentPushIds maps an agent identifier to the push token of an upstream monitor for that agent alone.
+	AgentPushIds map[string]string `json:"agent_push_ids" toml:"agent_push_ids" yaml:"agent_push_ids"`
+
+	// PushInterval is how often the push IDs are pushed, defaults to 30 seconds.
+	PushInterval Duration `json:"push_interval" toml:"push_interval" yaml:"push_interval"`
+}
+
+// RegistrationConfig registers the agent with a roselite server that has the fleet enabled.
+type RegistrationConfig struct {
+	// Enabled sends the self-heartbeat of the agent to the server.
+	Enabled bool `json:"enabled" toml:"enabled" yaml:"enabled" env:"REGISTRATION_ENABLED"`
+
+	// Url is the base URL of the server, defaults to UpstreamConfig.BaseUrl.
+	Url string `json:"url" toml:"url" yaml:"url" env:"REGISTRATION_URL"`
+
+	// Token is one of the tokens of the server's fleet.
+	Token string `json:"token" toml:"token" yaml:"token" env:"REGISTRATION_TOKEN"`
+
+	// Interval is how often the self-heartbeat is sent, defaults to 30 seconds.
+	Interval Duration `json:"interval" toml:"interval" yaml:"interval"`
+}
+
+// FleetOptions returns the options of the fleet, or nil if it is disabled.
+func (c Configuration) FleetOptions() *roselite.FleetOptions {
+	if !c.ServerConfig.Fleet.Enabled {
+		return nil
+	}
+
+	return &roselite.FleetOptions{
+		Tokens:         c.ServerConfig.Fleet.Tokens,
+		SilenceTimeout: c.ServerConfig.Fleet.SilenceTimeout.Duration(),
+		PushID:         c.ServerConfig.Fleet.PushId,
+		AgentPushIDs:   c.ServerConfig.Fleet.AgentPushIds,
+		PushInterval:   c.ServerConfig.Fleet.PushInterval.Duration(),
+	}
+}
+
+// RegistrationOptions returns the options of the registration, using the upstream TLS config to reach the server.
+func (c Configuration) RegistrationOptions() roselite.RegistrationOptions {
+	serverUrl := c.Registration.Url
+	if serverUrl == "" {
+		serverUrl = c.UpstreamConfig.BaseUrl
+	}
+
+	tlsConfig, err := c.UpstreamConfig.TLSConfig.ToTLSConfig()
+	if err != nil {
+		slog.Warn(fmt.Sprintf("invalid TLS config: %s", err))
+	}
+
+	return roselite.RegistrationOptions{
+		ServerAddress: serverUrl,
+		Token:         c.Registration.Token,
+		TLSConfig:     tlsConfig,
+		Interval:      c.Registration.Interval.Duration(),
+	}
+}
+
+func (c Configuration) validateFleet(problems *ConfigurationProblems) {
+	fleet := c.ServerConfig.Fleet
+	if fleet.Enabled {
+		if len(fleet.Tokens) == 0 {
+			problems.warn("server.fleet.tokens", "missing, any client can register an agent")
+		}
+
+		if fleet.SilenceTimeout < 0 {
+			problems.add("server.fleet.silence_timeout", "must not be negative")
+		}
+
+		if fleet.PushInterval < 0 {
+			problems.add("server.fleet.push_interval", "must not be negative")
+		}
+
+		if (fleet.PushId != "" || len(fleet.AgentPushIds) > 0) && c.UpstreamConfig.BaseUrl == "" {
+			problems.add("server.fleet", "push IDs are set, but upstream.base_url is not")
+		}
+	}
+
+	if c.Registration.Enabled {
+		switch {
+		case c.Registration.Url != "":
+			if err := validateHttpUrl(c.Registration.Url); err != nil {
+				problems.add("registration.url", "%s", err)
+			}
+		case c.UpstreamConfig.BaseUrl == "":
+			problems.add("registration.url", "missing, and upstream.base_url is not set either")
+		}
+
+		if c.Registration.Interval < 0 {
+			problems.add("registration.interval", "must not be negative")
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ConfigurationStatus tracks the outcome of loading the configuration file, reported on the readiness endpoint.
+// A rejected reload does not make the instance unready, as the previous configuration is still running. The readiness
+// endpoint is not authenticated, so the reason of a rejected reload, which names monitor IDs and paths, is only logged.
+type ConfigurationStatus struct {
+	mutex      sync.Mutex
+	loadedAt   time.Time
+	rejectedAt time.Time
+}
+
+// NewConfigurationStatus creates a ConfigurationStatus for a configuration that was loaded successfully.
+func NewConfigurationStatus() *ConfigurationStatus {
+	return &ConfigurationStatus{loadedAt: time.Now()}
+}
+
+// Reloaded records a successful reload of the configuration.
+func (c *ConfigurationStatus) Reloaded() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.loadedAt = time.Now()
+	c.rejectedAt = time.Time{}
+}
+
+// ReloadFailed records a reload that was rejected.
+func (c *ConfigurationStatus) ReloadFailed() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.rejectedAt = time.Now()
+}
+
+// Check implements roselite.HealthCheck.
+func (c *ConfigurationStatus) Check(context.Context) (string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	message := fmt.Sprintf("loaded at %s", c.loadedAt.UTC().Format(time.RFC3339))
+	if !c.rejectedAt.IsZero() {
+		message += fmt.Sprintf(", last reload rejected at %s", c.rejectedAt.UTC().Format(time.RFC3339))
+	}
+
+	return message, nil
+}
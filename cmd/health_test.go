@@ -0,0 +1,27 @@
+package main_test
+
+import (
+	"strings"
+	"testing"
+
+	main "github.com/teknologi-umum/roselite/cmd"
+)
+
+func TestConfigurationStatus_Check(t *testing.T) {
+	status := main.NewConfigurationStatus()
+	status.ReloadFailed()
+
+	message, err := status.Check(t.Context())
+	if err != nil {
+		t.Fatalf("expected a rejected reload to keep the instance ready, got %v", err)
+	}
+	if !strings.Contains(message, "last reload rejected at") {
+		t.Errorf("expected the message to report the rejected reload, got %q", message)
+	}
+
+	status.Reloaded()
+	message, _ = status.Check(t.Context())
+	if strings.Contains(message, "rejected") {
+		t.Errorf("expected a successful reload to clear the rejection, got %q", message)
+	}
+}
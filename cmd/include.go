@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/jinzhu/configor"
+)
+
+// monitorFileExtensions are the extensions of the files loaded from a monitors directory.
+var monitorFileExtensions = []string{".toml", ".yaml", ".yml", ".json"}
+
+// monitorsFile is a file that only holds monitors, loaded through Configuration.Include or the monitors directory.
+type monitorsFile struct {
+	Monitors []Monitor `json:"monitors" toml:"monitors" yaml:"monitors"`
+}
+
+// includeMonitors appends the monitors of every included file, then of every file in the monitors directory, to the
+// monitors of the main configuration file. Relative include paths are resolved from the main configuration file.
+func (c *Configuration) includeMonitors(path string, monitorsDir string) error {
+	c.sources = append(c.sources, path)
+
+	var files []string
+	for _, include := range c.Include {
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(path), include)
+		}
+
+		matches, err := includedFiles(include)
+		if err != nil {
+			return err
+		}
+
+		if isGlobPattern(include) {
+			// The pattern itself can not be watched. A file created later that matches it changes the modification
+			// time of its directory instead.
+			directories := []string{globDirectory(include)}
+			for _, match := range matches {
+				directories = append(directories, filepath.Dir(match))
+			}
+
+			for _, dir := range directories {
+				if !slices.Contains(c.sources, dir) {
+					c.sources = append(c.sources, dir)
+				}
+			}
+		} else {
+			c.sources = append(c.sources, include)
+		}
+		files = append(files, matches...)
+	}
+
+	if monitorsDir != "" {
+		matches, err := monitorFilesIn(monitorsDir)
+		if err != nil {
+			return err
+		}
+
+		c.sources = append(c.sources, monitorsDir)
+		files = append(files, matches...)
+	}
+
+	for _, file := range files {
+		var included monitorsFile
+		if err := configor.New(&configor.Config{}).Load(&included, file); err != nil {
+			return fmt.Errorf("loading monitors from %s: %w", file, err)
+		}
+
+		for i, monitor := range included.Monitors {
+			monitor.location = fmt.Sprintf("%s: monitors[%d]", file, i)
+			c.Monitors = append(c.Monitors, monitor)
+		}
+		c.sources = append(c.sources, file)
+	}
+
+	return nil
+}
+
+// includedFiles returns the files an include entry points to. The entry is either a file, a directory whose monitor
+// files are all included, or a glob pattern.
+func includedFiles(include string) ([]string, error) {
+	if isGlobPattern(include) {
+		matches, err := filepath.Glob(include)
+		if err != nil {
+			return nil, fmt.Errorf("include %s: %w", include, err)
+		}
+
+		return matches, nil
+	}
+
+	info, err := os.Stat(include)
+	if err != nil {
+		return nil, fmt.Errorf("include %s: %w", include, err)
+	}
+
+	if info.IsDir() {
+		return monitorFilesIn(include)
+	}
+
+	return []string{include}, nil
+}
+
+func isGlobPattern(include string) bool {
+	return strings.ContainsAny(include, "*?[")
+}
+
+// globDirectory returns the deepest directory of the pattern without any glob metacharacter.
+func globDirectory(pattern string) string {
+	dir := filepath.Dir(pattern)
+	for isGlobPattern(dir) {
+		dir = filepath.Dir(dir)
+	}
+
+	return dir
+}
+
+// monitorFilesIn returns the TOML, YAML and JSON files in the directory, sorted by name. Subdirectories and hidden
+// files are skipped.
+func monitorFilesIn(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading monitors directory: %w", err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		if slices.Contains(monitorFileExtensions, strings.ToLower(filepath.Ext(entry.Name()))) {
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+
+	return files, nil
+}
@@ -0,0 +1,126 @@
+package main_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	main "github.com/teknologi-umum/roselite/cmd"
+	"github.com/urfave/cli/v3"
+)
+
+func TestValidateAction_Include(t *testing.T) {
+	writeFile := func(t *testing.T, path string, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+	}
+
+	run := func(configurationPath string, monitorsDir string) (string, error) {
+		var output bytes.Buffer
+		cmd := &cli.Command{
+			Name:   "roselite",
+			Writer: &output,
+			Flags:  []cli.Flag{&cli.StringFlag{Name: "config"}, &cli.StringFlag{Name: "monitors-dir"}},
+			Commands: []*cli.Command{
+				{Name: "validate", Action: main.ValidateAction},
+			},
+		}
+		err := cmd.Run(t.Context(), []string{"roselite", "--config", configurationPath, "--monitors-dir", monitorsDir, "validate"})
+		return output.String(), err
+	}
+
+	const mainConfiguration = `include = ["conf.d", "extra/*.yaml"]
+
+[upstream]
+base_url = "https://kuma.example.com"
+
+[[monitors]]
+id = "main"
+monitor_type = "HTTP"
+monitor_target = "https://example.com"
+`
+
+	t.Run("Monitors from every source", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, "roselite.toml"), mainConfiguration)
+		writeFile(t, filepath.Join(dir, "conf.d", "team-a.toml"), "[[monitors]]\nid = \"team-a\"\nmonitor_type = \"HTTP\"\nmonitor_target = \"https://a.example.com\"\n")
+		writeFile(t, filepath.Join(dir, "conf.d", "README.md"), "not a monitor file")
+		writeFile(t, filepath.Join(dir, "extra", "team-b.yaml"), "monitors:\n  - id: team-b\n    monitor_type: ICMP\n    monitor_target: b.example.com\n")
+		writeFile(t, filepath.Join(dir, "monitors", "team-c.json"), `{"monitors": [{"id": "team-c", "monitor_type": "HTTP", "monitor_target": "https://c.example.com"}]}`)
+
+		output, err := run(filepath.Join(dir, "roselite.toml"), filepath.Join(dir, "monitors"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v\n%s", err, output)
+		}
+		if !strings.Contains(output, "4 monitors") {
+			t.Errorf("expected 4 monitors, got %q", output)
+		}
+	})
+
+	t.Run("Duplicate across files", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, "roselite.toml"), mainConfiguration)
+		writeFile(t, filepath.Join(dir, "conf.d", "team-a.toml"), "[[monitors]]\nid = \"main\"\nmonitor_type = \"HTTP\"\nmonitor_target = \"https://a.example.com\"\n")
+
+		output, err := run(filepath.Join(dir, "roselite.toml"), "")
+		if err == nil {
+			t.Fatalf("expected an error, got nil\n%s", output)
+		}
+		expected := filepath.Join(dir, "conf.d", "team-a.toml") + `: monitors[0] (id "main").id: duplicate of monitors[0]`
+		if !strings.Contains(output, expected) {
+			t.Errorf("expected %q in output, got %q", expected, output)
+		}
+	})
+
+	t.Run("Missing include", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, "roselite.toml"), mainConfiguration)
+
+		output, err := run(filepath.Join(dir, "roselite.toml"), "")
+		if err == nil {
+			t.Fatalf("expected an error, got nil\n%s", output)
+		}
+		if !strings.Contains(err.Error(), "conf.d") {
+			t.Errorf("expected the error to mention conf.d, got %v", err)
+		}
+	})
+}
+
+func TestConfiguration_IncludeFingerprint(t *testing.T) {
+	dir := t.TempDir()
+	configurationPath := filepath.Join(dir, "roselite.toml")
+	if err := os.WriteFile(configurationPath, []byte("include = [\"extra/*.yaml\"]\n"), 0o600); err != nil {
+		t.Fatalf("failed to write configuration: %v", err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "extra"), 0o700); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+
+	configuration, err := main.LoadConfiguration(configurationPath, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fingerprint := main.ConfigurationFingerprint(configuration)
+
+	// No file matched the pattern when the configuration was loaded.
+	if err := os.WriteFile(filepath.Join(dir, "extra", "team-b.yaml"), []byte("monitors: []\n"), 0o600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	// Make sure the modification time differs on file systems with a coarse resolution.
+	future := time.Now().Add(time.Second)
+	if err := os.Chtimes(filepath.Join(dir, "extra"), future, future); err != nil {
+		t.Fatalf("failed to change the modification time: %v", err)
+	}
+
+	if main.ConfigurationFingerprint(configuration) == fingerprint {
+		t.Error("expected a new file matching the include pattern to change the fingerprint")
+	}
+}
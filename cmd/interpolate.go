@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// environmentVariableName matches the names accepted on a ${NAME} reference.
+var environmentVariableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// interpolate replaces the ${NAME} and ${file:/path} references on every string value of the configuration,
+// including the values of maps and slices, with the value of the environment variable or the content of the file.
+// Write $${ for a literal ${. The resolved values are kept on secrets, so they can be redacted.
+func (c *Configuration) interpolate() error {
+	return interpolateValue(reflect.ValueOf(c).Elem(), "", &c.secrets)
+}
+
+func interpolateValue(value reflect.Value, location string, secrets *[]string) error {
+	switch value.Kind() {
+	case reflect.String:
+		interpolated, resolved, err := interpolateString(value.String())
+		if err != nil {
+			return fmt.Errorf("%s: %w", location, err)
+		}
+
+		value.SetString(interpolated)
+		*secrets = append(*secrets, resolved...)
+	case reflect.Pointer:
+		if !value.IsNil() {
+			return interpolateValue(value.Elem(), location, secrets)
+		}
+	case reflect.Struct:
+		var errs []error
+		for i := range value.NumField() {
+			field := value.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+
+			name, _, _ := strings.Cut(field.Tag.Get("toml"), ",")
+			if name == "" {
+				name = field.Name
+			}
+			if location != "" {
+				name = location + "." + name
+			}
+
+			errs = append(errs, interpolateValue(value.Field(i), name, secrets))
+		}
+		return errors.Join(errs...)
+	case reflect.Slice:
+		var errs []error
+		for i := range value.Len() {
+			elementLocation := fmt.Sprintf("%s[%d]", location, i)
+			if monitor, ok := value.Index(i).Interface().(Monitor); ok && monitor.location != "" {
+				elementLocation = monitor.location
+			}
+
+			errs = append(errs, interpolateValue(value.Index(i), elementLocation, secrets))
+		}
+		return errors.Join(errs...)
+	case reflect.Map:
+		var errs []error
+		for _, key := range value.MapKeys() {
+			// Map values are not addressable, so the value is interpolated on a copy that replaces it.
+			element := reflect.New(value.Type().Elem()).Elem()
+			element.Set(value.MapIndex(key))
+			errs = append(errs, interpolateValue(element, fmt.Sprintf("%s.%v", location, key), secrets))
+			value.SetMapIndex(key, element)
+		}
+		return errors.Join(errs...)
+	}
+
+	return nil
+}
+
+// interpolateString resolves the references on a single value, returning the interpolated value and the values
+// the references resolved to.
+func interpolateString(value string) (string, []string, error) {
+	if !strings.Contains(value, "${") {
+		return value, nil, nil
+	}
+
+	var interpolated strings.Builder
+	var resolved []string
+	for {
+		start := strings.Index(value, "${")
+		if start < 0 {
+			interpolated.WriteString(value)
+			break
+		}
+
+		if start > 0 && value[start-1] == '$' {
+			interpolated.WriteString(value[:start-1] + "${")
+			value = value[start+2:]
+			continue
+		}
+
+		end := strings.IndexByte(value[start:], '}')
+		if end < 0 {
+			return "", nil, fmt.Errorf("unterminated reference %q, write $${ for a literal ${", value[start:])
+		}
+
+		reference := value[start+2 : start+end]
+		result, err := resolveReference(reference)
+		if err != nil {
+			return "", nil, fmt.Errorf("unresolved reference ${%s}: %w", reference, err)
+		}
+
+		interpolated.WriteString(value[:start] + result)
+		resolved = append(resolved, result)
+		value = value[start+end+1:]
+	}
+
+	return interpolated.String(), resolved, nil
+}
+
+func resolveReference(reference string) (string, error) {
+	if path, ok := strings.CutPrefix(reference, "file:"); ok {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+
+		// Secret files usually end with a newline that is not part of the secret.
+		return strings.TrimRight(string(content), "\r\n"), nil
+	}
+
+	if !environmentVariableName.MatchString(reference) {
+		return "", errors.New("expected an environment variable name or file:/path")
+	}
+
+	result, ok := os.LookupEnv(reference)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", reference)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,154 @@
+package main_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	main "github.com/teknologi-umum/roselite/cmd"
+	"github.com/urfave/cli/v3"
+)
+
+func TestConfiguration_Interpolation(t *testing.T) {
+	var receivedApiKey, receivedToken string
+	targetServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedApiKey = r.Header.Get("X-Api-Key")
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(targetServer.Close)
+
+	kumaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedToken = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(kumaServer.Close)
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "token"), []byte("Bearer upstream-secret-token\n"), 0o600); err != nil {
+		t.Fatalf("failed to write secret file: %v", err)
+	}
+	t.Setenv("ROSELITE_TEST_KUMA_URL", kumaServer.URL)
+	t.Setenv("ROSELITE_TEST_API_KEY", "monitor-secret-key")
+
+	writeConfiguration := func(t *testing.T, content string) string {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "roselite.toml")
+		content = strings.ReplaceAll(content, "TOKEN_FILE", filepath.Join(dir, "token"))
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("failed to write configuration: %v", err)
+		}
+		return path
+	}
+
+	run := func(configurationPath string, command string, args ...string) (string, error) {
+		var output bytes.Buffer
+		cmd := &cli.Command{
+			Name:   "roselite",
+			Writer: &output,
+			Flags:  []cli.Flag{&cli.StringFlag{Name: "config"}},
+			Commands: []*cli.Command{
+				{Name: "validate", Action: main.ValidateAction},
+				{
+					Name:   "check",
+					Action: main.CheckAction,
+					Flags: []cli.Flag{
+						&cli.StringFlag{Name: "output", Value: "table"},
+						&cli.BoolFlag{Name: "push"},
+						&cli.BoolFlag{Name: "dry-run"},
+					},
+				},
+			},
+		}
+		err := cmd.Run(t.Context(), append([]string{"roselite", "--config", configurationPath, command}, args...))
+		return output.String(), err
+	}
+
+	t.Run("Environment variables and files", func(t *testing.T) {
+		path := writeConfiguration(t, `[upstream]
+base_url = "${ROSELITE_TEST_KUMA_URL}"
+request_headers = { Authorization = "${file:TOKEN_FILE}" }
+
+[[monitors]]
+id = "api"
+monitor_type = "HTTP"
+monitor_target = "`+targetServer.URL+`/$${literal}?key=${ROSELITE_TEST_API_KEY}"
+request_headers = { X-Api-Key = "${ROSELITE_TEST_API_KEY}" }
+`)
+
+		output, err := run(path, "check", "--push", "--output", "json")
+		if err != nil {
+			t.Fatalf("unexpected error: %v\n%s", err, output)
+		}
+
+		if receivedApiKey != "monitor-secret-key" {
+			t.Errorf("expected the monitor header to be interpolated, got %q", receivedApiKey)
+		}
+		if receivedToken != "Bearer upstream-secret-token" {
+			t.Errorf("expected the upstream header to be interpolated, got %q", receivedToken)
+		}
+
+		var results []struct {
+			Target string `json:"monitor_target"`
+			Pushed bool   `json:"pushed"`
+		}
+		if err := json.Unmarshal([]byte(output), &results); err != nil {
+			t.Fatalf("failed to decode output: %v\n%s", err, output)
+		}
+		if len(results) != 1 || !results[0].Pushed {
+			t.Fatalf("expected the heartbeat to be pushed, got %+v", results)
+		}
+
+		expectedTarget := targetServer.URL + "/${literal}?key=[redacted]"
+		if results[0].Target != expectedTarget {
+			t.Errorf("expected target %q, got %q", expectedTarget, results[0].Target)
+		}
+		if strings.Contains(output, "monitor-secret-key") {
+			t.Errorf("expected the interpolated value to be redacted, got %s", output)
+		}
+	})
+
+	t.Run("Unresolved reference", func(t *testing.T) {
+		path := writeConfiguration(t, `[upstream]
+base_url = "https://kuma.example.com"
+request_headers = { Authorization = "${ROSELITE_TEST_UNSET}" }
+
+[[monitors]]
+id = "api"
+monitor_type = "HTTP"
+monitor_target = "https://example.com"
+request_headers = { X-Api-Key = "${file:/nonexistent/roselite/secret}" }
+`)
+
+		_, err := run(path, "validate")
+		if err == nil {
+			t.Fatal("expected an error, got nil")
+		}
+
+		for _, expected := range []string{
+			"upstream.request_headers.Authorization: unresolved reference ${ROSELITE_TEST_UNSET}: environment variable ROSELITE_TEST_UNSET is not set",
+			"monitors[0].request_headers.X-Api-Key: unresolved reference ${file:/nonexistent/roselite/secret}",
+		} {
+			if !strings.Contains(err.Error(), expected) {
+				t.Errorf("expected %q in error, got %v", expected, err)
+			}
+		}
+	})
+
+	t.Run("Unterminated reference", func(t *testing.T) {
+		path := writeConfiguration(t, `[[monitors]]
+id = "api"
+monitor_type = "HTTP"
+monitor_target = "https://example.com/${ROSELITE_TEST_API_KEY"
+`)
+
+		_, err := run(path, "validate")
+		if err == nil || !strings.Contains(err.Error(), "monitors[0].monitor_target: unterminated reference") {
+			t.Errorf("expected an unterminated reference error, got %v", err)
+		}
+	})
+}
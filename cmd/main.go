@@ -3,8 +3,11 @@ package main
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"os"
 	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/urfave/cli/v3"
 )
@@ -46,6 +49,74 @@ func main() {
 				Usage:   "Start Roselite in server mode, it will expose HTTP port",
 				Action:  ServerAction,
 			},
+			{
+				Name:      "check",
+				Version:   version,
+				Usage:     "Check the given monitors, or every monitor, once and print the resulting heartbeats",
+				ArgsUsage: "[monitor id...]",
+				Action:    CheckAction,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "output",
+						Aliases: []string{"o"},
+						Usage:   "Output format, either table or json",
+						Value:   "table",
+					},
+					&cli.BoolFlag{
+						Name:  "push",
+						Usage: "Push the resulting heartbeats to the upstream instance",
+					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "Print the upstream URL the heartbeats would be pushed to, without pushing them",
+					},
+				},
+			},
+			{
+				Name:    "import",
+				Version: version,
+				Usage:   "Generate a roselite configuration from the monitors of another tool",
+				Commands: []*cli.Command{
+					{
+						Name:      "kuma-backup",
+						Usage:     "Convert the push monitors of an Uptime Kuma JSON backup, using their push tokens as IDs",
+						ArgsUsage: "<backup.json>",
+						Action:    ImportKumaBackupAction,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    "mapping",
+								Aliases: []string{"m"},
+								Usage:   "TOML, YAML or JSON file with the monitor_type and monitor_target of every monitor, keyed by monitor name or push token",
+							},
+							&cli.StringSliceFlag{
+								Name:    "target",
+								Aliases: []string{"t"},
+								Usage:   "Target of a monitor as <monitor name or push token>=<target>, can be repeated",
+							},
+							&cli.StringFlag{
+								Name:  "type",
+								Usage: "Monitor type of the monitors whose type is not set on the mapping",
+								Value: "HTTP",
+							},
+							&cli.StringFlag{
+								Name:  "upstream-base-url",
+								Usage: "Base URL of the Uptime Kuma instance the backup was taken from",
+							},
+							&cli.StringFlag{
+								Name:    "output",
+								Aliases: []string{"o"},
+								Usage:   "File to write the configuration to, it must not exist. Defaults to the standard output",
+							},
+						},
+					},
+				},
+			},
+			{
+				Name:    "validate",
+				Version: version,
+				Usage:   "Check the configuration file and list every problem found, including deprecated options",
+				Action:  ValidateAction,
+			},
 		},
 		Action: DefaultAction,
 		Flags: []cli.Flag{
@@ -55,20 +126,40 @@ func main() {
 				Usage:       "Path to Roselite configuration file",
 				HideDefault: false,
 				Sources:     cli.ValueSourceChain{Chain: []cli.ValueSource{cli.EnvVar("CONFIGURATION_FILE_PATH")}},
-				Required:    true,
 				OnlyOnce:    true,
 			},
+			&cli.StringFlag{
+				Name:     "monitors-dir",
+				Usage:    "Directory of TOML, YAML or JSON files whose monitors are added to the configuration",
+				Sources:  cli.ValueSourceChain{Chain: []cli.ValueSource{cli.EnvVar("MONITORS_DIRECTORY")}},
+				OnlyOnce: true,
+			},
+			&cli.BoolFlag{
+				Name:     "watch-config",
+				Usage:    "Reload the configuration file when it is modified. The configuration is always reloaded on SIGHUP",
+				Sources:  cli.ValueSourceChain{Chain: []cli.ValueSource{cli.EnvVar("WATCH_CONFIGURATION_FILE")}},
+				OnlyOnce: true,
+			},
+			&cli.DurationFlag{
+				Name:     "shutdown-grace-period",
+				Usage:    "Maximum duration to wait for in-flight pushes, queued heartbeats and running checks on shutdown",
+				Value:    time.Second * 30,
+				Sources:  cli.ValueSourceChain{Chain: []cli.ValueSource{cli.EnvVar("SHUTDOWN_GRACE_PERIOD")}},
+				OnlyOnce: true,
+			},
 		},
 		Authors:   []any{},
 		Copyright: copyright,
 		Suggest:   true,
 	}
 
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	slog.SetDefault(slog.New(newRedactingHandler(slog.NewTextHandler(os.Stderr, nil))))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
 	if err := cmd.Run(ctx, os.Args); err != nil {
-		fmt.Println(err)
+		fmt.Println(redact(err.Error()))
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"time"
+	// Maintenance windows name their timezone, which the host or the container image may not have installed.
+	_ "time/tzdata"
+
+	"github.com/teknologi-umum/roselite"
+)
+
+// MaintenanceConfig holds the recurring maintenance windows, and the ad-hoc silences created on the server.
+type MaintenanceConfig struct {
+	// Windows are recurring maintenances, applied to the checks of the agent and to the heartbeats relayed by the server.
+	Windows []MaintenanceWindowConfig `json:"windows" toml:"windows" yaml:"windows"`
+
+	// Silences enables the /api/silences endpoints of the server.
+	Silences SilencesConfig `json:"silences" toml:"silences" yaml:"silences"`
+}
+
+// MaintenanceWindowConfig is a recurring maintenance, such as a nightly backup.
+type MaintenanceWindowConfig struct {
+	Name string `json:"name" toml:"name" yaml:"name"`
+
+	// Schedule is a cron expression of five fields on which the window starts, such as "0 2 * * *" for every day at
+	// 2 AM, or a shorthand such as "@daily". RRULE recurrence rules are not supported.
+	Schedule string `json:"schedule" toml:"schedule" yaml:"schedule"`
+
+	// Duration is how long the window lasts from every start.
+	Duration Duration `json:"duration" toml:"duration" yaml:"duration"`
+
+	// Timezone is the IANA timezone the schedule is evaluated in, such as "Asia/Jakarta". Defaults to the local timezone.
+	Timezone string `json:"timezone" toml:"timezone" yaml:"timezone"`
+
+	// Monitors and Tags select the monitors under the window, by their ID or by any of their tags.
+	Monitors []string `json:"monitors" toml:"monitors" yaml:"monitors"`
+	Tags     []string `json:"tags" toml:"tags" yaml:"tags"`
+
+	// Mode is "push" to check the monitors and push them with the maintenance status, or "skip" to neither check nor
+	// push them. Defaults to "push".
+	Mode string `json:"mode" toml:"mode" yaml:"mode"`
+}
+
+// SilencesConfig configures the /api/silences endpoints of the server.
+type SilencesConfig struct {
+	// Enabled lets clients create and remove silences on the server.
+	Enabled bool `json:"enabled" toml:"enabled" yaml:"enabled" env:"SILENCES_ENABLED"`
+
+	// Tokens are the bearer tokens allowed to list, create and remove silences. Leaving it empty lets any client do so.
+	Tokens []string `json:"tokens" toml:"tokens" yaml:"tokens"`
+}
+
+// toRoseliteMaintenanceWindow parses the schedule, the timezone and the mode of the window.
+func (w MaintenanceWindowConfig) toRoseliteMaintenanceWindow() (roselite.MaintenanceWindow, error) {
+	schedule, err := roselite.ParseCronSchedule(w.Schedule)
+	if err != nil {
+		return roselite.MaintenanceWindow{}, fmt.Errorf("schedule: %w", err)
+	}
+
+	location := time.Local
+	if w.Timezone != "" {
+		location, err = time.LoadLocation(w.Timezone)
+		if err != nil {
+			return roselite.MaintenanceWindow{}, fmt.Errorf("timezone: %w", err)
+		}
+	}
+
+	mode, err := roselite.MaintenanceModeFromString(w.Mode)
+	if err != nil {
+		return roselite.MaintenanceWindow{}, fmt.Errorf("mode: %w: %q, expected push or skip", err, w.Mode)
+	}
+
+	return roselite.MaintenanceWindow{
+		Name:     w.Name,
+		Schedule: schedule,
+		Duration: w.Duration.Duration(),
+		Location: location,
+		MaintenanceSelector: roselite.MaintenanceSelector{
+			Monitors: w.Monitors,
+			Tags:     w.Tags,
+		},
+		Mode: mode,
+	}, nil
+}
+
+// MaintenanceWindows returns the recurring maintenance windows.
+func (c Configuration) MaintenanceWindows() ([]roselite.MaintenanceWindow, error) {
+	windows := make([]roselite.MaintenanceWindow, 0, len(c.Maintenance.Windows))
+	for i, window := range c.Maintenance.Windows {
+		converted, err := window.toRoseliteMaintenanceWindow()
+		if err != nil {
+			return nil, fmt.Errorf("maintenance.windows[%d]: %w", i, err)
+		}
+
+		windows = append(windows, converted)
+	}
+
+	return windows, nil
+}
+
+// SilenceOptions returns the options of the silences, or nil if they are disabled.
+func (c Configuration) SilenceOptions() *roselite.SilenceOptions {
+	if !c.Maintenance.Silences.Enabled {
+		return nil
+	}
+
+	return &roselite.SilenceOptions{Tokens: c.Maintenance.Silences.Tokens}
+}
+
+func (c Configuration) validateMaintenance(problems *ConfigurationProblems) {
+	for i, window := range c.Maintenance.Windows {
+		location := fmt.Sprintf("maintenance.windows[%d]", i)
+		if window.Name == "" {
+			problems.add(location+".name", "missing")
+		}
+
+		if _, err := roselite.ParseCronSchedule(window.Schedule); err != nil {
+			problems.add(location+".schedule", "%s", err)
+		}
+
+		if window.Duration <= 0 {
+			problems.add(location+".duration", "must be positive")
+		}
+
+		if window.Timezone != "" {
+			if _, err := time.LoadLocation(window.Timezone); err != nil {
+				problems.add(location+".timezone", "%q is not a known timezone", window.Timezone)
+			}
+		}
+
+		if len(window.Monitors) == 0 && len(window.Tags) == 0 {
+			problems.add(location, "must select monitors or tags")
+		}
+
+		if _, err := roselite.MaintenanceModeFromString(window.Mode); err != nil {
+			problems.add(location+".mode", "%q is not a valid mode, expected push or skip", window.Mode)
+		}
+	}
+
+	if c.Maintenance.Silences.Enabled && len(c.Maintenance.Silences.Tokens) == 0 {
+		problems.warn("maintenance.silences.tokens", "missing, any client can silence the monitors")
+	}
+}
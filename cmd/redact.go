@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const (
+	redactedPlaceholder = "[redacted]"
+	// minimumRedactedLength is the length under which an interpolated value is not redacted, as replacing every
+	// occurrence of such a short value would mangle unrelated output.
+	minimumRedactedLength = 4
+)
+
+// redactedValues are the values resolved from a ${...} reference on any configuration loaded by the process. They
+// are kept once a configuration is reloaded, as they may still show up on in-flight errors.
+var redactedValues struct {
+	mutex  sync.RWMutex
+	values []string
+}
+
+func addRedactedValues(values []string) {
+	redactedValues.mutex.Lock()
+	defer redactedValues.mutex.Unlock()
+
+	for _, value := range values {
+		if len(value) >= minimumRedactedLength && !slices.Contains(redactedValues.values, value) {
+			redactedValues.values = append(redactedValues.values, value)
+		}
+	}
+
+	// Longer values go first, so a value that contains another one is redacted as a whole.
+	slices.SortFunc(redactedValues.values, func(a, b string) int { return len(b) - len(a) })
+}
+
+// redact replaces every interpolated configuration value found on s.
+func redact(s string) string {
+	redactedValues.mutex.RLock()
+	defer redactedValues.mutex.RUnlock()
+
+	for _, value := range redactedValues.values {
+		s = strings.ReplaceAll(s, value, redactedPlaceholder)
+	}
+
+	return s
+}
+
+// redactingHandler is a slog.Handler that redacts the interpolated configuration values from the message and the
+// attributes of every record.
+type redactingHandler struct {
+	handler slog.Handler
+}
+
+func newRedactingHandler(handler slog.Handler) *redactingHandler {
+	return &redactingHandler{handler: handler}
+}
+
+func (h *redactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *redactingHandler) Handle(ctx context.Context, record slog.Record) error {
+	redacted := slog.NewRecord(record.Time, record.Level, redact(record.Message), record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		redacted.AddAttrs(redactAttr(attr))
+		return true
+	})
+
+	return h.handler.Handle(ctx, redacted)
+}
+
+func (h *redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		redacted[i] = redactAttr(attr)
+	}
+
+	return &redactingHandler{handler: h.handler.WithAttrs(redacted)}
+}
+
+func (h *redactingHandler) WithGroup(name string) slog.Handler {
+	return &redactingHandler{handler: h.handler.WithGroup(name)}
+}
+
+func redactAttr(attr slog.Attr) slog.Attr {
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, redact(value.String()))
+	case slog.KindAny:
+		if err, ok := value.Any().(error); ok {
+			return slog.String(attr.Key, redact(err.Error()))
+		}
+	case slog.KindGroup:
+		group := value.Group()
+		redacted := make([]any, len(group))
+		for i, groupAttr := range group {
+			redacted[i] = redactAttr(groupAttr)
+		}
+		return slog.Group(attr.Key, redacted...)
+	}
+
+	return slog.Attr{Key: attr.Key, Value: value}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jinzhu/configor"
+	"github.com/urfave/cli/v3"
+)
+
+// configurationWatchInterval is how often the configuration file is checked for modifications when watching is enabled.
+const configurationWatchInterval = time.Second * 5
+
+// loadConfiguration reads the configuration file, applying the defaults and the environment variables, then appends
+// the monitors of the included files and of the monitors directory. The ${...} references are resolved last, so they
+// can be used on the included files too.
+func loadConfiguration(path string, monitorsDir string) (Configuration, error) {
+	if path == "" {
+		return Configuration{}, errors.New("configuration file is not set, use --config or CONFIGURATION_FILE_PATH")
+	}
+
+	var configuration Configuration
+	err := configor.New(&configor.Config{}).Load(&configuration, path)
+	if err != nil {
+		return Configuration{}, fmt.Errorf("loading configuration: %w", err)
+	}
+
+	if err := configuration.includeMonitors(path, monitorsDir); err != nil {
+		return Configuration{}, fmt.Errorf("loading configuration: %w", err)
+	}
+	configuration.Discovery.FileSD.resolvePaths(path)
+
+	if err := configuration.interpolate(); err != nil {
+		return Configuration{}, fmt.Errorf("interpolating configuration:\n%w", err)
+	}
+	addRedactedValues(configuration.secrets)
+
+	return configuration, nil
+}
+
+// restartSettings are the settings a reload does not put into effect, they are only read when roselite starts.
+var restartSettings = []struct {
+	name  string
+	value func(Configuration) any
+}{
+	{"error_reporting", func(c Configuration) any { return c.ErrorReporting }},
+	{"server.listen_address", func(c Configuration) any { return c.ServerConfig.ListenAddress }},
+	{"server.tls_config", func(c Configuration) any { return c.ServerConfig.TLSConfig }},
+	{"server.monitor_aliases", func(c Configuration) any { return c.ServerConfig.MonitorAliases }},
+	{"server.strict_monitor_aliases", func(c Configuration) any { return c.ServerConfig.StrictMonitorAliases }},
+	{"server.rate_limit", func(c Configuration) any { return c.ServerConfig.RateLimit }},
+	{"server.batch_concurrency", func(c Configuration) any { return c.ServerConfig.BatchConcurrency }},
+	{"server.instance_id", func(c Configuration) any { return c.ServerConfig.InstanceId }},
+	{"server.max_hops", func(c Configuration) any { return c.ServerConfig.MaxHops }},
+	{"server.heartbeat_history_size", func(c Configuration) any { return c.ServerConfig.HeartbeatHistorySize }},
+	{"server.forwarding_queue", func(c Configuration) any { return c.ServerConfig.ForwardingQueue }},
+	{"region", func(c Configuration) any { return c.Region }},
+	{"agent_id", func(c Configuration) any { return c.AgentId }},
+	{"registration", func(c Configuration) any { return c.Registration }},
+	{"self", func(c Configuration) any { return c.Self }},
+	{"discovery", func(c Configuration) any { return c.Discovery }},
+}
+
+// restartRequiredChanges returns the settings that differ between the running and the reloaded configuration, but
+// keep their running value until roselite is restarted.
+func restartRequiredChanges(running Configuration, reloaded Configuration) []string {
+	var changes []string
+	for _, setting := range restartSettings {
+		if !reflect.DeepEqual(setting.value(running), setting.value(reloaded)) {
+			changes = append(changes, setting.name)
+		}
+	}
+
+	return changes
+}
+
+// configurationReloader reloads the configuration file on SIGHUP, and when the file is modified if watching is enabled.
+// A configuration that fails to load or to validate is rejected, and the running configuration is kept.
+type configurationReloader struct {
+	path        string
+	monitorsDir string
+	watch       bool
+	status      *ConfigurationStatus
+	// apply puts the new configuration into effect. It must not change anything if it returns an error.
+	apply func(Configuration) error
+
+	// running is the configuration roselite started with, which still holds the settings a reload does not change.
+	running Configuration
+	// sources are the files and directories the running configuration is loaded from, watched for modifications.
+	sources     []string
+	fingerprint string
+}
+
+func newConfigurationReloader(c *cli.Command, configuration Configuration, status *ConfigurationStatus, apply func(Configuration) error) *configurationReloader {
+	r := &configurationReloader{
+		path:        c.String("config"),
+		monitorsDir: c.String("monitors-dir"),
+		watch:       c.Bool("watch-config"),
+		status:      status,
+		apply:       apply,
+		running:     configuration,
+		sources:     configuration.sources,
+	}
+	r.fingerprint = r.currentFingerprint()
+
+	return r
+}
+
+// currentFingerprint combines the modification times of the sources. Adding or removing a file in a directory
+// changes the modification time of the directory.
+func (r *configurationReloader) currentFingerprint() string {
+	var fingerprint strings.Builder
+	for _, source := range r.sources {
+		if info, err := os.Stat(source); err == nil {
+			fingerprint.WriteString(info.ModTime().String())
+		}
+		fingerprint.WriteString("\n")
+	}
+
+	return fingerprint.String()
+}
+
+// Run listens for reload triggers until the context is done.
+func (r *configurationReloader) Run(ctx context.Context) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	var tick <-chan time.Time
+	if r.watch {
+		ticker := time.NewTicker(configurationWatchInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			slog.Info("reloading configuration", slog.String("trigger", "SIGHUP"))
+			r.reload()
+		case <-tick:
+			fingerprint := r.currentFingerprint()
+			if fingerprint == r.fingerprint {
+				continue
+			}
+
+			r.fingerprint = fingerprint
+			slog.Info("reloading configuration", slog.String("trigger", "file modified"))
+			r.reload()
+		}
+	}
+}
+
+func (r *configurationReloader) reload() {
+	err := r.load()
+	if err != nil {
+		slog.Error("configuration rejected, keeping the running configuration", slog.String("error", err.Error()))
+		r.status.ReloadFailed()
+		return
+	}
+
+	slog.Info("configuration reloaded")
+	r.status.Reloaded()
+}
+
+func (r *configurationReloader) load() error {
+	configuration, err := loadConfiguration(r.path, r.monitorsDir)
+	if err != nil {
+		return err
+	}
+
+	if err := configuration.Validate().Err(); err != nil {
+		return fmt.Errorf("validating configuration: %w", err)
+	}
+
+	if err := r.apply(configuration); err != nil {
+		return err
+	}
+
+	// running is not replaced, so the settings are reported on every reload until roselite is restarted.
+	if changes := restartRequiredChanges(r.running, configuration); len(changes) > 0 {
+		slog.Warn("configuration changes require a restart to take effect", slog.String("settings", strings.Join(changes, ", ")))
+	}
+
+	r.sources = configuration.sources
+	r.fingerprint = r.currentFingerprint()
+	return nil
+}
@@ -0,0 +1,43 @@
+package main_test
+
+import (
+	"slices"
+	"testing"
+
+	main "github.com/teknologi-umum/roselite/cmd"
+)
+
+func TestConfiguration_RestartRequiredChanges(t *testing.T) {
+	running := main.Configuration{
+		ServerConfig: main.ServerConfig{
+			ListenAddress:  "127.0.0.1:8321",
+			MonitorAliases: map[string]main.MonitorAlias{"billing": {Token: "token"}},
+		},
+		UpstreamConfig: main.UpstreamConfig{BaseUrl: "https://uptime.example.com"},
+		Region:         "eu",
+		Monitors:       []main.Monitor{{Id: "blog"}},
+	}
+
+	t.Run("Reloaded settings", func(t *testing.T) {
+		reloaded := running
+		reloaded.UpstreamConfig = main.UpstreamConfig{BaseUrl: "https://status.example.com"}
+		reloaded.Monitors = []main.Monitor{{Id: "blog"}, {Id: "shop"}}
+		reloaded.ServerConfig.Status = main.StatusConfig{Enabled: true}
+
+		if changes := main.RestartRequiredChanges(running, reloaded); len(changes) != 0 {
+			t.Errorf("expected no changes requiring a restart, got %v", changes)
+		}
+	})
+
+	t.Run("Restart settings", func(t *testing.T) {
+		reloaded := running
+		reloaded.ServerConfig.ListenAddress = "0.0.0.0:8321"
+		reloaded.ServerConfig.MonitorAliases = map[string]main.MonitorAlias{"billing": {Token: "rotated"}}
+		reloaded.Region = "us"
+
+		expected := []string{"server.listen_address", "server.monitor_aliases", "region"}
+		if changes := main.RestartRequiredChanges(running, reloaded); !slices.Equal(changes, expected) {
+			t.Errorf("expected %v, got %v", expected, changes)
+		}
+	})
+}
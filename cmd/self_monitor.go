@@ -0,0 +1,53 @@
+package main
+
+import (
+	"github.com/teknologi-umum/roselite"
+)
+
+// SelfConfig configures the monitor roselite pushes about its own health.
+type SelfConfig struct {
+	// PushId is the push token of an upstream monitor dedicated to roselite itself. Leaving it empty disables the self
+	// monitor.
+	PushId string `json:"push_id" toml:"push_id" yaml:"push_id" env:"SELF_PUSH_ID"`
+
+	// Interval is how often the self monitor is pushed, defaults to 30 seconds.
+	Interval Duration `json:"interval" toml:"interval" yaml:"interval"`
+
+	// UpstreamErrorRate is the share of failed pushes to the upstream instance within an interval, between 0 and 1,
+	// from which the self monitor is pending, defaults to 0.5.
+	UpstreamErrorRate float64 `json:"upstream_error_rate" toml:"upstream_error_rate" yaml:"upstream_error_rate"`
+}
+
+// SelfMonitor returns the self monitor reporting on the agent and the server, either can be nil. It returns nil if
+// the self monitor is disabled.
+func (c Configuration) SelfMonitor(agent *roselite.Agent, server *roselite.Server) *roselite.SelfMonitor {
+	if c.Self.PushId == "" {
+		return nil
+	}
+
+	return &roselite.SelfMonitor{
+		PushID:            c.Self.PushId,
+		Interval:          c.Self.Interval.Duration(),
+		UpstreamErrorRate: c.Self.UpstreamErrorRate,
+		Agent:             agent,
+		Server:            server,
+	}
+}
+
+func (c Configuration) validateSelf(problems *ConfigurationProblems) {
+	if c.Self.PushId == "" {
+		return
+	}
+
+	if c.UpstreamConfig.BaseUrl == "" {
+		problems.add("self.push_id", "is set, but upstream.base_url is not")
+	}
+
+	if c.Self.Interval < 0 {
+		problems.add("self.interval", "must not be negative")
+	}
+
+	if c.Self.UpstreamErrorRate < 0 || c.Self.UpstreamErrorRate > 1 {
+		problems.add("self.upstream_error_rate", "must be between 0 and 1")
+	}
+}
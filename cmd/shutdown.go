@@ -0,0 +1,19 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/urfave/cli/v3"
+)
+
+// shutdownGracePeriodContext returns a context that is done once the configured grace period is over. It does not
+// derive from the command's context, as that one is already cancelled by the time the shutdown starts.
+func shutdownGracePeriodContext(c *cli.Command) (context.Context, context.CancelFunc) {
+	gracePeriod := c.Duration("shutdown-grace-period")
+	if gracePeriod <= 0 {
+		gracePeriod = time.Second * 30
+	}
+
+	return context.WithTimeout(context.Background(), gracePeriod)
+}
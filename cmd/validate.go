@@ -0,0 +1,324 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/teknologi-umum/roselite"
+	"github.com/urfave/cli/v3"
+)
+
+// ConfigurationProblem is a single problem found in the configuration.
+type ConfigurationProblem struct {
+	// Location is the path of the offending option, such as `monitors[2].monitor_type`.
+	Location string
+	// Message describes the problem.
+	Message string
+	// Warning marks problems that do not prevent the configuration from being applied, such as deprecated options.
+	Warning bool
+}
+
+func (p ConfigurationProblem) String() string {
+	severity := "error"
+	if p.Warning {
+		severity = "warning"
+	}
+
+	return fmt.Sprintf("%s: %s: %s", severity, p.Location, p.Message)
+}
+
+// ConfigurationProblems is the list of problems found in the configuration, in the order of the options.
+type ConfigurationProblems []ConfigurationProblem
+
+// Err returns an error listing every problem that prevents the configuration from being applied, or nil if there is
+// none. Warnings are not included.
+func (p ConfigurationProblems) Err() error {
+	var errs []error
+	for _, problem := range p {
+		if !problem.Warning {
+			errs = append(errs, fmt.Errorf("%s: %s", problem.Location, problem.Message))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (p *ConfigurationProblems) add(location string, format string, args ...any) {
+	*p = append(*p, ConfigurationProblem{Location: location, Message: fmt.Sprintf(format, args...)})
+}
+
+func (p *ConfigurationProblems) warn(location string, format string, args ...any) {
+	*p = append(*p, ConfigurationProblem{Location: location, Message: fmt.Sprintf(format, args...), Warning: true})
+}
+
+// Validate checks the whole configuration and returns every problem found.
+func (c Configuration) Validate() ConfigurationProblems {
+	var problems ConfigurationProblems
+
+	if c.ServerConfig.UpstreamKuma != "" {
+		problems.warn("server.upstream_kuma", "deprecated and ignored, use upstream.base_url instead")
+	}
+	c.ServerConfig.TLSConfig.validate("server.tls_config", &problems)
+
+	if c.UpstreamConfig.BaseUrl == "" {
+		if c.usesDefaultUpstream() {
+			problems.add("upstream.base_url", "missing, heartbeats have nowhere to be pushed")
+		}
+	} else if err := validateHttpUrl(c.UpstreamConfig.BaseUrl); err != nil {
+		problems.add("upstream.base_url", "%s", err)
+	}
+	c.UpstreamConfig.TLSConfig.validate("upstream.tls_config", &problems)
+
+	for _, id := range slices.Sorted(maps.Keys(c.ServerConfig.MonitorAliases)) {
+		alias := c.ServerConfig.MonitorAliases[id]
+		if alias.UpstreamBaseUrl == "" {
+			continue
+		}
+
+		if err := validateHttpUrl(alias.UpstreamBaseUrl); err != nil {
+			problems.add(fmt.Sprintf("server.monitor_aliases.%s.upstream_base_url", id), "%s", err)
+		}
+	}
+
+	c.Defaults.validate("defaults", &problems)
+	c.validateDiscovery(&problems)
+	c.validateFleet(&problems)
+	c.validateSelf(&problems)
+	c.validateMaintenance(&problems)
+	if c.ServerConfig.Distribution.Enabled && len(c.ServerConfig.Distribution.Tokens) == 0 {
+		problems.warn("server.distribution.tokens", "missing, any client can read the monitors and their request headers")
+	}
+	if c.ServerConfig.Status.Enabled && len(c.ServerConfig.Status.Tokens) == 0 {
+		problems.warn("server.status.tokens", "missing, any client can read the status of the monitors")
+	}
+	for _, name := range slices.Sorted(maps.Keys(c.Templates)) {
+		c.Templates[name].validate(fmt.Sprintf("templates.%s", name), &problems)
+	}
+
+	ids := make(map[string]string, len(c.Monitors))
+	for i, monitor := range c.Monitors {
+		location := monitor.location
+		if location == "" {
+			location = fmt.Sprintf("monitors[%d]", i)
+		}
+		definedAt := location
+		if monitor.Id != "" {
+			location = fmt.Sprintf("%s (id %q)", location, monitor.Id)
+		}
+
+		if monitor.Id == "" {
+			problems.add(location+".id", "missing, the id is the push token on the upstream instance")
+		} else if first, ok := ids[monitor.Id]; ok {
+			problems.add(location+".id", "duplicate of %s", first)
+		} else {
+			ids[monitor.Id] = definedAt
+		}
+
+		if _, ok := c.Templates[monitor.Template]; monitor.Template != "" && !ok {
+			problems.add(location+".template", "unknown template %q", monitor.Template)
+		}
+
+		// Options inherited from a template or the defaults are checked there, the TLS files are not read twice.
+		monitor.TLSConfig.validate(location+".tls_config", &problems)
+		resolved := c.ResolveMonitor(monitor)
+		resolved.validate(location, &problems)
+		if c.ServerConfig.Distribution.Enabled && resolved.TLSConfig.hasFiles() {
+			problems.add(location+".tls_config", "files on this host cannot be distributed to the agents, which would look for them on their own host")
+		}
+	}
+
+	return problems
+}
+
+// usesDefaultUpstream reports whether any heartbeat can be pushed to upstream.base_url, which is not the case if
+// the server only accepts aliases that all point to another upstream.
+func (c Configuration) usesDefaultUpstream() bool {
+	if len(c.Monitors) > 0 || !c.ServerConfig.StrictMonitorAliases {
+		return true
+	}
+
+	for _, alias := range c.ServerConfig.MonitorAliases {
+		if alias.UpstreamBaseUrl == "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validate checks a monitor that has its template and the defaults applied, except for its TLS config.
+func (m Monitor) validate(location string, problems *ConfigurationProblems) {
+	if m.PushURL != "" {
+		problems.warn(location+".push_url", "deprecated and ignored, set upstream.base_url and use the push token as the id instead")
+	}
+
+	if m.SkipTLSVerify {
+		problems.warn(location+".skip_tls_verify", "deprecated and ignored, use tls_config.skip_tls_verify instead")
+	}
+
+	monitorType, err := roselite.MonitorTypeFromString(m.MonitorType)
+	if err != nil {
+		problems.add(location+".monitor_type", "unknown monitor type %q, expected HTTP or ICMP", m.MonitorType)
+	}
+
+	switch {
+	case m.MonitorTarget == "":
+		problems.add(location+".monitor_target", "missing")
+	case monitorType == roselite.MonitorTypeHTTP:
+		if err := validateHttpUrl(m.MonitorTarget); err != nil {
+			problems.add(location+".monitor_target", "%s", err)
+		}
+	case monitorType == roselite.MonitorTypeICMP:
+		if strings.ContainsAny(m.MonitorTarget, "/:?#@ ") && net.ParseIP(m.MonitorTarget) == nil {
+			problems.add(location+".monitor_target", "%q is not a host name or an IP address", m.MonitorTarget)
+		}
+	}
+
+	if m.Interval < 0 {
+		problems.add(location+".interval", "must not be negative")
+	}
+
+	if m.Timeout < 0 {
+		problems.add(location+".timeout", "must not be negative")
+	}
+
+	interval := m.Interval.Duration()
+	if interval <= 0 {
+		interval = roselite.DefaultMonitorInterval
+	}
+	if interval < roselite.MinimumMonitorInterval {
+		problems.add(location+".interval", "%s is shorter than the minimum of %s", interval, roselite.MinimumMonitorInterval)
+		return
+	}
+	timeout := roselite.Monitor{Interval: interval, Timeout: m.Timeout.Duration()}.CheckTimeout()
+
+	if interval < timeout {
+		problems.add(location+".interval", "%s is shorter than the %s timeout", interval, timeout)
+	}
+
+	if minimum := roselite.MinimumCheckDuration(monitorType); timeout <= minimum {
+		timeoutLocation := location + ".timeout"
+		if m.Timeout <= 0 {
+			timeoutLocation = location + ".interval"
+		}
+		problems.add(timeoutLocation, "%s is too short, %s checks take at least %s", timeout, monitorType, minimum)
+	}
+}
+
+func (t MonitorTemplate) validate(location string, problems *ConfigurationProblems) {
+	if t.MonitorType != "" {
+		if _, err := roselite.MonitorTypeFromString(t.MonitorType); err != nil {
+			problems.add(location+".monitor_type", "unknown monitor type %q, expected HTTP or ICMP", t.MonitorType)
+		}
+	}
+
+	if t.Interval < 0 {
+		problems.add(location+".interval", "must not be negative")
+	}
+
+	if t.Timeout < 0 {
+		problems.add(location+".timeout", "must not be negative")
+	}
+
+	t.TLSConfig.validate(location+".tls_config", problems)
+}
+
+func (t TLSConfig) validate(location string, problems *ConfigurationProblems) {
+	if t.CertificateAuthorityFile != "" {
+		content, err := os.ReadFile(t.CertificateAuthorityFile)
+		if err != nil {
+			problems.add(location+".ca_file", "%s", err)
+		} else if !x509.NewCertPool().AppendCertsFromPEM(content) {
+			problems.add(location+".ca_file", "%s does not contain any PEM encoded certificate", t.CertificateAuthorityFile)
+		}
+	}
+
+	if (t.CertificateFile == "") != (t.PrivateKeyFile == "") {
+		problems.add(location, "certificate_file and private_key_file must be set together")
+		return
+	}
+
+	if t.CertificateFile == "" {
+		return
+	}
+
+	_, certificateErr := os.ReadFile(t.CertificateFile)
+	if certificateErr != nil {
+		problems.add(location+".certificate_file", "%s", certificateErr)
+	}
+
+	_, privateKeyErr := os.ReadFile(t.PrivateKeyFile)
+	if privateKeyErr != nil {
+		problems.add(location+".private_key_file", "%s", privateKeyErr)
+	}
+
+	if certificateErr == nil && privateKeyErr == nil {
+		if _, err := tls.LoadX509KeyPair(t.CertificateFile, t.PrivateKeyFile); err != nil {
+			problems.add(location, "invalid certificate or private key: %s", err)
+		}
+	}
+}
+
+func validateHttpUrl(rawUrl string) error {
+	parsed, err := url.Parse(rawUrl)
+	if err != nil {
+		return fmt.Errorf("malformed URL: %w", err)
+	}
+
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("%q must be an http or https URL", rawUrl)
+	}
+
+	if parsed.Host == "" {
+		return fmt.Errorf("%q has no host", rawUrl)
+	}
+
+	return nil
+}
+
+// validateConfiguration runs the validation on startup. Warnings are logged, and any other problem is returned.
+func validateConfiguration(configuration Configuration) error {
+	problems := configuration.Validate()
+	for _, problem := range problems {
+		if problem.Warning {
+			slog.Warn(problem.Message, slog.String("location", problem.Location))
+		}
+	}
+
+	if err := problems.Err(); err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	return nil
+}
+
+// ValidateAction loads the configuration and reports every problem found. It fails if there is any, including the
+// use of deprecated options.
+func ValidateAction(ctx context.Context, c *cli.Command) error {
+	configuration, err := loadConfiguration(c.String("config"), c.String("monitors-dir"))
+	if err != nil {
+		return err
+	}
+
+	problems := configuration.Validate()
+	for _, problem := range problems {
+		_, _ = fmt.Fprintln(c.Root().Writer, redact(problem.String()))
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%s: %d problems found", c.String("config"), len(problems))
+	}
+
+	_, _ = fmt.Fprintf(c.Root().Writer, "%s: configuration is valid, %d monitors\n", c.String("config"), len(configuration.Monitors))
+	return nil
+}
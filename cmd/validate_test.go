@@ -0,0 +1,216 @@
+package main_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	main "github.com/teknologi-umum/roselite/cmd"
+)
+
+func TestConfiguration_Validate(t *testing.T) {
+	emptyFile := filepath.Join(t.TempDir(), "empty.pem")
+	if err := os.WriteFile(emptyFile, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	valid := func() main.Configuration {
+		return main.Configuration{
+			UpstreamConfig: main.UpstreamConfig{BaseUrl: "https://kuma.example.com"},
+			Monitors: []main.Monitor{
+				{Id: "http", MonitorType: "HTTP", MonitorTarget: "https://example.com/healthz", Interval: main.Duration(time.Second * 30)},
+				{Id: "icmp", MonitorType: "ICMP", MonitorTarget: "10.0.0.1", Interval: main.Duration(time.Second * 30)},
+			},
+		}
+	}
+
+	testCases := []struct {
+		name              string
+		modify            func(configuration *main.Configuration)
+		expectedLocations []string
+		expectedWarnings  []string
+	}{
+		{
+			name:   "Valid",
+			modify: func(configuration *main.Configuration) {},
+		},
+		{
+			name: "Unknown monitor type",
+			modify: func(configuration *main.Configuration) {
+				configuration.Monitors[0].MonitorType = "TCP"
+			},
+			expectedLocations: []string{`monitors[0] (id "http").monitor_type`},
+		},
+		{
+			name: "Duplicate and missing IDs",
+			modify: func(configuration *main.Configuration) {
+				configuration.Monitors[1].Id = "http"
+				configuration.Monitors = append(configuration.Monitors, main.Monitor{MonitorType: "HTTP", MonitorTarget: "https://example.com"})
+			},
+			expectedLocations: []string{`monitors[1] (id "http").id`, "monitors[2].id"},
+		},
+		{
+			name: "Missing upstream base URL",
+			modify: func(configuration *main.Configuration) {
+				configuration.UpstreamConfig.BaseUrl = ""
+			},
+			expectedLocations: []string{"upstream.base_url"},
+		},
+		{
+			name: "Missing upstream base URL with strict aliases to other upstreams",
+			modify: func(configuration *main.Configuration) {
+				configuration.UpstreamConfig.BaseUrl = ""
+				configuration.Monitors = nil
+				configuration.ServerConfig.StrictMonitorAliases = true
+				configuration.ServerConfig.MonitorAliases = map[string]main.MonitorAlias{
+					"billing": {Token: "abc", UpstreamBaseUrl: "https://another-kuma.example.com"},
+				}
+			},
+		},
+		{
+			name: "Unreadable certificate files",
+			modify: func(configuration *main.Configuration) {
+				configuration.UpstreamConfig.TLSConfig.CertificateAuthorityFile = emptyFile
+				configuration.Monitors[0].TLSConfig = main.TLSConfig{
+					CertificateFile: "/nonexistent/roselite.crt",
+					PrivateKeyFile:  "/nonexistent/roselite.key",
+				}
+				configuration.ServerConfig.TLSConfig.CertificateFile = "/nonexistent/roselite.crt"
+			},
+			expectedLocations: []string{
+				"server.tls_config",
+				"upstream.tls_config.ca_file",
+				`monitors[0] (id "http").tls_config.certificate_file`,
+				`monitors[0] (id "http").tls_config.private_key_file`,
+			},
+		},
+		{
+			name: "Malformed targets",
+			modify: func(configuration *main.Configuration) {
+				configuration.Monitors[0].MonitorTarget = "example.com/healthz"
+				configuration.Monitors[1].MonitorTarget = "https://example.com"
+			},
+			expectedLocations: []string{`monitors[0] (id "http").monitor_target`, `monitors[1] (id "icmp").monitor_target`},
+		},
+		{
+			name: "Deprecated options",
+			modify: func(configuration *main.Configuration) {
+				configuration.ServerConfig.UpstreamKuma = "https://kuma.example.com"
+				configuration.Monitors[0].PushURL = "https://kuma.example.com/api/push/http"
+				configuration.Monitors[0].SkipTLSVerify = true
+			},
+			expectedWarnings: []string{
+				"server.upstream_kuma",
+				`monitors[0] (id "http").push_url`,
+				`monitors[0] (id "http").skip_tls_verify`,
+			},
+		},
+		{
+			name: "Interval shorter than the timeout",
+			modify: func(configuration *main.Configuration) {
+				configuration.Defaults.Timeout = main.Duration(time.Minute)
+				configuration.Monitors[1].Timeout = main.Duration(time.Second * 10)
+			},
+			expectedLocations: []string{`monitors[0] (id "http").interval`},
+		},
+		{
+			name: "Timeout too short",
+			modify: func(configuration *main.Configuration) {
+				configuration.Monitors[1].Timeout = main.Duration(time.Second)
+			},
+			expectedLocations: []string{`monitors[1] (id "icmp").timeout`},
+		},
+		{
+			name: "Interval too short",
+			modify: func(configuration *main.Configuration) {
+				configuration.Monitors[1].Interval = main.Duration(time.Second)
+			},
+			expectedLocations: []string{`monitors[1] (id "icmp").interval`},
+		},
+		{
+			name: "Interval below the minimum",
+			modify: func(configuration *main.Configuration) {
+				configuration.Monitors[0].Interval = main.Duration(time.Millisecond)
+			},
+			expectedLocations: []string{`monitors[0] (id "http").interval`},
+		},
+		{
+			name: "Distributed monitor with TLS files",
+			modify: func(configuration *main.Configuration) {
+				configuration.ServerConfig.Distribution = main.DistributionConfig{Enabled: true, Tokens: []string{"agent-token"}}
+				configuration.Monitors[0].TLSConfig.CertificateAuthorityFile = emptyFile
+			},
+			expectedLocations: []string{`monitors[0] (id "http").tls_config.ca_file`, `monitors[0] (id "http").tls_config`},
+		},
+		{
+			name: "Docker discovery over TCP",
+			modify: func(configuration *main.Configuration) {
+				configuration.Discovery.Docker = main.DockerDiscoveryConfig{Enabled: true, Host: "tcp://127.0.0.1:2375"}
+			},
+			expectedLocations: []string{"discovery.docker.host"},
+		},
+		{
+			name: "Maintenance window and silences without tokens",
+			modify: func(configuration *main.Configuration) {
+				configuration.Maintenance = main.MaintenanceConfig{
+					Windows: []main.MaintenanceWindowConfig{
+						{Name: "nightly-backup", Schedule: "0 2 * * *", Duration: main.Duration(time.Hour), Timezone: "Asia/Jakarta", Tags: []string{"database"}},
+					},
+					Silences: main.SilencesConfig{Enabled: true},
+				}
+			},
+			expectedWarnings: []string{"maintenance.silences.tokens"},
+		},
+		{
+			name: "Status without tokens",
+			modify: func(configuration *main.Configuration) {
+				configuration.ServerConfig.Status = main.StatusConfig{Enabled: true}
+			},
+			expectedWarnings: []string{"server.status.tokens"},
+		},
+		{
+			name: "Invalid maintenance window",
+			modify: func(configuration *main.Configuration) {
+				configuration.Maintenance.Windows = []main.MaintenanceWindowConfig{
+					{Name: "nightly-backup", Schedule: "0 25 * * *", Timezone: "Mars/Olympus", Mode: "mute"},
+				}
+			},
+			expectedLocations: []string{
+				"maintenance.windows[0].schedule",
+				"maintenance.windows[0].duration",
+				"maintenance.windows[0].timezone",
+				"maintenance.windows[0]",
+				"maintenance.windows[0].mode",
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			configuration := valid()
+			testCase.modify(&configuration)
+
+			var locations, warnings []string
+			problems := configuration.Validate()
+			for _, problem := range problems {
+				if problem.Warning {
+					warnings = append(warnings, problem.Location)
+				} else {
+					locations = append(locations, problem.Location)
+				}
+			}
+
+			if !slices.Equal(locations, testCase.expectedLocations) {
+				t.Errorf("expected errors at %v, got %v", testCase.expectedLocations, problems)
+			}
+			if !slices.Equal(warnings, testCase.expectedWarnings) {
+				t.Errorf("expected warnings at %v, got %v", testCase.expectedWarnings, problems)
+			}
+			if (problems.Err() != nil) != (len(testCase.expectedLocations) > 0) {
+				t.Errorf("unexpected error: %v", problems.Err())
+			}
+		})
+	}
+}
@@ -1,6 +1,10 @@
 package roselite
 
 import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
 	"net/url"
 	"strconv"
 	"time"
@@ -44,9 +48,44 @@ func HeartbeatFromQuery(query url.Values) Heartbeat {
 	}
 }
 
+// HeartbeatFromRequest reads the heartbeat from the request body if it is a JSON or form-encoded body,
+// otherwise it falls back to the query string.
+func HeartbeatFromRequest(r *http.Request) (Heartbeat, error) {
+	if r.Body == nil || r.ContentLength == 0 {
+		return HeartbeatFromQuery(r.URL.Query()), nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/json":
+		heartbeat := Heartbeat{Status: HeartbeatStatusUnknown}
+		if err := json.NewDecoder(r.Body).Decode(&heartbeat); err != nil {
+			return Heartbeat{}, fmt.Errorf("decoding json body: %w", err)
+		}
+
+		return heartbeat, nil
+	case "application/x-www-form-urlencoded":
+		if err := r.ParseForm(); err != nil {
+			return Heartbeat{}, fmt.Errorf("parsing form body: %w", err)
+		}
+
+		// r.Form contains both the body and the query string, the body takes precedence.
+		return HeartbeatFromQuery(r.Form), nil
+	default:
+		return HeartbeatFromQuery(r.URL.Query()), nil
+	}
+}
+
 func (h Heartbeat) ToQuery() url.Values {
+	status := h.Status
+	// Uptime Kuma's push endpoint treats any status but up as down. A monitor under maintenance must not alert, so it
+	// is pushed as up, and its message tells the maintenance apart.
+	if status == HeartbeatStatusMaintenance {
+		status = HeartbeatStatusUp
+	}
+
 	query := url.Values{}
-	query.Set("status", h.Status.String())
+	query.Set("status", status.String())
 	query.Set("ping", strconv.FormatInt(h.Latency, 10))
 	if h.AdditionalMessage.Valid {
 		query.Set("msg", h.AdditionalMessage.ValueOrZero())
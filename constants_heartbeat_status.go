@@ -1,12 +1,22 @@
 package roselite
 
-import "strings"
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
 
 type HeartbeatStatus uint8
 
 const (
 	HeartbeatStatusUp HeartbeatStatus = iota
 	HeartbeatStatusDown
+	// HeartbeatStatusPending is a degraded state that is not down yet. Uptime Kuma treats it as down on its push
+	// endpoint.
+	HeartbeatStatusPending
+	// HeartbeatStatusMaintenance is a monitor under a maintenance window or a silence. It is pushed as up to Uptime
+	// Kuma, see Heartbeat.ToQuery.
+	HeartbeatStatusMaintenance
 	HeartbeatStatusUnknown HeartbeatStatus = 255
 )
 
@@ -16,6 +26,10 @@ func (h HeartbeatStatus) String() string {
 		return "up"
 	case HeartbeatStatusDown:
 		return "down"
+	case HeartbeatStatusPending:
+		return "pending"
+	case HeartbeatStatusMaintenance:
+		return "maintenance"
 	default:
 		return ""
 	}
@@ -27,6 +41,10 @@ func HeartbeatStatusFromString(s string) HeartbeatStatus {
 		return HeartbeatStatusUp
 	case "down":
 		return HeartbeatStatusDown
+	case "pending":
+		return HeartbeatStatusPending
+	case "maintenance":
+		return HeartbeatStatusMaintenance
 	default:
 		return HeartbeatStatusUnknown
 	}
@@ -35,3 +53,13 @@ func HeartbeatStatusFromString(s string) HeartbeatStatus {
 func (h HeartbeatStatus) MarshalJSON() ([]byte, error) {
 	return []byte(`"` + h.String() + `"`), nil
 }
+
+func (h *HeartbeatStatus) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("heartbeat status must be a string: %w", err)
+	}
+
+	*h = HeartbeatStatusFromString(s)
+	return nil
+}
@@ -20,6 +20,14 @@ func TestHeartbeatStatus_String(t *testing.T) {
 			status:   roselite.HeartbeatStatusDown,
 			expected: "down",
 		},
+		{
+			status:   roselite.HeartbeatStatusPending,
+			expected: "pending",
+		},
+		{
+			status:   roselite.HeartbeatStatusMaintenance,
+			expected: "maintenance",
+		},
 		{
 			status:   roselite.HeartbeatStatusUnknown,
 			expected: "",
@@ -50,6 +58,14 @@ func TestHeartbeatStatusFromString(t *testing.T) {
 			status:   "down",
 			expected: roselite.HeartbeatStatusDown,
 		},
+		{
+			status:   "Pending",
+			expected: roselite.HeartbeatStatusPending,
+		},
+		{
+			status:   "maintenance",
+			expected: roselite.HeartbeatStatusMaintenance,
+		},
 		{
 			status:   "unknown",
 			expected: roselite.HeartbeatStatusUnknown,
@@ -85,3 +101,47 @@ func TestHeartbeatStatus_MarshalJSON(t *testing.T) {
 		}
 	}
 }
+
+func TestHeartbeatStatus_UnmarshalJSON(t *testing.T) {
+	testCases := []struct {
+		input       string
+		expected    roselite.HeartbeatStatus
+		expectError bool
+	}{
+		{
+			input:    "\"up\"",
+			expected: roselite.HeartbeatStatusUp,
+		},
+		{
+			input:    "\"DOWN\"",
+			expected: roselite.HeartbeatStatusDown,
+		},
+		{
+			input:    "\"something\"",
+			expected: roselite.HeartbeatStatusUnknown,
+		},
+		{
+			input:       "1",
+			expectError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		var status roselite.HeartbeatStatus
+		err := status.UnmarshalJSON([]byte(testCase.input))
+		if testCase.expectError {
+			if err == nil {
+				t.Errorf("expected error for %s, got nil", testCase.input)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+
+		if status != testCase.expected {
+			t.Errorf("expected %s, got %s", testCase.expected, status)
+		}
+	}
+}
@@ -1,7 +1,10 @@
 package roselite_test
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -145,4 +148,94 @@ func TestHeartbeat_ToQuery(t *testing.T) {
 			t.Errorf("expected tls expiry date to be 1677721600, got %s", query.Get("tls_expiry"))
 		}
 	})
+
+	t.Run("Maintenance", func(t *testing.T) {
+		heartbeat := roselite.Heartbeat{
+			Status:            roselite.HeartbeatStatusMaintenance,
+			AdditionalMessage: null.NewString("under maintenance", true),
+		}
+
+		query := heartbeat.ToQuery()
+		if query.Get("status") != "up" {
+			t.Errorf("expected status to be up, got %s", query.Get("status"))
+		}
+		if query.Get("msg") != "under maintenance" {
+			t.Errorf("expected message to be under maintenance, got %s", query.Get("msg"))
+		}
+	})
+}
+
+func TestHeartbeatFromRequest(t *testing.T) {
+	t.Run("JSON body", func(t *testing.T) {
+		body := `{"status":"down","latency":100,"additional_message":"connection refused","tls_version":"TLS 1.3","tls_expiry_date":"2023-03-02T01:46:40Z"}`
+		request := httptest.NewRequest(http.MethodPost, "/api/push/12?status=up", strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+		heartbeat, err := roselite.HeartbeatFromRequest(request)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if heartbeat.Status != roselite.HeartbeatStatusDown {
+			t.Errorf("expected status to be down, got %s", heartbeat.Status)
+		}
+		if heartbeat.Latency != 100 {
+			t.Errorf("expected latency to be 100, got %d", heartbeat.Latency)
+		}
+		if heartbeat.AdditionalMessage.ValueOrZero() != "connection refused" {
+			t.Errorf("expected additional message to be connection refused, got %s", heartbeat.AdditionalMessage.ValueOrZero())
+		}
+		if heartbeat.TLSVersion.ValueOrZero() != "TLS 1.3" {
+			t.Errorf("expected tls version to be TLS 1.3, got %s", heartbeat.TLSVersion.ValueOrZero())
+		}
+		if heartbeat.TLSExpiryDate.ValueOrZero().Unix() != 1677721600 {
+			t.Errorf("expected tls expiry date to be 1677721600, got %d", heartbeat.TLSExpiryDate.ValueOrZero().Unix())
+		}
+	})
+
+	t.Run("Invalid JSON body", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodPost, "/api/push/12", strings.NewReader(`{"status":1}`))
+		request.Header.Set("Content-Type", "application/json")
+
+		_, err := roselite.HeartbeatFromRequest(request)
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("Form body", func(t *testing.T) {
+		body := url.Values{}
+		body.Set("status", "down")
+		body.Set("msg", "connection refused")
+		request := httptest.NewRequest(http.MethodPost, "/api/push/12?status=up&ping=5", strings.NewReader(body.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		heartbeat, err := roselite.HeartbeatFromRequest(request)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if heartbeat.Status != roselite.HeartbeatStatusDown {
+			t.Errorf("expected status to be down, got %s", heartbeat.Status)
+		}
+		if heartbeat.Latency != 5 {
+			t.Errorf("expected latency to be 5, got %d", heartbeat.Latency)
+		}
+		if heartbeat.AdditionalMessage.ValueOrZero() != "connection refused" {
+			t.Errorf("expected additional message to be connection refused, got %s", heartbeat.AdditionalMessage.ValueOrZero())
+		}
+	})
+
+	t.Run("Query string", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodGet, "/api/push/12?status=up&ping=5", nil)
+
+		heartbeat, err := roselite.HeartbeatFromRequest(request)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if heartbeat.Status != roselite.HeartbeatStatusUp {
+			t.Errorf("expected status to be up, got %s", heartbeat.Status)
+		}
+		if heartbeat.Latency != 5 {
+			t.Errorf("expected latency to be 5, got %d", heartbeat.Latency)
+		}
+	})
 }
@@ -0,0 +1,64 @@
+package roselite
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrMaintenanceModeInvalid = errors.New("invalid maintenance mode")
+
+// MaintenanceMode tells what happens to a monitor under a maintenance window or a silence.
+type MaintenanceMode uint8
+
+const (
+	// MaintenanceModePush still checks the monitor, then pushes its heartbeat with the maintenance status.
+	MaintenanceModePush MaintenanceMode = iota
+	// MaintenanceModeSkip neither checks the monitor nor pushes anything. Uptime Kuma reports a push monitor as down
+	// once it misses its heartbeats, unless the monitor is under a maintenance of Uptime Kuma too.
+	MaintenanceModeSkip
+	MaintenanceModeUnknown MaintenanceMode = 255
+)
+
+func (m MaintenanceMode) String() string {
+	switch m {
+	case MaintenanceModePush:
+		return "push"
+	case MaintenanceModeSkip:
+		return "skip"
+	default:
+		return ""
+	}
+}
+
+// MaintenanceModeFromString parses a maintenance mode, an empty string is MaintenanceModePush.
+func MaintenanceModeFromString(s string) (MaintenanceMode, error) {
+	switch strings.ToLower(s) {
+	case "", "push":
+		return MaintenanceModePush, nil
+	case "skip":
+		return MaintenanceModeSkip, nil
+	default:
+		return MaintenanceModeUnknown, ErrMaintenanceModeInvalid
+	}
+}
+
+func (m MaintenanceMode) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + m.String() + `"`), nil
+}
+
+func (m *MaintenanceMode) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("maintenance mode must be a string: %w", err)
+	}
+
+	mode, err := MaintenanceModeFromString(s)
+	if err != nil {
+		return fmt.Errorf("%w: %q", err, s)
+	}
+
+	*m = mode
+	return nil
+}
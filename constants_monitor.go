@@ -2,6 +2,8 @@ package roselite
 
 import (
 	"crypto/tls"
+	"fmt"
+	"strconv"
 	"time"
 )
 
@@ -13,5 +15,43 @@ type Monitor struct {
 	RequestHeaders       map[string]string `json:"request_headers" toml:"request_headers" yaml:"request_headers"`
 	TLSConfig            *tls.Config       `json:"tls_config" toml:"tls_config" yaml:"tls_config"`
 	Interval             time.Duration     `json:"interval" toml:"interval" yaml:"interval"`
+	Timeout              time.Duration     `json:"timeout" toml:"timeout" yaml:"timeout"`
 	EnableSentrySampling bool              `json:"enable_sentry_sampling" toml:"enable_sentry_sampling" yaml:"enable_sentry_sampling"`
+	Tags                 []string          `json:"tags" toml:"tags" yaml:"tags"`
+	// Region overrides the region of the agent for this monitor, such as the region of the node a discovered pod
+	// runs on.
+	Region string `json:"region" toml:"region" yaml:"region"`
+}
+
+// DefaultMonitorTimeout is the timeout of a check if Monitor.Timeout is not set, unless the interval is shorter. It is
+// the 5 minutes every check was allowed before the timeout could be configured.
+const DefaultMonitorTimeout = time.Minute * 5
+
+// ParseDuration parses a duration of a monitor, written as a Go duration string such as "30s" or "2m", or as an
+// integer number of seconds.
+func ParseDuration(value string) (time.Duration, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q, expected a duration such as \"30s\" or a number of seconds", value)
+	}
+
+	return duration, nil
+}
+
+// CheckTimeout returns the maximum duration of a single check of the monitor, excluding the push to the upstream
+// instance. If Timeout is not set, it is DefaultMonitorTimeout or the interval, whichever is shorter.
+func (m Monitor) CheckTimeout() time.Duration {
+	if m.Timeout > 0 {
+		return m.Timeout
+	}
+
+	if m.Interval > 0 {
+		return min(m.Interval, DefaultMonitorTimeout)
+	}
+
+	return DefaultMonitorTimeout
 }
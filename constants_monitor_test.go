@@ -0,0 +1,42 @@
+package roselite_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/teknologi-umum/roselite"
+)
+
+func TestMonitor_CheckTimeout(t *testing.T) {
+	testCases := []struct {
+		monitor  roselite.Monitor
+		expected time.Duration
+	}{
+		{
+			monitor:  roselite.Monitor{Interval: time.Minute, Timeout: time.Second * 10},
+			expected: time.Second * 10,
+		},
+		{
+			monitor:  roselite.Monitor{Interval: time.Minute * 10},
+			expected: time.Minute * 5,
+		},
+		{
+			monitor:  roselite.Monitor{Interval: time.Minute},
+			expected: time.Minute,
+		},
+		{
+			monitor:  roselite.Monitor{Interval: time.Second * 10},
+			expected: time.Second * 10,
+		},
+		{
+			monitor:  roselite.Monitor{},
+			expected: time.Minute * 5,
+		},
+	}
+
+	for _, testCase := range testCases {
+		if testCase.monitor.CheckTimeout() != testCase.expected {
+			t.Errorf("expected %s, got %s", testCase.expected, testCase.monitor.CheckTimeout())
+		}
+	}
+}
@@ -3,6 +3,7 @@ package roselite
 import (
 	"errors"
 	"strings"
+	"time"
 )
 
 var ErrMonitorTypeInvalid = errors.New("invalid monitor type")
@@ -36,3 +37,16 @@ func MonitorTypeFromString(s string) (MonitorType, error) {
 		return MonitorTypeUnknown, ErrMonitorTypeInvalid
 	}
 }
+
+// icmpPacketCount is the amount of echo requests sent on every ICMP check, one second apart.
+const icmpPacketCount = 3
+
+// MinimumCheckDuration returns how long a check of the monitor type takes at the very least.
+func MinimumCheckDuration(monitorType MonitorType) time.Duration {
+	switch monitorType {
+	case MonitorTypeICMP:
+		return time.Second * (icmpPacketCount - 1)
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,190 @@
+package roselite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// discoveryMinimumBackoff is the delay before a failed discovery is restarted, doubled on every failure in a row.
+	discoveryMinimumBackoff = time.Second
+	// discoveryMaximumBackoff is the longest delay before a failed discovery is restarted.
+	discoveryMaximumBackoff = time.Minute
+)
+
+// DefaultMonitorInterval is the interval of a monitor that does not set one.
+const DefaultMonitorInterval = time.Second * 30
+
+// MinimumMonitorInterval is the shortest interval a monitor can be checked at, so a mistyped unit does not flood the
+// target.
+const MinimumMonitorInterval = time.Second
+
+// Discoverer finds monitors on a dynamic source, such as the running containers of a host.
+type Discoverer interface {
+	// Name identifies the source on the logs, and tells the monitors of different sources apart.
+	Name() string
+	// Run watches the source until the context is done, calling update with every monitor of the source each time
+	// they change. A discoverer that fails returns an error, and is restarted by Agent.RunDiscovery.
+	Run(ctx context.Context, update func(monitors []Monitor)) error
+}
+
+// RunDiscovery checks the monitors found by the discoverer along with the monitors given to UpdateMonitors, until
+// the context is done or the agent is shut down. The discoverer is restarted with a backoff when it fails, and its
+// last monitors are kept checked in the meantime.
+func (a *Agent) RunDiscovery(ctx context.Context, discoverer Discoverer) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(a.shutdownCtx, cancel)
+	defer stop()
+
+	backoff := discoveryMinimumBackoff
+	for {
+		startedAt := time.Now()
+		err := discoverer.Run(ctx, func(monitors []Monitor) {
+			a.UpdateDiscoveredMonitors(discoverer.Name(), monitors)
+		})
+		if ctx.Err() != nil {
+			return
+		}
+
+		// A discoverer that ran for a while before failing is not failing in a row.
+		if time.Since(startedAt) > discoveryMaximumBackoff {
+			backoff = discoveryMinimumBackoff
+		}
+		if err == nil {
+			err = errors.New("stopped unexpectedly")
+		}
+		slog.Warn("monitor discovery failed, restarting",
+			slog.String("source", discoverer.Name()),
+			slog.String("error", err.Error()),
+			slog.Duration("backoff", backoff))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, discoveryMaximumBackoff)
+	}
+}
+
+// validateDiscoveredMonitor reports why a discovered monitor cannot be checked. Monitors from the configuration
+// file are validated before they reach the agent, discovered monitors are not.
+func validateDiscoveredMonitor(monitor Monitor) error {
+	if monitor.ID == "" {
+		return errors.New("missing id")
+	}
+
+	if _, err := CallerFor(monitor.MonitorType); err != nil {
+		return err
+	}
+
+	if monitor.MonitorTarget == "" {
+		return errors.New("missing target")
+	}
+
+	if monitor.Interval < MinimumMonitorInterval {
+		return fmt.Errorf("invalid interval %s, the minimum is %s", monitor.Interval, MinimumMonitorInterval)
+	}
+
+	if monitor.Timeout < 0 {
+		return fmt.Errorf("invalid timeout %s", monitor.Timeout)
+	}
+
+	return nil
+}
+
+// discoveredMonitorOptions are the options of a discovered monitor, as written on the labels or the annotations
+// of the source.
+type discoveredMonitorOptions struct {
+	ID             string
+	MonitorType    string
+	MonitorTarget  string
+	Interval       string
+	Timeout        string
+	Tags           string
+	Region         string
+	RequestHeaders map[string]string
+}
+
+// toMonitor returns the monitor with the options that are set, taking the other ones from defaults. The {{name}}
+// placeholders on the ID and the target are replaced by the given values.
+func (o discoveredMonitorOptions) toMonitor(defaults Monitor, placeholders map[string]string) (Monitor, error) {
+	monitor := defaults
+	monitor.RequestHeaders = maps.Clone(defaults.RequestHeaders)
+	monitor.Tags = slices.Clone(defaults.Tags)
+
+	// A container without a network or a headless service has no address, the target would never be reachable.
+	if placeholders["ip"] == "" && (strings.Contains(o.ID, "{{ip}}") || strings.Contains(o.MonitorTarget, "{{ip}}")) {
+		return Monitor{}, errors.New("no IP address to replace {{ip}} with")
+	}
+
+	monitor.ID = expandPlaceholders(o.ID, placeholders)
+
+	if o.MonitorType != "" {
+		monitorType, err := MonitorTypeFromString(o.MonitorType)
+		if err != nil {
+			return Monitor{}, fmt.Errorf("%w: %s", err, o.MonitorType)
+		}
+		monitor.MonitorType = monitorType
+	}
+
+	monitor.MonitorTarget = expandPlaceholders(o.MonitorTarget, placeholders)
+	if monitor.MonitorTarget == "" && monitor.MonitorType == MonitorTypeICMP {
+		monitor.MonitorTarget = placeholders["ip"]
+	}
+
+	if o.Interval != "" {
+		interval, err := ParseDuration(o.Interval)
+		if err != nil {
+			return Monitor{}, fmt.Errorf("invalid interval: %w", err)
+		}
+		monitor.Interval = interval
+	}
+	if monitor.Interval == 0 {
+		monitor.Interval = DefaultMonitorInterval
+	}
+
+	if o.Timeout != "" {
+		timeout, err := ParseDuration(o.Timeout)
+		if err != nil {
+			return Monitor{}, fmt.Errorf("invalid timeout: %w", err)
+		}
+		monitor.Timeout = timeout
+	}
+
+	for tag := range strings.SplitSeq(o.Tags, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag != "" && !slices.Contains(monitor.Tags, tag) {
+			monitor.Tags = append(monitor.Tags, tag)
+		}
+	}
+
+	if o.Region != "" {
+		monitor.Region = o.Region
+	}
+
+	if len(o.RequestHeaders) > 0 {
+		if monitor.RequestHeaders == nil {
+			monitor.RequestHeaders = make(map[string]string, len(o.RequestHeaders))
+		}
+		maps.Copy(monitor.RequestHeaders, o.RequestHeaders)
+	}
+
+	return monitor, validateDiscoveredMonitor(monitor)
+}
+
+// expandPlaceholders replaces every {{name}} on s with its value. Unknown placeholders are kept as is.
+func expandPlaceholders(s string, placeholders map[string]string) string {
+	for name, value := range placeholders {
+		s = strings.ReplaceAll(s, "{{"+name+"}}", value)
+	}
+
+	return s
+}
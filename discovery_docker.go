@@ -0,0 +1,291 @@
+package roselite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultDockerHost is the address of the Docker Engine API if DockerDiscovery.Host is not set.
+	DefaultDockerHost = "unix:///var/run/docker.sock"
+	// DefaultDockerLabelPrefix is the prefix of the container labels if DockerDiscovery.LabelPrefix is not set.
+	DefaultDockerLabelPrefix = "roselite"
+
+	// dockerDebounceDelay is how long the containers are listed after an event, so a burst of events such as a
+	// `docker compose up` results in a single update.
+	dockerDebounceDelay = time.Millisecond * 500
+)
+
+// DockerDiscovery discovers monitors from the labels of the running containers of a Docker host, through the
+// Docker Engine API over its unix socket. The containers are listed again every time one of them starts, stops,
+// or is connected to a network.
+//
+// A container is monitored once it has the <prefix>.id label. The other labels are optional:
+//
+//	roselite.id=<push token>
+//	roselite.type=http
+//	roselite.target=http://{{ip}}:8080/health
+//	roselite.interval=30s
+//	roselite.timeout=10s
+//	roselite.tags=production,billing
+//	roselite.region=jakarta
+//	roselite.header.Authorization=Bearer token
+//
+// The {{ip}}, {{name}} and {{id}} placeholders of the ID and the target are replaced by the IP address, the name and
+// the short ID of the container. An ICMP monitor without a target checks the IP address of the container.
+type DockerDiscovery struct {
+	// Host is the unix socket of the Docker Engine API, as unix:///path or as a path. Defaults to DefaultDockerHost.
+	Host string
+	// Network is the network whose IP address replaces {{ip}}. Defaults to the first network of the container,
+	// sorted by name.
+	Network string
+	// LabelPrefix defaults to DefaultDockerLabelPrefix.
+	LabelPrefix string
+	// Defaults holds the options of the monitors that their labels do not set.
+	Defaults Monitor
+}
+
+var _ Discoverer = (*DockerDiscovery)(nil)
+
+type dockerContainer struct {
+	ID              string            `json:"Id"`
+	Names           []string          `json:"Names"`
+	Labels          map[string]string `json:"Labels"`
+	NetworkSettings struct {
+		Networks map[string]struct {
+			IPAddress string `json:"IPAddress"`
+		} `json:"Networks"`
+	} `json:"NetworkSettings"`
+}
+
+func (d *DockerDiscovery) Name() string {
+	return "docker"
+}
+
+func (d *DockerDiscovery) Run(ctx context.Context, update func(monitors []Monitor)) error {
+	socket := strings.TrimPrefix(d.host(), "unix://")
+	if strings.Contains(socket, "://") {
+		return fmt.Errorf("unsupported Docker host %s, only unix sockets are supported", d.host())
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _ string, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+	defer client.CloseIdleConnections()
+
+	// The events are watched before the containers are listed, so no container that starts in between is missed.
+	events, err := d.watchEvents(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	debounce := time.NewTimer(0)
+	defer debounce.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-events:
+			if err != nil {
+				return err
+			}
+			debounce.Reset(dockerDebounceDelay)
+		case <-debounce.C:
+			monitors, err := d.listMonitors(ctx, client)
+			if err != nil {
+				return err
+			}
+			update(monitors)
+		}
+	}
+}
+
+func (d *DockerDiscovery) host() string {
+	if d.Host == "" {
+		return DefaultDockerHost
+	}
+
+	return d.Host
+}
+
+func (d *DockerDiscovery) labelPrefix() string {
+	if d.LabelPrefix == "" {
+		return DefaultDockerLabelPrefix
+	}
+
+	return d.LabelPrefix
+}
+
+// watchEvents streams the container and network events. The channel receives nil for every event, and the error
+// that ended the stream.
+func (d *DockerDiscovery) watchEvents(ctx context.Context, client *http.Client) (<-chan error, error) {
+	filters, err := json.Marshal(map[string][]string{
+		"type":  {"container", "network"},
+		"event": {"start", "die", "destroy", "rename", "connect", "disconnect"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("encoding filters: %w", err)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://docker/events?filters="+url.QueryEscape(string(filters)), nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("watching events: %w", err)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		_ = response.Body.Close()
+		return nil, fmt.Errorf("watching events: unexpected status code: %d", response.StatusCode)
+	}
+
+	events := make(chan error)
+	go func() {
+		defer func() {
+			_ = response.Body.Close()
+		}()
+
+		decoder := json.NewDecoder(response.Body)
+		for {
+			var event json.RawMessage
+			err := decoder.Decode(&event)
+			if err != nil && ctx.Err() == nil {
+				err = fmt.Errorf("watching events: %w", err)
+			}
+
+			select {
+			case events <- err:
+			case <-ctx.Done():
+				return
+			}
+
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+func (d *DockerDiscovery) listMonitors(ctx context.Context, client *http.Client) ([]Monitor, error) {
+	filters, err := json.Marshal(map[string][]string{
+		"label":  {d.labelPrefix() + ".id"},
+		"status": {"running"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("encoding filters: %w", err)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://docker/containers/json?filters="+url.QueryEscape(string(filters)), nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("listing containers: %w", err)
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("listing containers: unexpected status code: %d", response.StatusCode)
+	}
+
+	var containers []dockerContainer
+	if err := json.NewDecoder(response.Body).Decode(&containers); err != nil {
+		return nil, fmt.Errorf("decoding containers: %w", err)
+	}
+
+	monitors := make([]Monitor, 0, len(containers))
+	for _, container := range containers {
+		monitor, err := d.containerMonitor(container)
+		if err != nil {
+			// A container with invalid labels must not prevent the other ones from being monitored.
+			slog.Warn("skipping container with invalid labels",
+				slog.String("source", d.Name()),
+				slog.String("container", d.containerName(container)),
+				slog.String("error", err.Error()))
+			continue
+		}
+
+		monitors = append(monitors, monitor)
+	}
+
+	return monitors, nil
+}
+
+func (d *DockerDiscovery) containerMonitor(container dockerContainer) (Monitor, error) {
+	prefix := d.labelPrefix() + "."
+	options := discoveredMonitorOptions{
+		ID:            container.Labels[prefix+"id"],
+		MonitorType:   container.Labels[prefix+"type"],
+		MonitorTarget: container.Labels[prefix+"target"],
+		Interval:      container.Labels[prefix+"interval"],
+		Timeout:       container.Labels[prefix+"timeout"],
+		Tags:          container.Labels[prefix+"tags"],
+		Region:        container.Labels[prefix+"region"],
+	}
+	for label, value := range container.Labels {
+		if header, ok := strings.CutPrefix(label, prefix+"header."); ok && header != "" {
+			if options.RequestHeaders == nil {
+				options.RequestHeaders = make(map[string]string)
+			}
+			options.RequestHeaders[header] = value
+		}
+	}
+
+	shortID := container.ID
+	if len(shortID) > 12 {
+		shortID = shortID[:12]
+	}
+
+	return options.toMonitor(d.Defaults, map[string]string{
+		"ip":   d.containerIP(container),
+		"name": d.containerName(container),
+		"id":   shortID,
+	})
+}
+
+func (d *DockerDiscovery) containerName(container dockerContainer) string {
+	if len(container.Names) == 0 {
+		return container.ID
+	}
+
+	return strings.TrimPrefix(container.Names[0], "/")
+}
+
+func (d *DockerDiscovery) containerIP(container dockerContainer) string {
+	networks := container.NetworkSettings.Networks
+	if d.Network != "" {
+		return networks[d.Network].IPAddress
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(networks)) {
+		if ip := networks[name].IPAddress; ip != "" {
+			return ip
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,188 @@
+package roselite_test
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/teknologi-umum/roselite"
+)
+
+// fakeDockerEngine serves the parts of the Docker Engine API used by DockerDiscovery on a unix socket.
+type fakeDockerEngine struct {
+	mutex      sync.Mutex
+	containers []map[string]any
+	events     chan struct{}
+}
+
+func (f *fakeDockerEngine) setContainers(containers ...map[string]any) {
+	f.mutex.Lock()
+	f.containers = containers
+	f.mutex.Unlock()
+}
+
+func (f *fakeDockerEngine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/containers/json":
+		if !strings.Contains(r.URL.Query().Get("filters"), "roselite.id") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		f.mutex.Lock()
+		defer f.mutex.Unlock()
+		_ = json.NewEncoder(w).Encode(f.containers)
+	case "/events":
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-f.events:
+				_, _ = w.Write([]byte(`{"Type":"container","Action":"start"}` + "\n"))
+				w.(http.Flusher).Flush()
+			}
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func dockerContainer(id string, name string, ip string, labels map[string]string) map[string]any {
+	return map[string]any{
+		"Id":     id,
+		"Names":  []string{"/" + name},
+		"Labels": labels,
+		"NetworkSettings": map[string]any{
+			"Networks": map[string]any{"bridge": map[string]any{"IPAddress": ip}},
+		},
+	}
+}
+
+func waitFor(t *testing.T, description string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second * 5)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", description)
+		}
+		time.Sleep(time.Millisecond * 20)
+	}
+}
+
+func TestDockerDiscovery(t *testing.T) {
+	// Unix socket paths are limited to about a hundred characters, which a test's temporary directory may exceed.
+	dir, err := os.MkdirTemp("", "roselite")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	socket := filepath.Join(dir, "docker.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	engine := &fakeDockerEngine{events: make(chan struct{})}
+	engine.setContainers(
+		dockerContainer("0123456789abcdef", "web", "172.17.0.2", map[string]string{
+			"roselite.id":                   "web-token",
+			"roselite.target":               "http://{{ip}}:8080/health?container={{name}}",
+			"roselite.interval":             "3600",
+			"roselite.tags":                 "production, web",
+			"roselite.header.Authorization": "Bearer token",
+		}),
+		dockerContainer("fedcba9876543210", "db", "172.17.0.3", map[string]string{
+			"roselite.id":   "db-{{id}}",
+			"roselite.type": "icmp",
+		}),
+		dockerContainer("aaaaaaaaaaaaaaaa", "broken", "172.17.0.4", map[string]string{
+			"roselite.id":       "broken-token",
+			"roselite.interval": "soon",
+		}),
+		// Without a network, the target would be http://:8080/health.
+		dockerContainer("bbbbbbbbbbbbbbbb", "isolated", "", map[string]string{
+			"roselite.id":     "isolated-token",
+			"roselite.target": "http://{{ip}}:8080/health",
+		}),
+	)
+
+	server := &http.Server{Handler: engine}
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(func() { _ = server.Close() })
+
+	store := roselite.NewMonitorStore(0)
+	agent := roselite.NewAgent(roselite.AgentOptions{
+		Monitors: []roselite.Monitor{
+			{ID: "static", MonitorType: roselite.MonitorTypeHTTP, MonitorTarget: "http://127.0.0.1:1", Interval: time.Hour},
+			// Taken by the configuration file, the discovered monitor with the same ID is skipped.
+			{ID: "db-fedcba987654", MonitorType: roselite.MonitorTypeHTTP, MonitorTarget: "http://127.0.0.1:1", Interval: time.Hour},
+		},
+		UpstreamKumaAddress: "http://127.0.0.1:1",
+		MonitorStore:        store,
+	})
+	t.Cleanup(func() { _ = agent.Close() })
+
+	discovery := &roselite.DockerDiscovery{
+		Host:     "unix://" + socket,
+		Defaults: roselite.Monitor{Interval: time.Hour, Tags: []string{"docker"}},
+	}
+	go agent.RunDiscovery(t.Context(), discovery)
+
+	monitorIDs := func() []string {
+		var ids []string
+		for _, summary := range store.Monitors() {
+			ids = append(ids, summary.ID)
+		}
+		slices.Sort(ids)
+		return ids
+	}
+
+	waitFor(t, "the web container to be discovered", func() bool {
+		return slices.Contains(monitorIDs(), "web-token")
+	})
+	if ids := monitorIDs(); !slices.Equal(ids, []string{"db-fedcba987654", "static", "web-token"}) {
+		t.Errorf("unexpected monitors: %v", ids)
+	}
+
+	summary, _, _ := store.Monitor("web-token", 0)
+	if expected := []string{"docker", "production", "web"}; !slices.Equal(summary.Tags, expected) {
+		t.Errorf("expected tags %v, got %v", expected, summary.Tags)
+	}
+	if summary.Interval != time.Hour.String() {
+		t.Errorf("expected interval %s, got %s", time.Hour, summary.Interval)
+	}
+
+	// The web container stops, and a container is started with the ID the configuration file no longer takes.
+	engine.setContainers(
+		dockerContainer("fedcba9876543210", "db", "172.17.0.3", map[string]string{
+			"roselite.id":   "db-{{id}}",
+			"roselite.type": "icmp",
+		}),
+	)
+	agent.UpdateMonitors([]roselite.Monitor{
+		{ID: "static", MonitorType: roselite.MonitorTypeHTTP, MonitorTarget: "http://127.0.0.1:1", Interval: time.Hour},
+	})
+	engine.events <- struct{}{}
+
+	waitFor(t, "the web container to be removed", func() bool {
+		return !slices.Contains(monitorIDs(), "web-token")
+	})
+	if ids := monitorIDs(); !slices.Equal(ids, []string{"db-fedcba987654", "static"}) {
+		t.Errorf("unexpected monitors: %v", ids)
+	}
+
+	summary, _, _ = store.Monitor("db-fedcba987654", 0)
+	if summary.MonitorType != roselite.MonitorTypeICMP.String() {
+		t.Errorf("expected the discovered ICMP monitor to replace the configured one, got %s", summary.MonitorType)
+	}
+}
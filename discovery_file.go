@@ -0,0 +1,240 @@
+package roselite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// DefaultFileDiscoveryRefreshInterval is how often the target files are checked if
+	// FileDiscovery.RefreshInterval is not set.
+	DefaultFileDiscoveryRefreshInterval = time.Second * 30
+
+	// fileDiscoveryLabelPrefix is the prefix of the labels that define a monitor. Prometheus drops the labels that
+	// start with two underscores, so the same files can be used by both without adding labels to the scraped series.
+	fileDiscoveryLabelPrefix = "__roselite_"
+)
+
+// FileDiscovery discovers monitors from target files in the format of Prometheus' file_sd, a list of groups of
+// targets sharing the same labels, written in JSON or YAML:
+//
+//	[
+//	  {
+//	    "targets": ["billing.internal:8080"],
+//	    "labels": {
+//	      "__roselite_id": "<push token>",
+//	      "__roselite_type": "http",
+//	      "__roselite_target": "http://{{target}}/health",
+//	      "__roselite_interval": "30s",
+//	      "__roselite_timeout": "10s",
+//	      "__roselite_tags": "production,billing",
+//	      "__roselite_region": "jakarta"
+//	    }
+//	  }
+//	]
+//
+// Every target of a group with the __roselite_id label is a monitor. The {{target}}, {{host}} and {{port}}
+// placeholders of the ID and the target are replaced by the target as written, its host and its port. Without a
+// __roselite_target label, an HTTP monitor checks http://{{target}} and an ICMP monitor checks {{host}}. A push token
+// is unique to a monitor, so a group with a fixed ID should only have a single target.
+//
+// The files are checked for modifications every refresh interval. A file that cannot be read or parsed keeps its
+// previous monitors.
+type FileDiscovery struct {
+	// Files are the paths or the glob patterns of the target files. Files ending with .json are parsed as JSON, the
+	// other ones as YAML.
+	Files []string
+	// RefreshInterval defaults to DefaultFileDiscoveryRefreshInterval.
+	RefreshInterval time.Duration
+	// Defaults holds the options of the monitors that their labels do not set.
+	Defaults Monitor
+}
+
+var _ Discoverer = (*FileDiscovery)(nil)
+
+type fileTargetGroup struct {
+	Targets []string          `json:"targets" yaml:"targets"`
+	Labels  map[string]string `json:"labels" yaml:"labels"`
+}
+
+// fileDiscoveryState is the last version of a target file that was read.
+type fileDiscoveryState struct {
+	modifiedAt time.Time
+	size       int64
+	monitors   []Monitor
+}
+
+func (d *FileDiscovery) Name() string {
+	return "file_sd"
+}
+
+func (d *FileDiscovery) Run(ctx context.Context, update func(monitors []Monitor)) error {
+	refreshInterval := d.RefreshInterval
+	if refreshInterval <= 0 {
+		refreshInterval = DefaultFileDiscoveryRefreshInterval
+	}
+
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+
+	files := make(map[string]fileDiscoveryState)
+	for {
+		changed, err := d.refresh(files)
+		if err != nil {
+			return err
+		}
+
+		if changed {
+			var monitors []Monitor
+			for _, path := range slices.Sorted(maps.Keys(files)) {
+				monitors = append(monitors, files[path].monitors...)
+			}
+			update(monitors)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// refresh reads the files that were added or modified since the last refresh, and forgets the removed ones. It
+// reports whether any monitor may have changed.
+func (d *FileDiscovery) refresh(files map[string]fileDiscoveryState) (bool, error) {
+	paths := make(map[string]struct{})
+	for _, pattern := range d.Files {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return false, fmt.Errorf("matching %s: %w", pattern, err)
+		}
+
+		for _, match := range matches {
+			paths[match] = struct{}{}
+		}
+	}
+
+	changed := false
+	for path := range files {
+		if _, ok := paths[path]; !ok {
+			delete(files, path)
+			changed = true
+		}
+	}
+
+	for path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+
+		previous, ok := files[path]
+		if ok && previous.modifiedAt.Equal(info.ModTime()) && previous.size == info.Size() {
+			continue
+		}
+
+		state := fileDiscoveryState{modifiedAt: info.ModTime(), size: info.Size(), monitors: previous.monitors}
+		monitors, err := d.readFile(path)
+		if err != nil {
+			slog.Warn("keeping the previous monitors of an invalid target file",
+				slog.String("source", d.Name()),
+				slog.String("file", path),
+				slog.String("error", err.Error()))
+		} else {
+			state.monitors = monitors
+		}
+
+		files[path] = state
+		changed = true
+	}
+
+	return changed, nil
+}
+
+func (d *FileDiscovery) readFile(path string) ([]Monitor, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []fileTargetGroup
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(content, &groups)
+	} else {
+		err = yaml.Unmarshal(content, &groups)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing: %w", err)
+	}
+
+	var monitors []Monitor
+	for i, group := range groups {
+		if _, ok := group.Labels[fileDiscoveryLabelPrefix+"id"]; !ok {
+			continue
+		}
+
+		for _, target := range group.Targets {
+			monitor, err := d.targetMonitor(target, group.Labels)
+			if err != nil {
+				slog.Warn("skipping target with invalid labels",
+					slog.String("source", d.Name()),
+					slog.String("file", path),
+					slog.Int("group", i),
+					slog.String("target", target),
+					slog.String("error", err.Error()))
+				continue
+			}
+
+			monitors = append(monitors, monitor)
+		}
+	}
+
+	return monitors, nil
+}
+
+func (d *FileDiscovery) targetMonitor(target string, labels map[string]string) (Monitor, error) {
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		host, port = target, ""
+	}
+
+	options := discoveredMonitorOptions{
+		ID:            labels[fileDiscoveryLabelPrefix+"id"],
+		MonitorType:   labels[fileDiscoveryLabelPrefix+"type"],
+		MonitorTarget: labels[fileDiscoveryLabelPrefix+"target"],
+		Interval:      labels[fileDiscoveryLabelPrefix+"interval"],
+		Timeout:       labels[fileDiscoveryLabelPrefix+"timeout"],
+		Tags:          labels[fileDiscoveryLabelPrefix+"tags"],
+		Region:        labels[fileDiscoveryLabelPrefix+"region"],
+	}
+
+	if options.MonitorTarget == "" {
+		monitorType := d.Defaults.MonitorType
+		if options.MonitorType != "" {
+			monitorType, _ = MonitorTypeFromString(options.MonitorType)
+		}
+
+		options.MonitorTarget = "http://{{target}}"
+		if monitorType == MonitorTypeICMP {
+			options.MonitorTarget = "{{host}}"
+		}
+	}
+
+	return options.toMonitor(d.Defaults, map[string]string{
+		"target": target,
+		"host":   host,
+		"port":   port,
+	})
+}
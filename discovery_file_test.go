@@ -0,0 +1,137 @@
+package roselite_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/teknologi-umum/roselite"
+)
+
+func TestFileDiscovery(t *testing.T) {
+	dir := t.TempDir()
+
+	writeFile := func(name string, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	writeFile("web.json", `[
+		{
+			"targets": ["billing.internal:8080"],
+			"labels": {
+				"__roselite_id": "billing-token",
+				"__roselite_target": "http://{{target}}/health",
+				"__roselite_tags": "production, billing",
+				"job": "billing"
+			}
+		},
+		{
+			"targets": ["10.0.0.5:9100", "10.0.0.6:9100"],
+			"labels": {
+				"__roselite_id": "node-{{host}}",
+				"__roselite_type": "icmp",
+				"__roselite_interval": "1m"
+			}
+		},
+		{
+			"targets": ["scraped-only.internal:9090"],
+			"labels": {"job": "prometheus"}
+		},
+		{
+			"targets": ["broken.internal"],
+			"labels": {"__roselite_id": "broken-token", "__roselite_timeout": "soon"}
+		}
+	]`)
+	writeFile("db.yml", `
+- targets: ["db.internal:5432"]
+  labels:
+    __roselite_id: db-token
+    __roselite_region: jakarta
+`)
+
+	updates := make(chan []roselite.Monitor)
+	discovery := &roselite.FileDiscovery{
+		Files:           []string{filepath.Join(dir, "*.json"), filepath.Join(dir, "*.yml")},
+		RefreshInterval: time.Millisecond * 10,
+		Defaults:        roselite.Monitor{Interval: time.Hour, Tags: []string{"file_sd"}},
+	}
+	go func() {
+		_ = discovery.Run(t.Context(), func(monitors []roselite.Monitor) {
+			select {
+			case updates <- monitors:
+			case <-t.Context().Done():
+			}
+		})
+	}()
+
+	nextUpdate := func() []roselite.Monitor {
+		t.Helper()
+		select {
+		case monitors := <-updates:
+			return monitors
+		case <-time.After(time.Second * 5):
+			t.Fatal("timed out waiting for the monitors to be updated")
+			return nil
+		}
+	}
+
+	monitors := nextUpdate()
+	byID := make(map[string]roselite.Monitor)
+	var ids []string
+	for _, monitor := range monitors {
+		byID[monitor.ID] = monitor
+		ids = append(ids, monitor.ID)
+	}
+	// The files are read in order of their paths.
+	if expected := []string{"db-token", "billing-token", "node-10.0.0.5", "node-10.0.0.6"}; !slices.Equal(ids, expected) {
+		t.Fatalf("expected monitors %v, got %v", expected, ids)
+	}
+
+	billing := byID["billing-token"]
+	if billing.MonitorType != roselite.MonitorTypeHTTP || billing.MonitorTarget != "http://billing.internal:8080/health" {
+		t.Errorf("unexpected billing monitor: %s %s", billing.MonitorType, billing.MonitorTarget)
+	}
+	if expected := []string{"file_sd", "production", "billing"}; !slices.Equal(billing.Tags, expected) {
+		t.Errorf("expected tags %v, got %v", expected, billing.Tags)
+	}
+	if billing.Interval != time.Hour {
+		t.Errorf("expected the default interval, got %s", billing.Interval)
+	}
+
+	node := byID["node-10.0.0.6"]
+	if node.MonitorType != roselite.MonitorTypeICMP || node.MonitorTarget != "10.0.0.6" || node.Interval != time.Minute {
+		t.Errorf("unexpected node monitor: %s %s %s", node.MonitorType, node.MonitorTarget, node.Interval)
+	}
+
+	db := byID["db-token"]
+	if db.MonitorTarget != "http://db.internal:5432" || db.Region != "jakarta" {
+		t.Errorf("unexpected db monitor: %s %s", db.MonitorTarget, db.Region)
+	}
+
+	monitorIDs := func(monitors []roselite.Monitor) []string {
+		var ids []string
+		for _, monitor := range monitors {
+			ids = append(ids, monitor.ID)
+		}
+		return ids
+	}
+
+	// A removed file drops its monitors.
+	if err := os.Remove(filepath.Join(dir, "db.yml")); err != nil {
+		t.Fatalf("failed to remove db.yml: %v", err)
+	}
+	if ids, expected := monitorIDs(nextUpdate()), []string{"billing-token", "node-10.0.0.5", "node-10.0.0.6"}; !slices.Equal(ids, expected) {
+		t.Errorf("expected monitors %v, got %v", expected, ids)
+	}
+
+	// An invalid file keeps its previous monitors.
+	writeFile("web.json", `[{"targets": [`)
+	if ids, expected := monitorIDs(nextUpdate()), []string{"billing-token", "node-10.0.0.5", "node-10.0.0.6"}; !slices.Equal(ids, expected) {
+		t.Errorf("expected monitors %v, got %v", expected, ids)
+	}
+}
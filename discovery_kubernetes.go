@@ -0,0 +1,444 @@
+package roselite
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// KubernetesAnnotationPrefix is the prefix of the annotations that define a monitor on a Service or a Pod.
+	KubernetesAnnotationPrefix = "roselite.teknologiumum.com/"
+
+	// kubernetesDebounceDelay is how long the monitors are computed after a change, so a rollout results in a few
+	// updates instead of one for every pod.
+	kubernetesDebounceDelay = time.Second
+)
+
+// kubernetesRegionLabels are the node labels the region of a pod is taken from, the first one that is set wins.
+var kubernetesRegionLabels = []string{"topology.kubernetes.io/region", "failure-domain.beta.kubernetes.io/region"}
+
+var (
+	// errKubernetesResourceVersionExpired is returned by a watch that must start over with a new list.
+	errKubernetesResourceVersionExpired = errors.New("resource version expired")
+	// errKubernetesForbidden is returned when the service account or the user is not allowed to list a resource.
+	errKubernetesForbidden = errors.New("forbidden")
+)
+
+// KubernetesDiscovery discovers monitors from the annotations of the Services and the running Pods of a Kubernetes
+// cluster, watching them through the API server. The service account or the kubeconfig user needs to get, list and
+// watch pods and services, and nodes for the regions.
+//
+// A Service or a Pod is monitored once it has the roselite.teknologiumum.com/id annotation. The other annotations
+// are optional:
+//
+//	roselite.teknologiumum.com/id: <push token>
+//	roselite.teknologiumum.com/type: http
+//	roselite.teknologiumum.com/target: http://{{ip}}:8080/health
+//	roselite.teknologiumum.com/interval: 30s
+//	roselite.teknologiumum.com/timeout: 10s
+//	roselite.teknologiumum.com/tags: production,billing
+//	roselite.teknologiumum.com/region: jakarta
+//	roselite.teknologiumum.com/header.Authorization: Bearer token
+//
+// The {{ip}}, {{name}} and {{namespace}} placeholders of the ID and the target are replaced by the cluster IP of the
+// Service or the IP of the Pod, its name and its namespace. The region of a Pod is the topology.kubernetes.io/region
+// label of its node, unless the region annotation is set. Every replica of a Deployment carries the same annotations,
+// so annotate its Service instead, or use {{name}} on the ID.
+type KubernetesDiscovery struct {
+	// Kubeconfig is the path to a kubeconfig file. Defaults to the service account of the pod when running in a
+	// cluster, then to $KUBECONFIG and ~/.kube/config.
+	Kubeconfig string
+	// Context is the kubeconfig context to use, defaults to the current context.
+	Context string
+	// Namespace limits the discovery to a namespace. Defaults to every namespace.
+	Namespace string
+	// Defaults holds the options of the monitors that their annotations do not set.
+	Defaults Monitor
+}
+
+var _ Discoverer = (*KubernetesDiscovery)(nil)
+
+type kubernetesObject struct {
+	Metadata struct {
+		Name            string            `json:"name"`
+		Namespace       string            `json:"namespace"`
+		ResourceVersion string            `json:"resourceVersion"`
+		Labels          map[string]string `json:"labels"`
+		Annotations     map[string]string `json:"annotations"`
+	} `json:"metadata"`
+	Spec struct {
+		NodeName  string `json:"nodeName"`
+		ClusterIP string `json:"clusterIP"`
+	} `json:"spec"`
+	Status struct {
+		Phase string `json:"phase"`
+		PodIP string `json:"podIP"`
+	} `json:"status"`
+}
+
+func (o kubernetesObject) key() string {
+	return o.Metadata.Namespace + "/" + o.Metadata.Name
+}
+
+// equal reports whether both objects are the same as far as the discovery is concerned. Most updates, such as the
+// status of a node, only change the resource version.
+func (o kubernetesObject) equal(other kubernetesObject) bool {
+	o.Metadata.ResourceVersion, other.Metadata.ResourceVersion = "", ""
+	return reflect.DeepEqual(o, other)
+}
+
+type kubernetesList struct {
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Items []kubernetesObject `json:"items"`
+}
+
+type kubernetesWatchEvent struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+type kubernetesStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// kubernetesResource is the local copy of a kind of object, kept up to date by a watch.
+type kubernetesResource struct {
+	path string
+	// optional resources that cannot be listed are left empty, instead of failing the discovery.
+	optional bool
+
+	mutex   sync.Mutex
+	objects map[string]kubernetesObject
+	synced  bool
+}
+
+func (r *kubernetesResource) snapshot() (map[string]kubernetesObject, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return maps.Clone(r.objects), r.synced
+}
+
+func (d *KubernetesDiscovery) Name() string {
+	return "kubernetes"
+}
+
+func (d *KubernetesDiscovery) Run(ctx context.Context, update func(monitors []Monitor)) error {
+	client, err := newKubernetesClient(d.Kubeconfig, d.Context)
+	if err != nil {
+		return err
+	}
+	defer client.close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	namespacePath := "/api/v1"
+	if d.Namespace != "" {
+		namespacePath = "/api/v1/namespaces/" + url.PathEscape(d.Namespace)
+	}
+	services := &kubernetesResource{path: namespacePath + "/services"}
+	pods := &kubernetesResource{path: namespacePath + "/pods"}
+	nodes := &kubernetesResource{path: "/api/v1/nodes", optional: true}
+	resources := []*kubernetesResource{services, pods, nodes}
+
+	changed := make(chan struct{}, 1)
+	errs := make(chan error, len(resources))
+	for _, resource := range resources {
+		go func() {
+			errs <- d.watchResource(ctx, client, resource, changed)
+		}()
+	}
+
+	debounce := time.NewTimer(kubernetesDebounceDelay)
+	debounce.Stop()
+	defer debounce.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-errs:
+			return err
+		case <-changed:
+			debounce.Reset(kubernetesDebounceDelay)
+		case <-debounce.C:
+			monitors, ok := d.monitors(services, pods, nodes)
+			if ok {
+				update(monitors)
+			}
+		}
+	}
+}
+
+// watchResource lists the objects, then watches them until the context is done. The objects are listed again when
+// the watch falls too far behind.
+func (d *KubernetesDiscovery) watchResource(ctx context.Context, client *kubernetesClient, resource *kubernetesResource, changed chan<- struct{}) error {
+	notify := func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}
+
+	for {
+		version, err := d.listResource(ctx, client, resource)
+		if resource.optional && errors.Is(err, errKubernetesForbidden) {
+			slog.Warn("kubernetes discovery cannot list an optional resource, leaving it empty",
+				slog.String("path", resource.path),
+				slog.String("error", err.Error()))
+
+			resource.mutex.Lock()
+			resource.synced = true
+			resource.mutex.Unlock()
+			notify()
+
+			<-ctx.Done()
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		notify()
+
+		for {
+			version, err = d.watchChanges(ctx, client, resource, version, notify)
+			if ctx.Err() != nil {
+				return nil
+			}
+			if errors.Is(err, errKubernetesResourceVersionExpired) {
+				break
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (d *KubernetesDiscovery) listResource(ctx context.Context, client *kubernetesClient, resource *kubernetesResource) (string, error) {
+	response, err := client.get(ctx, resource.path, nil)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+
+	var list kubernetesList
+	if err := json.NewDecoder(response.Body).Decode(&list); err != nil {
+		return "", fmt.Errorf("decoding %s: %w", resource.path, err)
+	}
+
+	objects := make(map[string]kubernetesObject, len(list.Items))
+	for _, object := range list.Items {
+		objects[object.key()] = object
+	}
+
+	resource.mutex.Lock()
+	resource.objects = objects
+	resource.synced = true
+	resource.mutex.Unlock()
+
+	return list.Metadata.ResourceVersion, nil
+}
+
+// watchChanges applies the changes since the resource version, until the API server ends the watch. It returns the
+// resource version to continue watching from.
+func (d *KubernetesDiscovery) watchChanges(ctx context.Context, client *kubernetesClient, resource *kubernetesResource, version string, notify func()) (string, error) {
+	query := url.Values{}
+	query.Set("watch", "1")
+	query.Set("resourceVersion", version)
+	query.Set("allowWatchBookmarks", "true")
+
+	response, err := client.get(ctx, resource.path, query)
+	if err != nil {
+		return version, err
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+
+	decoder := json.NewDecoder(response.Body)
+	for {
+		var event kubernetesWatchEvent
+		if err := decoder.Decode(&event); err != nil {
+			if errors.Is(err, io.EOF) {
+				return version, nil
+			}
+			return version, fmt.Errorf("watching %s: %w", resource.path, err)
+		}
+
+		if event.Type == "ERROR" {
+			var status kubernetesStatus
+			_ = json.Unmarshal(event.Object, &status)
+			if status.Code == http.StatusGone {
+				return version, errKubernetesResourceVersionExpired
+			}
+			return version, fmt.Errorf("watching %s: %d %s", resource.path, status.Code, status.Message)
+		}
+
+		var object kubernetesObject
+		if err := json.Unmarshal(event.Object, &object); err != nil {
+			return version, fmt.Errorf("decoding %s event: %w", resource.path, err)
+		}
+		if object.Metadata.ResourceVersion != "" {
+			version = object.Metadata.ResourceVersion
+		}
+
+		resource.mutex.Lock()
+		previous, existed := resource.objects[object.key()]
+		changed := false
+		switch event.Type {
+		case "ADDED", "MODIFIED":
+			resource.objects[object.key()] = object
+			changed = !existed || !previous.equal(object)
+		case "DELETED":
+			delete(resource.objects, object.key())
+			changed = existed
+		}
+		resource.mutex.Unlock()
+
+		if changed {
+			notify()
+		}
+	}
+}
+
+// monitors returns the monitors of the annotated services and running pods, once every resource is listed.
+func (d *KubernetesDiscovery) monitors(services *kubernetesResource, pods *kubernetesResource, nodes *kubernetesResource) ([]Monitor, bool) {
+	serviceObjects, servicesSynced := services.snapshot()
+	podObjects, podsSynced := pods.snapshot()
+	nodeObjects, nodesSynced := nodes.snapshot()
+	if !servicesSynced || !podsSynced || !nodesSynced {
+		return nil, false
+	}
+
+	var monitors []Monitor
+	add := func(kind string, object kubernetesObject, ip string, region string) {
+		if _, ok := object.Metadata.Annotations[KubernetesAnnotationPrefix+"id"]; !ok {
+			return
+		}
+
+		monitor, err := d.objectMonitor(object, ip, region)
+		if err != nil {
+			slog.Warn("skipping kubernetes object with invalid annotations",
+				slog.String("source", d.Name()),
+				slog.String(kind, object.key()),
+				slog.String("error", err.Error()))
+			return
+		}
+
+		monitors = append(monitors, monitor)
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(serviceObjects)) {
+		service := serviceObjects[key]
+		// A headless service has "None" as its cluster IP.
+		clusterIP := service.Spec.ClusterIP
+		if clusterIP == "None" {
+			clusterIP = ""
+		}
+		add("service", service, clusterIP, "")
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(podObjects)) {
+		pod := podObjects[key]
+		if pod.Status.Phase != "Running" || pod.Status.PodIP == "" {
+			continue
+		}
+
+		var region string
+		if node, ok := nodeObjects["/"+pod.Spec.NodeName]; ok {
+			for _, label := range kubernetesRegionLabels {
+				if region = node.Metadata.Labels[label]; region != "" {
+					break
+				}
+			}
+		}
+
+		add("pod", pod, pod.Status.PodIP, region)
+	}
+
+	return monitors, true
+}
+
+func (d *KubernetesDiscovery) objectMonitor(object kubernetesObject, ip string, region string) (Monitor, error) {
+	annotations := object.Metadata.Annotations
+	options := discoveredMonitorOptions{
+		ID:            annotations[KubernetesAnnotationPrefix+"id"],
+		MonitorType:   annotations[KubernetesAnnotationPrefix+"type"],
+		MonitorTarget: annotations[KubernetesAnnotationPrefix+"target"],
+		Interval:      annotations[KubernetesAnnotationPrefix+"interval"],
+		Timeout:       annotations[KubernetesAnnotationPrefix+"timeout"],
+		Tags:          annotations[KubernetesAnnotationPrefix+"tags"],
+		Region:        region,
+	}
+	if annotated := annotations[KubernetesAnnotationPrefix+"region"]; annotated != "" {
+		options.Region = annotated
+	}
+	for annotation, value := range annotations {
+		if header, ok := strings.CutPrefix(annotation, KubernetesAnnotationPrefix+"header."); ok && header != "" {
+			if options.RequestHeaders == nil {
+				options.RequestHeaders = make(map[string]string)
+			}
+			options.RequestHeaders[header] = value
+		}
+	}
+
+	return options.toMonitor(d.Defaults, map[string]string{
+		"ip":        ip,
+		"name":      object.Metadata.Name,
+		"namespace": object.Metadata.Namespace,
+	})
+}
+
+// get performs a GET request on the API server, failing on any status other than 200 OK.
+func (c *kubernetesClient) get(ctx context.Context, path string, query url.Values) (*http.Response, error) {
+	requestUrl := c.server + path
+	if len(query) > 0 {
+		requestUrl += "?" + query.Encode()
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, requestUrl, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	request.Header.Set("Accept", "application/json")
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("requesting %s: %w", path, err)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		defer func() {
+			_ = response.Body.Close()
+		}()
+
+		var status kubernetesStatus
+		_ = json.NewDecoder(response.Body).Decode(&status)
+		err := fmt.Errorf("requesting %s: unexpected status code: %d %s", path, response.StatusCode, status.Message)
+		if response.StatusCode == http.StatusForbidden {
+			err = fmt.Errorf("%w: %w", errKubernetesForbidden, err)
+		}
+		return nil, err
+	}
+
+	return response, nil
+}
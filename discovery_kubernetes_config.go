@@ -0,0 +1,257 @@
+package roselite
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// kubernetesServiceAccountDirectory holds the token and the certificate authority of the pod's service account.
+const kubernetesServiceAccountDirectory = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// kubernetesClient is an HTTP client to the API server, authenticated as the service account of the pod or as the
+// user of a kubeconfig context.
+type kubernetesClient struct {
+	server     string
+	httpClient *http.Client
+}
+
+// kubeconfig is the part of a kubeconfig file that is needed to reach the API server.
+type kubeconfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Clusters       []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server                   string `yaml:"server"`
+			CertificateAuthority     string `yaml:"certificate-authority"`
+			CertificateAuthorityData string `yaml:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User struct {
+			Token                 string `yaml:"token"`
+			TokenFile             string `yaml:"tokenFile"`
+			ClientCertificate     string `yaml:"client-certificate"`
+			ClientCertificateData string `yaml:"client-certificate-data"`
+			ClientKey             string `yaml:"client-key"`
+			ClientKeyData         string `yaml:"client-key-data"`
+			Exec                  any    `yaml:"exec"`
+			AuthProvider          any    `yaml:"auth-provider"`
+		} `yaml:"user"`
+	} `yaml:"users"`
+	Contexts []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster string `yaml:"cluster"`
+			User    string `yaml:"user"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+}
+
+// newKubernetesClient creates a client from the kubeconfig file if one is given, then from the service account of
+// the pod when running in a cluster, then from $KUBECONFIG or ~/.kube/config.
+func newKubernetesClient(kubeconfigPath string, contextName string) (*kubernetesClient, error) {
+	if kubeconfigPath == "" {
+		if host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT"); host != "" && port != "" {
+			return newInClusterKubernetesClient(host, port)
+		}
+
+		kubeconfigPath = os.Getenv("KUBECONFIG")
+		// Like kubectl, only the first file of the list is read.
+		kubeconfigPath, _, _ = strings.Cut(kubeconfigPath, string(filepath.ListSeparator))
+	}
+
+	if kubeconfigPath == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("not running in a cluster, and no kubeconfig is set: %w", err)
+		}
+		kubeconfigPath = filepath.Join(home, ".kube", "config")
+	}
+
+	return newKubeconfigKubernetesClient(kubeconfigPath, contextName)
+}
+
+func newInClusterKubernetesClient(host string, port string) (*kubernetesClient, error) {
+	caCertificate, err := os.ReadFile(filepath.Join(kubernetesServiceAccountDirectory, "ca.crt"))
+	if err != nil {
+		return nil, fmt.Errorf("reading service account certificate authority: %w", err)
+	}
+
+	rootCAs := x509.NewCertPool()
+	if !rootCAs.AppendCertsFromPEM(caCertificate) {
+		return nil, errors.New("invalid service account certificate authority")
+	}
+
+	transport := &http.Transport{TLSClientConfig: &tls.Config{RootCAs: rootCAs}}
+	return &kubernetesClient{
+		server: "https://" + net.JoinHostPort(host, port),
+		httpClient: &http.Client{
+			// Service account tokens are rotated, hence they are read again on every request.
+			Transport: &kubernetesTokenTransport{
+				tokenFile: filepath.Join(kubernetesServiceAccountDirectory, "token"),
+				transport: transport,
+			},
+		},
+	}, nil
+}
+
+func newKubeconfigKubernetesClient(path string, contextName string) (*kubernetesClient, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading kubeconfig: %w", err)
+	}
+
+	var config kubeconfig
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		return nil, fmt.Errorf("parsing kubeconfig: %w", err)
+	}
+
+	if contextName == "" {
+		contextName = config.CurrentContext
+	}
+
+	var clusterName, userName string
+	found := false
+	for _, context := range config.Contexts {
+		if context.Name == contextName {
+			clusterName, userName, found = context.Context.Cluster, context.Context.User, true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("kubeconfig %s has no context %q", path, contextName)
+	}
+
+	// Relative paths on a kubeconfig are relative to the kubeconfig itself.
+	resolve := func(file string) string {
+		if file == "" || filepath.IsAbs(file) {
+			return file
+		}
+		return filepath.Join(filepath.Dir(path), file)
+	}
+
+	tlsConfig := &tls.Config{}
+	var server string
+	found = false
+	for _, cluster := range config.Clusters {
+		if cluster.Name != clusterName {
+			continue
+		}
+
+		found = true
+		server = cluster.Cluster.Server
+		tlsConfig.InsecureSkipVerify = cluster.Cluster.InsecureSkipTLSVerify
+
+		caCertificate, err := kubeconfigData(cluster.Cluster.CertificateAuthorityData, resolve(cluster.Cluster.CertificateAuthority))
+		if err != nil {
+			return nil, fmt.Errorf("reading certificate authority of cluster %q: %w", clusterName, err)
+		}
+		if caCertificate != nil {
+			tlsConfig.RootCAs = x509.NewCertPool()
+			if !tlsConfig.RootCAs.AppendCertsFromPEM(caCertificate) {
+				return nil, fmt.Errorf("invalid certificate authority of cluster %q", clusterName)
+			}
+		}
+		break
+	}
+	if !found || server == "" {
+		return nil, fmt.Errorf("kubeconfig %s has no server for cluster %q", path, clusterName)
+	}
+
+	var httpTransport http.RoundTripper = &http.Transport{TLSClientConfig: tlsConfig}
+	for _, user := range config.Users {
+		if user.Name != userName {
+			continue
+		}
+
+		if user.User.Exec != nil || user.User.AuthProvider != nil {
+			return nil, fmt.Errorf("user %q authenticates with a credential plugin, which is not supported, use a token or a client certificate", userName)
+		}
+
+		certificate, err := kubeconfigData(user.User.ClientCertificateData, resolve(user.User.ClientCertificate))
+		if err != nil {
+			return nil, fmt.Errorf("reading client certificate of user %q: %w", userName, err)
+		}
+		key, err := kubeconfigData(user.User.ClientKeyData, resolve(user.User.ClientKey))
+		if err != nil {
+			return nil, fmt.Errorf("reading client key of user %q: %w", userName, err)
+		}
+		if certificate != nil || key != nil {
+			keyPair, err := tls.X509KeyPair(certificate, key)
+			if err != nil {
+				return nil, fmt.Errorf("loading client certificate of user %q: %w", userName, err)
+			}
+			tlsConfig.Certificates = []tls.Certificate{keyPair}
+		}
+
+		switch {
+		case user.User.Token != "":
+			httpTransport = &kubernetesTokenTransport{token: user.User.Token, transport: httpTransport}
+		case user.User.TokenFile != "":
+			httpTransport = &kubernetesTokenTransport{tokenFile: resolve(user.User.TokenFile), transport: httpTransport}
+		}
+		break
+	}
+
+	return &kubernetesClient{
+		server:     strings.TrimSuffix(server, "/"),
+		httpClient: &http.Client{Transport: httpTransport},
+	}, nil
+}
+
+// kubeconfigData returns the base64 encoded data if it is set, or else the content of the file if it is set.
+func kubeconfigData(data string, file string) ([]byte, error) {
+	if data != "" {
+		return base64.StdEncoding.DecodeString(data)
+	}
+
+	if file != "" {
+		return os.ReadFile(file)
+	}
+
+	return nil, nil
+}
+
+// kubernetesTokenTransport authenticates the requests with a bearer token, either given or read from a file.
+type kubernetesTokenTransport struct {
+	token     string
+	tokenFile string
+	transport http.RoundTripper
+}
+
+func (t *kubernetesTokenTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	token := t.token
+	if t.tokenFile != "" {
+		content, err := os.ReadFile(t.tokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading token: %w", err)
+		}
+		token = strings.TrimSpace(string(content))
+	}
+
+	request = request.Clone(request.Context())
+	request.Header.Set("Authorization", "Bearer "+token)
+	return t.transport.RoundTrip(request)
+}
+
+func (t *kubernetesTokenTransport) CloseIdleConnections() {
+	if transport, ok := t.transport.(interface{ CloseIdleConnections() }); ok {
+		transport.CloseIdleConnections()
+	}
+}
+
+func (c *kubernetesClient) close() {
+	c.httpClient.CloseIdleConnections()
+}
@@ -0,0 +1,246 @@
+package roselite_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/teknologi-umum/roselite"
+)
+
+// fakeKubernetesAPI serves lists and watches of pods, services and nodes. Watch events are sent with send.
+type fakeKubernetesAPI struct {
+	mutex      sync.Mutex
+	lists      map[string][]map[string]any
+	watches    map[string]chan map[string]any
+	forbidden  map[string]bool
+	tokenCheck string
+}
+
+func newFakeKubernetesAPI() *fakeKubernetesAPI {
+	return &fakeKubernetesAPI{
+		lists:      make(map[string][]map[string]any),
+		watches:    make(map[string]chan map[string]any),
+		forbidden:  make(map[string]bool),
+		tokenCheck: "Bearer test-token",
+	}
+}
+
+func (f *fakeKubernetesAPI) watch(path string) chan map[string]any {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if _, ok := f.watches[path]; !ok {
+		f.watches[path] = make(chan map[string]any)
+	}
+	return f.watches[path]
+}
+
+func (f *fakeKubernetesAPI) send(path string, eventType string, object map[string]any) {
+	f.watch(path) <- map[string]any{"type": eventType, "object": object}
+}
+
+func (f *fakeKubernetesAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != f.tokenCheck {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	f.mutex.Lock()
+	forbidden := f.forbidden[r.URL.Path]
+	items, ok := f.lists[r.URL.Path]
+	f.mutex.Unlock()
+	if forbidden {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"kind":"Status","code":403,"message":"nodes is forbidden"}`))
+		return
+	}
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if r.URL.Query().Get("watch") == "" {
+		_ = json.NewEncoder(w).Encode(map[string]any{"metadata": map[string]any{"resourceVersion": "1"}, "items": items})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.(http.Flusher).Flush()
+	events := f.watch(r.URL.Path)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-events:
+			_ = json.NewEncoder(w).Encode(event)
+			w.(http.Flusher).Flush()
+		}
+	}
+}
+
+func kubernetesObject(namespace string, name string, annotations map[string]string, spec map[string]any, status map[string]any) map[string]any {
+	return map[string]any{
+		"metadata": map[string]any{"namespace": namespace, "name": name, "resourceVersion": "2", "annotations": annotations},
+		"spec":     spec,
+		"status":   status,
+	}
+}
+
+func writeKubeconfig(t *testing.T, server string) string {
+	t.Helper()
+	kubeconfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+current-context: test
+clusters:
+  - name: test
+    cluster:
+      server: %s
+users:
+  - name: test
+    user:
+      token: test-token
+contexts:
+  - name: test
+    context:
+      cluster: test
+      user: test
+`, server)
+
+	path := filepath.Join(t.TempDir(), "kubeconfig")
+	if err := os.WriteFile(path, []byte(kubeconfig), 0o600); err != nil {
+		t.Fatalf("failed to write kubeconfig: %v", err)
+	}
+	return path
+}
+
+func TestKubernetesDiscovery(t *testing.T) {
+	billing := kubernetesObject("default", "billing", map[string]string{
+		roselite.KubernetesAnnotationPrefix + "id":                   "billing-token",
+		roselite.KubernetesAnnotationPrefix + "target":               "http://{{ip}}:8080/health",
+		roselite.KubernetesAnnotationPrefix + "tags":                 "production",
+		roselite.KubernetesAnnotationPrefix + "header.Authorization": "Bearer token",
+	}, map[string]any{"clusterIP": "10.96.0.10"}, nil)
+	database := kubernetesObject("data", "postgres-0", map[string]string{
+		roselite.KubernetesAnnotationPrefix + "id":       "db-{{name}}",
+		roselite.KubernetesAnnotationPrefix + "type":     "icmp",
+		roselite.KubernetesAnnotationPrefix + "interval": "1m",
+	}, map[string]any{"nodeName": "node-a"}, map[string]any{"phase": "Running", "podIP": "10.244.1.5"})
+	pending := kubernetesObject("data", "postgres-1", map[string]string{
+		roselite.KubernetesAnnotationPrefix + "id":   "db-{{name}}",
+		roselite.KubernetesAnnotationPrefix + "type": "icmp",
+	}, map[string]any{"nodeName": "node-b"}, map[string]any{"phase": "Pending"})
+	unannotated := kubernetesObject("default", "kubernetes", nil, map[string]any{"clusterIP": "10.96.0.1"}, nil)
+	// A headless service has no cluster IP to check.
+	headless := kubernetesObject("data", "postgres", map[string]string{
+		roselite.KubernetesAnnotationPrefix + "id":     "postgres-token",
+		roselite.KubernetesAnnotationPrefix + "target": "http://{{ip}}:8080/health",
+	}, map[string]any{"clusterIP": "None"}, nil)
+	node := map[string]any{"metadata": map[string]any{"name": "node-a", "labels": map[string]string{"topology.kubernetes.io/region": "ap-southeast-3"}}}
+
+	run := func(t *testing.T, api *fakeKubernetesAPI) <-chan []roselite.Monitor {
+		server := httptest.NewServer(api)
+		t.Cleanup(server.Close)
+
+		discovery := &roselite.KubernetesDiscovery{
+			Kubeconfig: writeKubeconfig(t, server.URL),
+			Defaults:   roselite.Monitor{Interval: time.Second * 30, Tags: []string{"kubernetes"}},
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		updates := make(chan []roselite.Monitor, 16)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			err := discovery.Run(ctx, func(monitors []roselite.Monitor) { updates <- monitors })
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+		t.Cleanup(func() {
+			cancel()
+			<-done
+		})
+
+		return updates
+	}
+
+	nextUpdate := func(t *testing.T, updates <-chan []roselite.Monitor) []roselite.Monitor {
+		t.Helper()
+		select {
+		case monitors := <-updates:
+			return monitors
+		case <-time.After(time.Second * 10):
+			t.Fatal("timed out waiting for an update")
+			return nil
+		}
+	}
+
+	t.Run("Services and pods", func(t *testing.T) {
+		api := newFakeKubernetesAPI()
+		api.lists["/api/v1/services"] = []map[string]any{billing, headless, unannotated}
+		api.lists["/api/v1/pods"] = []map[string]any{database, pending}
+		api.lists["/api/v1/nodes"] = []map[string]any{node}
+		updates := run(t, api)
+
+		monitors := nextUpdate(t, updates)
+		if len(monitors) != 2 {
+			t.Fatalf("expected 2 monitors, got %+v", monitors)
+		}
+
+		service := monitors[0]
+		if service.ID != "billing-token" || service.MonitorType != roselite.MonitorTypeHTTP || service.MonitorTarget != "http://10.96.0.10:8080/health" {
+			t.Errorf("unexpected service monitor: %+v", service)
+		}
+		if service.Interval != time.Second*30 || service.Region != "" || service.RequestHeaders["Authorization"] != "Bearer token" {
+			t.Errorf("unexpected service monitor options: %+v", service)
+		}
+		if len(service.Tags) != 2 || service.Tags[0] != "kubernetes" || service.Tags[1] != "production" {
+			t.Errorf("unexpected service monitor tags: %v", service.Tags)
+		}
+
+		pod := monitors[1]
+		if pod.ID != "db-postgres-0" || pod.MonitorType != roselite.MonitorTypeICMP || pod.MonitorTarget != "10.244.1.5" {
+			t.Errorf("unexpected pod monitor: %+v", pod)
+		}
+		if pod.Interval != time.Minute || pod.Region != "ap-southeast-3" {
+			t.Errorf("unexpected pod monitor options: %+v", pod)
+		}
+
+		api.send("/api/v1/services", "DELETED", billing)
+		monitors = nextUpdate(t, updates)
+		if len(monitors) != 1 || monitors[0].ID != "db-postgres-0" {
+			t.Errorf("expected only the pod monitor to be left, got %+v", monitors)
+		}
+
+		running := kubernetesObject("data", "postgres-1", map[string]string{
+			roselite.KubernetesAnnotationPrefix + "id":   "db-{{name}}",
+			roselite.KubernetesAnnotationPrefix + "type": "icmp",
+		}, map[string]any{"nodeName": "node-b"}, map[string]any{"phase": "Running", "podIP": "10.244.2.7"})
+		api.send("/api/v1/pods", "MODIFIED", running)
+		monitors = nextUpdate(t, updates)
+		if len(monitors) != 2 || monitors[1].ID != "db-postgres-1" || monitors[1].Region != "" {
+			t.Errorf("expected the pod that started running to be added without a region, got %+v", monitors)
+		}
+	})
+
+	t.Run("Nodes are forbidden", func(t *testing.T) {
+		api := newFakeKubernetesAPI()
+		api.lists["/api/v1/services"] = nil
+		api.lists["/api/v1/pods"] = []map[string]any{database}
+		api.forbidden["/api/v1/nodes"] = true
+		updates := run(t, api)
+
+		monitors := nextUpdate(t, updates)
+		if len(monitors) != 1 || monitors[0].ID != "db-postgres-0" || monitors[0].Region != "" {
+			t.Errorf("expected the pod monitor without a region, got %+v", monitors)
+		}
+	})
+}
@@ -0,0 +1,197 @@
+package roselite
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ServerDiscovery receives the monitors of the agent from a roselite server, which hands them out by the region and
+// the identity of the agent. The request is held by the server until the monitors change, so changes are applied
+// right away without polling.
+//
+// The last monitors received are written to the cache file, and are checked on the next start until the server is
+// reachable again.
+type ServerDiscovery struct {
+	// ServerAddress is the base URL of the roselite server.
+	ServerAddress string
+	// Token authenticates the agent to the server.
+	Token     string
+	TLSConfig *tls.Config
+	// Region and AgentIdentifier select the monitors of the agent. They default to "default" and the hostname, the
+	// same way they do for the Agent.
+	Region          string
+	AgentIdentifier string
+	// Wait is how long the server holds a request if the monitors did not change. Defaults to 30 seconds.
+	Wait time.Duration
+	// CacheFile is where the last monitors received are kept. Leave it empty to disable the cache.
+	CacheFile string
+	// Decode converts a monitor definition handed out by the server into a Monitor.
+	Decode func(definition json.RawMessage) (Monitor, error)
+}
+
+var _ Discoverer = (*ServerDiscovery)(nil)
+
+func (d *ServerDiscovery) Name() string {
+	return "server"
+}
+
+func (d *ServerDiscovery) Run(ctx context.Context, update func(monitors []Monitor)) error {
+	endpoint, err := url.JoinPath(d.ServerAddress, "/api/agent/monitors")
+	if err != nil {
+		return fmt.Errorf("invalid server address: %w", err)
+	}
+
+	wait := d.Wait
+	if wait <= 0 {
+		wait = defaultDistributionWait
+	}
+
+	region := d.Region
+	if region == "" {
+		region = "default"
+	}
+
+	agentIdentifier := d.AgentIdentifier
+	if agentIdentifier == "" {
+		agentIdentifier = defaultInstanceIdentifier()
+	}
+
+	transport := &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: d.TLSConfig}
+	defer transport.CloseIdleConnections()
+	httpClient := &http.Client{Transport: transport, Timeout: wait + time.Minute}
+
+	var version string
+	if d.CacheFile != "" {
+		if response, err := d.readCache(); err == nil {
+			version = response.Version
+			update(d.decode(response.Monitors))
+		} else if !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("ignoring the monitor cache",
+				slog.String("source", d.Name()),
+				slog.String("file", d.CacheFile),
+				slog.String("error", err.Error()))
+		}
+	}
+
+	for {
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?wait="+url.QueryEscape(wait.String()), nil)
+		if err != nil {
+			return err
+		}
+		request.Header.Set(regionHeader, region)
+		request.Header.Set(agentHeader, agentIdentifier)
+		if d.Token != "" {
+			request.Header.Set("Authorization", "Bearer "+d.Token)
+		}
+		if version != "" {
+			request.Header.Set("If-None-Match", `"`+version+`"`)
+		}
+
+		response, err := httpClient.Do(request)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		body, err := io.ReadAll(response.Body)
+		_ = response.Body.Close()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("reading monitors: %w", err)
+		}
+
+		switch response.StatusCode {
+		case http.StatusNotModified:
+			continue
+		case http.StatusOK:
+		default:
+			return fmt.Errorf("server responded with status %d: %s", response.StatusCode, strings.TrimSpace(string(body)))
+		}
+
+		var monitors distributedMonitorsResponse
+		if err := json.Unmarshal(body, &monitors); err != nil {
+			return fmt.Errorf("parsing monitors: %w", err)
+		}
+
+		if d.CacheFile != "" {
+			if err := d.writeCache(body); err != nil {
+				slog.Warn("failed to write the monitor cache",
+					slog.String("source", d.Name()),
+					slog.String("file", d.CacheFile),
+					slog.String("error", err.Error()))
+			}
+		}
+
+		version = monitors.Version
+		update(d.decode(monitors.Monitors))
+	}
+}
+
+// decode converts the monitor definitions, skipping the ones that cannot be decoded.
+func (d *ServerDiscovery) decode(definitions []json.RawMessage) []Monitor {
+	monitors := make([]Monitor, 0, len(definitions))
+	for i, definition := range definitions {
+		monitor, err := d.Decode(definition)
+		if err != nil {
+			slog.Warn("skipping monitor that cannot be decoded",
+				slog.String("source", d.Name()),
+				slog.Int("index", i),
+				slog.String("error", err.Error()))
+			continue
+		}
+
+		monitors = append(monitors, monitor)
+	}
+
+	return monitors
+}
+
+func (d *ServerDiscovery) readCache() (distributedMonitorsResponse, error) {
+	content, err := os.ReadFile(d.CacheFile)
+	if err != nil {
+		return distributedMonitorsResponse{}, err
+	}
+
+	var response distributedMonitorsResponse
+	if err := json.Unmarshal(content, &response); err != nil {
+		return distributedMonitorsResponse{}, fmt.Errorf("parsing: %w", err)
+	}
+
+	return response, nil
+}
+
+// writeCache replaces the cache file through a temporary file, so a crash never leaves a partial cache behind. The
+// monitors may hold credentials, hence the file is only readable by its owner.
+func (d *ServerDiscovery) writeCache(content []byte) error {
+	file, err := os.CreateTemp(filepath.Dir(d.CacheFile), "."+filepath.Base(d.CacheFile)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(file.Name()) }()
+
+	if _, err := file.Write(content); err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), d.CacheFile)
+}
@@ -0,0 +1,140 @@
+package roselite_test
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/teknologi-umum/roselite"
+)
+
+func distributedMonitor(id string, target string, selector roselite.AgentSelector) roselite.DistributedMonitor {
+	definition, _ := json.Marshal(map[string]string{"id": id, "target": target})
+	return roselite.DistributedMonitor{AgentSelector: selector, Definition: definition}
+}
+
+func decodeDistributedMonitor(definition json.RawMessage) (roselite.Monitor, error) {
+	var fields map[string]string
+	if err := json.Unmarshal(definition, &fields); err != nil {
+		return roselite.Monitor{}, err
+	}
+
+	return roselite.Monitor{
+		ID:            fields["id"],
+		MonitorType:   roselite.MonitorTypeHTTP,
+		MonitorTarget: fields["target"],
+		Interval:      time.Hour,
+	}, nil
+}
+
+func TestServerDiscovery(t *testing.T) {
+	server := roselite.NewServer(roselite.ServerOptions{
+		MonitorDistribution: &roselite.MonitorDistribution{
+			Monitors: []roselite.DistributedMonitor{
+				distributedMonitor("everywhere", "http://127.0.0.1:1/a", roselite.AgentSelector{}),
+				distributedMonitor("jakarta", "http://127.0.0.1:1/b", roselite.AgentSelector{Regions: []string{"jakarta"}}),
+				distributedMonitor("edge-2", "http://127.0.0.1:1/c", roselite.AgentSelector{Agents: []string{"edge-2"}}),
+			},
+			Tokens: []string{"agent-token"},
+		},
+	})
+	httpServer := httptest.NewServer(server.Handler())
+	t.Cleanup(httpServer.Close)
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodGet, httpServer.URL+"/api/agent/monitors", nil)
+		request.Header.Set("Authorization", "Bearer wrong-token")
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("failed to request monitors: %v", err)
+		}
+		_ = response.Body.Close()
+
+		if response.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected status %d, got %d", http.StatusUnauthorized, response.StatusCode)
+		}
+	})
+
+	cacheFile := filepath.Join(t.TempDir(), "monitors.json")
+	updates := make(chan []roselite.Monitor)
+	run := func(ctx context.Context, serverAddress string) <-chan error {
+		done := make(chan error, 1)
+		discovery := &roselite.ServerDiscovery{
+			ServerAddress:   serverAddress,
+			Token:           "agent-token",
+			Region:          "jakarta",
+			AgentIdentifier: "edge-1",
+			// Longer than the test waits, changes must wake up the held request.
+			Wait:      time.Second * 30,
+			CacheFile: cacheFile,
+			Decode:    decodeDistributedMonitor,
+		}
+		go func() {
+			done <- discovery.Run(ctx, func(monitors []roselite.Monitor) {
+				select {
+				case updates <- monitors:
+				case <-ctx.Done():
+				}
+			})
+		}()
+		return done
+	}
+
+	nextUpdate := func() map[string]string {
+		t.Helper()
+		select {
+		case monitors := <-updates:
+			targets := make(map[string]string, len(monitors))
+			for _, monitor := range monitors {
+				targets[monitor.ID] = monitor.MonitorTarget
+			}
+			return targets
+		case <-time.After(time.Second * 5):
+			t.Fatal("timed out waiting for the monitors to be updated")
+			return nil
+		}
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := run(ctx, httpServer.URL)
+
+	targets := nextUpdate()
+	if ids := slices.Sorted(maps.Keys(targets)); !slices.Equal(ids, []string{"everywhere", "jakarta"}) {
+		t.Errorf("expected the monitors selecting the agent, got %v", ids)
+	}
+
+	server.SetMonitorDistribution(&roselite.MonitorDistribution{
+		Monitors: []roselite.DistributedMonitor{
+			distributedMonitor("everywhere", "http://127.0.0.1:1/changed", roselite.AgentSelector{}),
+		},
+		Tokens: []string{"agent-token"},
+	})
+
+	targets = nextUpdate()
+	if len(targets) != 1 || targets["everywhere"] != "http://127.0.0.1:1/changed" {
+		t.Errorf("expected the changed monitor, got %v", targets)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("expected the discovery to stop without an error, got %v", err)
+	}
+
+	// The server is gone, the agent starts with the cached monitors.
+	httpServer.Close()
+	done = run(t.Context(), httpServer.URL)
+
+	targets = nextUpdate()
+	if len(targets) != 1 || targets["everywhere"] != "http://127.0.0.1:1/changed" {
+		t.Errorf("expected the cached monitor, got %v", targets)
+	}
+	if err := <-done; err == nil {
+		t.Error("expected the discovery to fail while the server is unreachable")
+	}
+}
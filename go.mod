@@ -8,6 +8,7 @@ require (
 	github.com/jinzhu/configor v1.2.2
 	github.com/prometheus-community/pro-bing v0.7.0
 	github.com/urfave/cli/v3 v3.3.3
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -18,5 +19,4 @@ require (
 	golang.org/x/sync v0.13.0 // indirect
 	golang.org/x/sys v0.31.0 // indirect
 	golang.org/x/text v0.23.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
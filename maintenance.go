@@ -0,0 +1,214 @@
+package roselite
+
+import (
+	"crypto/rand"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/guregu/null/v6"
+)
+
+// maxSilences is the maximum amount of silences kept at once. New silences are rejected once it is reached.
+const maxSilences = 1_000
+
+// MaintenanceSelector selects monitors by their ID or by any of their tags. An empty selector selects nothing.
+type MaintenanceSelector struct {
+	Monitors []string `json:"monitors,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+}
+
+// Selects reports whether the monitor with the given ID and tags is selected.
+func (s MaintenanceSelector) Selects(id string, tags []string) bool {
+	if slices.Contains(s.Monitors, id) {
+		return true
+	}
+
+	for _, tag := range tags {
+		if slices.Contains(s.Tags, tag) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MaintenanceWindow is a recurring maintenance, such as a nightly backup, that starts on every occurrence of its
+// schedule and lasts for its duration.
+type MaintenanceWindow struct {
+	Name     string
+	Schedule CronSchedule
+	Duration time.Duration
+	// Location is the timezone the schedule is evaluated in. Defaults to the local timezone.
+	Location *time.Location
+	MaintenanceSelector
+	Mode MaintenanceMode
+}
+
+// occurrence returns the end of the occurrence of the window that is in effect at the given time.
+func (w MaintenanceWindow) occurrence(at time.Time) (time.Time, bool) {
+	location := w.Location
+	if location == nil {
+		location = time.Local
+	}
+
+	// The window is in effect if it started within its duration before the given time. When occurrences overlap, the
+	// latest one ends last.
+	var latest time.Time
+	for start := w.Schedule.Next(at.Add(-w.Duration).In(location)); !start.IsZero() && !start.After(at); start = w.Schedule.Next(start) {
+		latest = start
+	}
+	if latest.IsZero() {
+		return time.Time{}, false
+	}
+
+	return latest.Add(w.Duration), true
+}
+
+// Silence is an ad-hoc maintenance, created on the server's /api/silences endpoint.
+type Silence struct {
+	ID string `json:"id"`
+	MaintenanceSelector
+	StartsAt  time.Time       `json:"starts_at"`
+	EndsAt    time.Time       `json:"ends_at"`
+	Mode      MaintenanceMode `json:"mode"`
+	Comment   string          `json:"comment,omitempty"`
+	CreatedBy string          `json:"created_by,omitempty"`
+}
+
+// MaintenancePeriod is the maintenance window or the silence a monitor is under.
+type MaintenancePeriod struct {
+	// Name is the name of the window, or the silence and its comment.
+	Name   string
+	Mode   MaintenanceMode
+	EndsAt time.Time
+}
+
+// apply rewrites the heartbeat of the check into a maintenance heartbeat, keeping the outcome of the check in its
+// message.
+func (p MaintenancePeriod) apply(heartbeat Heartbeat) Heartbeat {
+	if heartbeat.Status == HeartbeatStatusMaintenance {
+		return heartbeat
+	}
+
+	message := fmt.Sprintf("under maintenance (%s) until %s, check is %s", p.Name, p.EndsAt.Format(time.RFC3339), heartbeat.Status)
+	if heartbeat.AdditionalMessage.ValueOrZero() != "" {
+		message += ": " + heartbeat.AdditionalMessage.ValueOrZero()
+	}
+
+	heartbeat.Status = HeartbeatStatusMaintenance
+	heartbeat.AdditionalMessage = null.StringFrom(message)
+	return heartbeat
+}
+
+// Maintenance tells which monitors are under a recurring maintenance window or an ad-hoc silence. It is safe for
+// concurrent use, and can be shared between an Agent and a Server running on the same process, so a silence created
+// on the server applies to the checks of the agent too.
+type Maintenance struct {
+	mutex    sync.RWMutex
+	windows  []MaintenanceWindow
+	silences map[string]Silence
+}
+
+// NewMaintenance creates a Maintenance with the given recurring windows, and no silence.
+func NewMaintenance(windows []MaintenanceWindow) *Maintenance {
+	return &Maintenance{windows: slices.Clone(windows), silences: make(map[string]Silence)}
+}
+
+// SetWindows replaces the recurring windows. The silences are kept.
+func (m *Maintenance) SetWindows(windows []MaintenanceWindow) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.windows = slices.Clone(windows)
+}
+
+// AddSilence adds the silence with a new ID, and returns it. It reports false if too many silences are kept.
+func (m *Maintenance) AddSilence(silence Silence) (Silence, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.pruneSilences(time.Now())
+	if len(m.silences) >= maxSilences {
+		return Silence{}, false
+	}
+
+	silence.ID = strings.ToLower(rand.Text())
+	m.silences[silence.ID] = silence
+	return silence, true
+}
+
+// RemoveSilence ends the silence before its time. It reports false if the silence is not found.
+func (m *Maintenance) RemoveSilence(id string) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	_, ok := m.silences[id]
+	delete(m.silences, id)
+	return ok
+}
+
+// Silences returns the silences that did not end yet, sorted by their start.
+func (m *Maintenance) Silences() []Silence {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.pruneSilences(time.Now())
+	return slices.SortedFunc(maps.Values(m.silences), func(a Silence, b Silence) int {
+		if c := a.StartsAt.Compare(b.StartsAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+}
+
+// pruneSilences forgets the silences that ended. The caller must hold the mutex.
+func (m *Maintenance) pruneSilences(now time.Time) {
+	maps.DeleteFunc(m.silences, func(_ string, silence Silence) bool {
+		return !silence.EndsAt.After(now)
+	})
+}
+
+// Period returns the maintenance the monitor with the given ID and tags is under at the given time. If it is under
+// several, skipping the check takes precedence, then the one that ends last.
+func (m *Maintenance) Period(id string, tags []string, at time.Time) (MaintenancePeriod, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	var period MaintenancePeriod
+	var found bool
+	consider := func(candidate MaintenancePeriod) {
+		if !found ||
+			(candidate.Mode == MaintenanceModeSkip && period.Mode != MaintenanceModeSkip) ||
+			(candidate.Mode == period.Mode && candidate.EndsAt.After(period.EndsAt)) {
+			period, found = candidate, true
+		}
+	}
+
+	for _, window := range m.windows {
+		if !window.Selects(id, tags) {
+			continue
+		}
+
+		if endsAt, ok := window.occurrence(at); ok {
+			consider(MaintenancePeriod{Name: window.Name, Mode: window.Mode, EndsAt: endsAt})
+		}
+	}
+
+	for _, silence := range m.silences {
+		if !silence.Selects(id, tags) || at.Before(silence.StartsAt) || !at.Before(silence.EndsAt) {
+			continue
+		}
+
+		name := "silence " + silence.ID
+		if silence.Comment != "" {
+			name += ": " + silence.Comment
+		}
+		consider(MaintenancePeriod{Name: name, Mode: silence.Mode, EndsAt: silence.EndsAt})
+	}
+
+	return period, found
+}
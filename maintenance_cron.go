@@ -0,0 +1,219 @@
+package roselite
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSearchLimit is how far ahead the next occurrence of a schedule is searched, so a schedule that never matches,
+// such as the 30th of February, does not loop forever.
+const cronSearchLimit = time.Hour * 24 * 366 * 5
+
+// cronDescriptors are the shorthands accepted in place of the five fields.
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronField describes the bounds and the names accepted by a field of a schedule.
+type cronField struct {
+	name     string
+	minimum  int
+	maximum  int
+	aliases  map[string]int
+	wrapping int
+}
+
+var (
+	cronMinuteField = cronField{name: "minute", minimum: 0, maximum: 59}
+	cronHourField   = cronField{name: "hour", minimum: 0, maximum: 23}
+	cronDayField    = cronField{name: "day of month", minimum: 1, maximum: 31}
+	cronMonthField  = cronField{name: "month", minimum: 1, maximum: 12, aliases: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6, "jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// Both 0 and 7 are Sunday.
+	cronWeekdayField = cronField{name: "day of week", minimum: 0, maximum: 7, wrapping: 7, aliases: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// CronSchedule is a recurring schedule written as a cron expression of five fields: minute, hour, day of month,
+// month and day of week. Fields accept `*`, values, ranges such as `1-5`, steps such as `*/15`, lists such as
+// `1,15`, and the English abbreviations of months and days. The @yearly, @monthly, @weekly, @daily and @hourly
+// shorthands are accepted too.
+type CronSchedule struct {
+	expression string
+	minutes    uint64
+	hours      uint64
+	days       uint64
+	months     uint64
+	weekdays   uint64
+	// Like cron, a day matches either the day of month or the day of week when both are restricted. A field starting
+	// with `*`, such as `*/2`, is not restricted.
+	daysRestricted     bool
+	weekdaysRestricted bool
+}
+
+// ParseCronSchedule parses a cron expression.
+func ParseCronSchedule(expression string) (CronSchedule, error) {
+	fields := strings.Fields(expression)
+	if len(fields) == 1 && strings.HasPrefix(fields[0], "@") {
+		descriptor, ok := cronDescriptors[strings.ToLower(fields[0])]
+		if !ok {
+			return CronSchedule{}, fmt.Errorf("unknown cron descriptor %q", fields[0])
+		}
+		fields = strings.Fields(descriptor)
+	}
+
+	if len(fields) != 5 {
+		return CronSchedule{}, fmt.Errorf("cron expression %q must have 5 fields, got %d", expression, len(fields))
+	}
+
+	schedule := CronSchedule{
+		expression:         strings.Join(fields, " "),
+		daysRestricted:     !strings.HasPrefix(fields[2], "*"),
+		weekdaysRestricted: !strings.HasPrefix(fields[4], "*"),
+	}
+
+	var err error
+	for _, field := range []struct {
+		value string
+		field cronField
+		bits  *uint64
+	}{
+		{fields[0], cronMinuteField, &schedule.minutes},
+		{fields[1], cronHourField, &schedule.hours},
+		{fields[2], cronDayField, &schedule.days},
+		{fields[3], cronMonthField, &schedule.months},
+		{fields[4], cronWeekdayField, &schedule.weekdays},
+	} {
+		*field.bits, err = field.field.parse(field.value)
+		if err != nil {
+			return CronSchedule{}, err
+		}
+	}
+
+	return schedule, nil
+}
+
+// parse returns the set of values matched by the field, as a bit set.
+func (f cronField) parse(value string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(value, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+
+		step := 1
+		if hasStep {
+			parsed, err := strconv.Atoi(stepPart)
+			if err != nil || parsed < 1 {
+				return 0, fmt.Errorf("invalid step %q in %s field", stepPart, f.name)
+			}
+			step = parsed
+		}
+
+		var start, end int
+		switch {
+		case rangePart == "*":
+			start, end = f.minimum, f.maximum
+		case strings.Contains(rangePart, "-"):
+			from, to, _ := strings.Cut(rangePart, "-")
+			var err error
+			if start, err = f.value(from); err != nil {
+				return 0, err
+			}
+			if end, err = f.value(to); err != nil {
+				return 0, err
+			}
+			if start > end {
+				return 0, fmt.Errorf("invalid range %q in %s field", rangePart, f.name)
+			}
+		default:
+			var err error
+			if start, err = f.value(rangePart); err != nil {
+				return 0, err
+			}
+			// A single value with a step, such as 5/15, runs from the value to the end of the field.
+			end = start
+			if hasStep {
+				end = f.maximum
+			}
+		}
+
+		for value := start; value <= end; value += step {
+			if f.wrapping > 0 {
+				bits |= 1 << (value % f.wrapping)
+			} else {
+				bits |= 1 << value
+			}
+		}
+	}
+
+	return bits, nil
+}
+
+func (f cronField) value(s string) (int, error) {
+	if value, ok := f.aliases[strings.ToLower(s)]; ok {
+		return value, nil
+	}
+
+	value, err := strconv.Atoi(s)
+	if err != nil || value < f.minimum || value > f.maximum {
+		return 0, fmt.Errorf("invalid value %q in %s field, expected %d-%d", s, f.name, f.minimum, f.maximum)
+	}
+
+	return value, nil
+}
+
+func (c CronSchedule) String() string {
+	return c.expression
+}
+
+func (c CronSchedule) matchesDay(t time.Time) bool {
+	day := c.days&(1<<t.Day()) != 0
+	weekday := c.weekdays&(1<<int(t.Weekday())) != 0
+	if c.daysRestricted && c.weekdaysRestricted {
+		return day || weekday
+	}
+
+	return day && weekday
+}
+
+// Next returns the first occurrence of the schedule strictly after the given time, in the location of the given
+// time. It returns the zero time if the schedule does not occur within the next five years.
+func (c CronSchedule) Next(after time.Time) time.Time {
+	location := after.Location()
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := after.Add(cronSearchLimit)
+
+	for t.Before(limit) {
+		year, month, day := t.Date()
+		next := t
+		switch {
+		case c.months&(1<<int(month)) == 0:
+			next = time.Date(year, month+1, 1, 0, 0, 0, 0, location)
+		case !c.matchesDay(t):
+			next = time.Date(year, month, day+1, 0, 0, 0, 0, location)
+		case c.hours&(1<<t.Hour()) == 0:
+			next = time.Date(year, month, day, t.Hour()+1, 0, 0, 0, location)
+		case c.minutes&(1<<t.Minute()) == 0:
+			next = t.Add(time.Minute)
+		default:
+			return t
+		}
+
+		// A daylight saving time transition can move a wall clock time backwards, always make progress.
+		if !next.After(t) {
+			next = t.Add(time.Minute)
+		}
+		t = next
+	}
+
+	return time.Time{}
+}
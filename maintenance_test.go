@@ -0,0 +1,197 @@
+package roselite_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/teknologi-umum/roselite"
+)
+
+func TestCronSchedule_Next(t *testing.T) {
+	jakarta, err := time.LoadLocation("Asia/Jakarta")
+	if err != nil {
+		t.Skipf("timezone data is not available: %v", err)
+	}
+
+	testCases := []struct {
+		name       string
+		expression string
+		after      time.Time
+		expected   time.Time
+	}{
+		{
+			name:       "Every day at 2 AM",
+			expression: "0 2 * * *",
+			after:      time.Date(2025, time.March, 1, 10, 0, 0, 0, jakarta),
+			expected:   time.Date(2025, time.March, 2, 2, 0, 0, 0, jakarta),
+		},
+		{
+			name:       "Strictly after",
+			expression: "0 2 * * *",
+			after:      time.Date(2025, time.March, 2, 2, 0, 0, 0, jakarta),
+			expected:   time.Date(2025, time.March, 3, 2, 0, 0, 0, jakarta),
+		},
+		{
+			name:       "Steps",
+			expression: "*/15 * * * *",
+			after:      time.Date(2025, time.March, 1, 10, 16, 30, 0, time.UTC),
+			expected:   time.Date(2025, time.March, 1, 10, 30, 0, 0, time.UTC),
+		},
+		{
+			name:       "Weekdays by name",
+			expression: "30 22 * * mon-fri",
+			after:      time.Date(2025, time.March, 7, 23, 0, 0, 0, time.UTC), // Friday
+			expected:   time.Date(2025, time.March, 10, 22, 30, 0, 0, time.UTC),
+		},
+		{
+			name:       "Sunday as 7",
+			expression: "0 0 * * 7",
+			after:      time.Date(2025, time.March, 3, 0, 0, 0, 0, time.UTC), // Monday
+			expected:   time.Date(2025, time.March, 9, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:       "Day of month or day of week",
+			expression: "0 0 15 * sun",
+			after:      time.Date(2025, time.March, 10, 0, 0, 0, 0, time.UTC), // Monday
+			expected:   time.Date(2025, time.March, 15, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:       "Stepped day of month is not restricted",
+			expression: "0 0 */2 * mon",
+			after:      time.Date(2025, time.March, 3, 12, 0, 0, 0, time.UTC), // Monday
+			expected:   time.Date(2025, time.March, 17, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:       "Shorthand",
+			expression: "@monthly",
+			after:      time.Date(2025, time.December, 15, 0, 0, 0, 0, time.UTC),
+			expected:   time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:       "Never",
+			expression: "0 0 30 feb *",
+			after:      time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC),
+			expected:   time.Time{},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			schedule, err := roselite.ParseCronSchedule(testCase.expression)
+			if err != nil {
+				t.Fatalf("failed to parse %q: %v", testCase.expression, err)
+			}
+
+			if next := schedule.Next(testCase.after); !next.Equal(testCase.expected) {
+				t.Errorf("expected %s, got %s", testCase.expected, next)
+			}
+		})
+	}
+}
+
+func TestParseCronSchedule_Invalid(t *testing.T) {
+	for _, expression := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"@fortnightly",
+	} {
+		if _, err := roselite.ParseCronSchedule(expression); err == nil {
+			t.Errorf("expected %q to be invalid", expression)
+		}
+	}
+}
+
+func TestMaintenance_Period(t *testing.T) {
+	schedule, err := roselite.ParseCronSchedule("0 2 * * *")
+	if err != nil {
+		t.Fatalf("failed to parse schedule: %v", err)
+	}
+
+	maintenance := roselite.NewMaintenance([]roselite.MaintenanceWindow{
+		{
+			Name:                "nightly-backup",
+			Schedule:            schedule,
+			Duration:            time.Hour,
+			Location:            time.UTC,
+			MaintenanceSelector: roselite.MaintenanceSelector{Tags: []string{"database"}},
+		},
+	})
+
+	at := func(hour int, minute int) time.Time {
+		return time.Date(2025, time.March, 1, hour, minute, 0, 0, time.UTC)
+	}
+
+	t.Run("Window", func(t *testing.T) {
+		period, ok := maintenance.Period("orders-db", []string{"database"}, at(2, 30))
+		if !ok {
+			t.Fatal("expected the monitor to be under maintenance")
+		}
+		if period.Name != "nightly-backup" || period.Mode != roselite.MaintenanceModePush || !period.EndsAt.Equal(at(3, 0)) {
+			t.Errorf("unexpected period: %+v", period)
+		}
+
+		for _, outside := range []time.Time{at(1, 59), at(3, 0)} {
+			if _, ok := maintenance.Period("orders-db", []string{"database"}, outside); ok {
+				t.Errorf("expected the monitor not to be under maintenance at %s", outside)
+			}
+		}
+
+		if _, ok := maintenance.Period("web", []string{"public"}, at(2, 30)); ok {
+			t.Error("expected a monitor without the tag not to be under maintenance")
+		}
+	})
+
+	t.Run("Overlapping occurrences", func(t *testing.T) {
+		schedule, err := roselite.ParseCronSchedule("0 * * * *")
+		if err != nil {
+			t.Fatalf("failed to parse schedule: %v", err)
+		}
+
+		overlapping := roselite.NewMaintenance([]roselite.MaintenanceWindow{
+			{Name: "hourly", Schedule: schedule, Duration: time.Minute * 90, Location: time.UTC, MaintenanceSelector: roselite.MaintenanceSelector{Monitors: []string{"web"}}},
+		})
+
+		// The occurrences of 1 AM and 2 AM are both in effect, the latter ends last.
+		period, ok := overlapping.Period("web", nil, at(2, 15))
+		if !ok || !period.EndsAt.Equal(at(3, 30)) {
+			t.Errorf("expected the window to end at %s, got %+v", at(3, 30), period)
+		}
+	})
+
+	t.Run("Silence", func(t *testing.T) {
+		now := time.Now()
+		silence, ok := maintenance.AddSilence(roselite.Silence{
+			MaintenanceSelector: roselite.MaintenanceSelector{Monitors: []string{"web"}},
+			StartsAt:            now.Add(-time.Minute),
+			EndsAt:              now.Add(time.Hour),
+			Mode:                roselite.MaintenanceModeSkip,
+			Comment:             "migration",
+		})
+		if !ok || silence.ID == "" {
+			t.Fatalf("expected the silence to be added, got %+v", silence)
+		}
+
+		period, ok := maintenance.Period("web", nil, now)
+		if !ok || period.Mode != roselite.MaintenanceModeSkip || period.Name != "silence "+silence.ID+": migration" {
+			t.Errorf("unexpected period: %+v", period)
+		}
+
+		if silences := maintenance.Silences(); len(silences) != 1 || silences[0].ID != silence.ID {
+			t.Errorf("unexpected silences: %+v", silences)
+		}
+
+		if !maintenance.RemoveSilence(silence.ID) {
+			t.Error("expected the silence to be removed")
+		}
+		if _, ok := maintenance.Period("web", nil, now); ok {
+			t.Error("expected the monitor not to be under maintenance once the silence is removed")
+		}
+	})
+}
@@ -0,0 +1,248 @@
+package roselite
+
+import (
+	"container/list"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultHeartbeatHistorySize is the amount of heartbeats kept for every monitor if no size is given.
+	defaultHeartbeatHistorySize = 20
+	// maxPushedMonitors is the maximum amount of monitors seen on the push endpoint that are kept on the store.
+	// The least recently updated one is evicted once the limit is reached.
+	maxPushedMonitors = 10_000
+)
+
+// MonitorSource tells how roselite knows about a monitor.
+type MonitorSource string
+
+const (
+	// MonitorSourceAgent is a monitor that is checked by the agent.
+	MonitorSourceAgent MonitorSource = "agent"
+	// MonitorSourcePush is a monitor ID that is seen on the push endpoint of the server.
+	MonitorSourcePush MonitorSource = "push"
+)
+
+// HeartbeatRecord is a heartbeat as seen by roselite, along with the error that occurred while producing or
+// forwarding it.
+type HeartbeatRecord struct {
+	Heartbeat
+	ReceivedAt time.Time `json:"received_at"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// MonitorSummary describes a monitor known to the agent or the server, and its latest heartbeat.
+type MonitorSummary struct {
+	ID            string           `json:"id"`
+	Source        MonitorSource    `json:"source"`
+	MonitorType   string           `json:"monitor_type,omitempty"`
+	Interval      string           `json:"interval,omitempty"`
+	Region        string           `json:"region,omitempty"`
+	Tags          []string         `json:"tags,omitempty"`
+	Agent         string           `json:"agent,omitempty"`
+	LastHeartbeat *HeartbeatRecord `json:"last_heartbeat"`
+	LastError     string           `json:"last_error,omitempty"`
+}
+
+type monitorState struct {
+	summary MonitorSummary
+	// history is a ring buffer of heartbeats, next points to the slot that will be written next.
+	history []HeartbeatRecord
+	next    int
+	full    bool
+	// pushed is the element of the monitor on MonitorStore.pushed, if it is seen on the push endpoint.
+	pushed *list.Element
+}
+
+func (m *monitorState) record(record HeartbeatRecord) {
+	m.history[m.next] = record
+	m.next = (m.next + 1) % len(m.history)
+	if m.next == 0 {
+		m.full = true
+	}
+
+	m.summary.LastHeartbeat = &record
+	if record.Error != "" {
+		m.summary.LastError = record.Error
+	}
+}
+
+// latest returns at most limit heartbeats, the newest first.
+func (m *monitorState) latest(limit int) []HeartbeatRecord {
+	size := m.next
+	if m.full {
+		size = len(m.history)
+	}
+
+	if limit <= 0 || limit > size {
+		limit = size
+	}
+
+	records := make([]HeartbeatRecord, 0, limit)
+	for i := 1; i <= limit; i++ {
+		records = append(records, m.history[(m.next-i+len(m.history))%len(m.history)])
+	}
+
+	return records
+}
+
+// MonitorStore keeps the last heartbeats of every monitor checked by the agent and every monitor ID seen on the
+// push endpoint, in a bounded ring buffer for each monitor. It is safe for concurrent use, and can be shared between
+// an Agent and a Server running on the same process.
+type MonitorStore struct {
+	mutex       sync.RWMutex
+	historySize int
+	monitors    map[string]*monitorState
+	// pushed holds the IDs of the monitors seen on the push endpoint, the least recently updated first.
+	pushed *list.List
+}
+
+// NewMonitorStore creates a MonitorStore that keeps historySize heartbeats for every monitor. A historySize that
+// is less than 1 defaults to 20.
+func NewMonitorStore(historySize int) *MonitorStore {
+	if historySize < 1 {
+		historySize = defaultHeartbeatHistorySize
+	}
+
+	return &MonitorStore{
+		historySize: historySize,
+		monitors:    make(map[string]*monitorState),
+		pushed:      list.New(),
+	}
+}
+
+func (s *MonitorStore) getOrCreate(id string, source MonitorSource) *monitorState {
+	state, ok := s.monitors[id]
+	if ok {
+		return state
+	}
+
+	state = &monitorState{
+		summary: MonitorSummary{ID: id, Source: source},
+		history: make([]HeartbeatRecord, s.historySize),
+	}
+	if source == MonitorSourcePush {
+		s.evictPushedMonitors()
+		state.pushed = s.pushed.PushBack(id)
+	}
+	s.monitors[id] = state
+	return state
+}
+
+// evictPushedMonitors removes the least recently updated monitor seen on the push endpoint if the limit is reached.
+// The caller must hold the mutex.
+func (s *MonitorStore) evictPushedMonitors() {
+	if s.pushed.Len() < maxPushedMonitors {
+		return
+	}
+
+	s.remove(s.pushed.Front().Value.(string))
+}
+
+// remove deletes the monitor from the store. The caller must hold the mutex.
+func (s *MonitorStore) remove(id string) {
+	state, ok := s.monitors[id]
+	if !ok {
+		return
+	}
+
+	if state.pushed != nil {
+		s.pushed.Remove(state.pushed)
+	}
+	delete(s.monitors, id)
+}
+
+// RegisterMonitor adds a monitor checked by the agent into the store, so it is listed even before its first check.
+func (s *MonitorStore) RegisterMonitor(monitor Monitor, region string, agent string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	state := s.getOrCreate(monitor.ID, MonitorSourceAgent)
+	if state.pushed != nil {
+		s.pushed.Remove(state.pushed)
+		state.pushed = nil
+	}
+	state.summary.Source = MonitorSourceAgent
+	state.summary.MonitorType = monitor.MonitorType.String()
+	state.summary.Interval = monitor.Interval.String()
+	state.summary.Region = region
+	state.summary.Tags = slices.Clone(monitor.Tags)
+	state.summary.Agent = agent
+}
+
+// UnregisterMonitor removes the monitor and its heartbeats from the store.
+func (s *MonitorStore) UnregisterMonitor(id string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.remove(id)
+}
+
+// Record appends a heartbeat for the monitor. Monitors that are not registered yet are added as seen on the push
+// endpoint, in which the region and the agent identity of the sender are recorded if they are not empty.
+func (s *MonitorStore) Record(id string, region string, agent string, heartbeat Heartbeat, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	state := s.getOrCreate(id, MonitorSourcePush)
+	if state.pushed != nil {
+		s.pushed.MoveToBack(state.pushed)
+	}
+	if region != "" {
+		state.summary.Region = region
+	}
+	if agent != "" {
+		state.summary.Agent = agent
+	}
+
+	record := HeartbeatRecord{Heartbeat: heartbeat, ReceivedAt: time.Now()}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	state.record(record)
+}
+
+// tags returns the tags of the monitor, which are only known for the monitors checked by the agent.
+func (s *MonitorStore) tags(id string) []string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if state, ok := s.monitors[id]; ok {
+		return state.summary.Tags
+	}
+
+	return nil
+}
+
+// Monitors returns the summary of every monitor, sorted by its ID.
+func (s *MonitorStore) Monitors() []MonitorSummary {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	summaries := make([]MonitorSummary, 0, len(s.monitors))
+	for _, state := range s.monitors {
+		summaries = append(summaries, state.summary)
+	}
+
+	slices.SortFunc(summaries, func(a, b MonitorSummary) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	return summaries
+}
+
+// Monitor returns the summary of a monitor, and at most limit of its latest heartbeats, the newest first.
+// A limit that is less than 1 returns every heartbeat that is kept.
+func (s *MonitorStore) Monitor(id string, limit int) (MonitorSummary, []HeartbeatRecord, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	state, ok := s.monitors[id]
+	if !ok {
+		return MonitorSummary{}, nil, false
+	}
+
+	return state.summary, state.latest(limit), true
+}
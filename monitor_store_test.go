@@ -0,0 +1,114 @@
+package roselite_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/teknologi-umum/roselite"
+)
+
+func TestMonitorStore(t *testing.T) {
+	store := roselite.NewMonitorStore(3)
+	store.RegisterMonitor(roselite.Monitor{
+		ID:          "agent-monitor",
+		MonitorType: roselite.MonitorTypeHTTP,
+		Interval:    time.Second * 30,
+	}, "ap-southeast-1", "agent-1")
+
+	for i := int64(1); i <= 5; i++ {
+		store.Record("pushed-monitor", "eu-west-1", "", roselite.Heartbeat{Status: roselite.HeartbeatStatusUp, Latency: i}, nil)
+	}
+	store.Record("pushed-monitor", "", "", roselite.Heartbeat{Status: roselite.HeartbeatStatusDown, Latency: 6}, errors.New("connection refused"))
+
+	t.Run("List", func(t *testing.T) {
+		monitors := store.Monitors()
+		if len(monitors) != 2 {
+			t.Fatalf("expected 2 monitors, got %d", len(monitors))
+		}
+
+		if monitors[0].ID != "agent-monitor" || monitors[0].Source != roselite.MonitorSourceAgent {
+			t.Errorf("unexpected first monitor: %+v", monitors[0])
+		}
+		if monitors[0].LastHeartbeat != nil {
+			t.Errorf("expected agent monitor to have no heartbeat yet")
+		}
+		if monitors[0].Region != "ap-southeast-1" || monitors[0].Interval != "30s" {
+			t.Errorf("unexpected agent monitor: %+v", monitors[0])
+		}
+
+		if monitors[1].ID != "pushed-monitor" || monitors[1].Source != roselite.MonitorSourcePush {
+			t.Errorf("unexpected second monitor: %+v", monitors[1])
+		}
+		if monitors[1].Region != "eu-west-1" {
+			t.Errorf("expected region to be kept, got %s", monitors[1].Region)
+		}
+		if monitors[1].LastError != "connection refused" {
+			t.Errorf("expected last error to be connection refused, got %s", monitors[1].LastError)
+		}
+	})
+
+	t.Run("History", func(t *testing.T) {
+		_, heartbeats, ok := store.Monitor("pushed-monitor", 0)
+		if !ok {
+			t.Fatalf("expected monitor to be found")
+		}
+
+		if len(heartbeats) != 3 {
+			t.Fatalf("expected 3 heartbeats, got %d", len(heartbeats))
+		}
+
+		for i, expectedLatency := range []int64{6, 5, 4} {
+			if heartbeats[i].Latency != expectedLatency {
+				t.Errorf("heartbeat %d: expected latency %d, got %d", i, expectedLatency, heartbeats[i].Latency)
+			}
+		}
+
+		_, heartbeats, _ = store.Monitor("pushed-monitor", 1)
+		if len(heartbeats) != 1 || heartbeats[0].Status != roselite.HeartbeatStatusDown {
+			t.Errorf("unexpected heartbeats: %+v", heartbeats)
+		}
+	})
+
+	t.Run("Unregister", func(t *testing.T) {
+		store.UnregisterMonitor("agent-monitor")
+		if _, _, ok := store.Monitor("agent-monitor", 0); ok {
+			t.Errorf("expected monitor to be removed")
+		}
+	})
+}
+
+func TestMonitorStore_EvictPushedMonitors(t *testing.T) {
+	// The store keeps up to 10 000 monitors seen on the push endpoint.
+	store := roselite.NewMonitorStore(1)
+	store.RegisterMonitor(roselite.Monitor{ID: "agent-monitor", MonitorType: roselite.MonitorTypeHTTP}, "", "")
+	for i := range 10_000 {
+		store.Record(fmt.Sprintf("pushed-%d", i), "", "", roselite.Heartbeat{Status: roselite.HeartbeatStatusUp}, nil)
+	}
+
+	// pushed-0 is updated again, and pushed-1 becomes a monitor of the agent, so pushed-2 is the least recently
+	// updated one when two more IDs are pushed.
+	store.Record("pushed-0", "", "", roselite.Heartbeat{Status: roselite.HeartbeatStatusUp}, nil)
+	store.RegisterMonitor(roselite.Monitor{ID: "pushed-1", MonitorType: roselite.MonitorTypeHTTP}, "", "")
+	store.Record("pushed-new", "", "", roselite.Heartbeat{Status: roselite.HeartbeatStatusUp}, nil)
+	store.Record("pushed-newer", "", "", roselite.Heartbeat{Status: roselite.HeartbeatStatusUp}, nil)
+
+	for id, expected := range map[string]bool{
+		"agent-monitor": true,
+		"pushed-0":      true,
+		"pushed-1":      true,
+		"pushed-2":      false,
+		"pushed-3":      true,
+		"pushed-new":    true,
+		"pushed-newer":  true,
+	} {
+		if _, _, ok := store.Monitor(id, 0); ok != expected {
+			t.Errorf("expected %s to be kept: %t, got %t", id, expected, ok)
+		}
+	}
+
+	if monitors := store.Monitors(); len(monitors) != 10_002 {
+		t.Errorf("expected 10002 monitors, got %d", len(monitors))
+	}
+}
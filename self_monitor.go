@@ -0,0 +1,170 @@
+package roselite
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/guregu/null/v6"
+)
+
+const (
+	// defaultSelfMonitorInterval is how often the self monitor is pushed if SelfMonitor.Interval is not set.
+	defaultSelfMonitorInterval = time.Second * 30
+	// defaultSelfMonitorUpstreamErrorRate is the share of failed pushes to the upstream instance within an interval
+	// that makes the self monitor pending, if SelfMonitor.UpstreamErrorRate is not set.
+	defaultSelfMonitorUpstreamErrorRate = 0.5
+)
+
+// SelfMonitor pushes the health of roselite itself to a dedicated push token on the upstream instance, so a broken
+// relay is reported on its own instead of as every one of its monitors being down.
+//
+// The heartbeat is up while the scheduler is ticking and the upstream instance is reachable. It is pending while
+// the scheduler is lagging, or while too many pushes to the upstream instance fail. It is down while checks are
+// stuck, or while every push to the upstream instance fails. The message names every cause.
+type SelfMonitor struct {
+	// PushID is the push token of the monitor on the upstream instance.
+	PushID string
+	// Interval is how often the heartbeat is pushed. Defaults to 30 seconds.
+	Interval time.Duration
+	// UpstreamErrorRate is the share of failed pushes to the upstream instance within an interval, between 0 and 1,
+	// from which the heartbeat is pending. Defaults to 0.5.
+	UpstreamErrorRate float64
+	// Agent and Server are the parts of roselite whose health is reported, either can be nil. The heartbeat is pushed
+	// through the upstream of the agent if it is set, or else of the server.
+	Agent  *Agent
+	Server *Server
+}
+
+// pushCounter tells how many pushes happened, and how many failed, since the previous interval.
+type pushCounter struct {
+	pushes   int64
+	failures int64
+}
+
+// selfHealth is the health of roselite over an interval, made of the causes that degrade it.
+type selfHealth struct {
+	status HeartbeatStatus
+	causes []string
+}
+
+func (h *selfHealth) degrade(status HeartbeatStatus, format string, args ...any) {
+	if status == HeartbeatStatusDown || h.status == HeartbeatStatusUp {
+		h.status = status
+	}
+	h.causes = append(h.causes, fmt.Sprintf(format, args...))
+}
+
+// Run pushes the heartbeat on every interval until the context is done.
+func (m *SelfMonitor) Run(ctx context.Context) {
+	interval := m.Interval
+	if interval <= 0 {
+		interval = defaultSelfMonitorInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var agentPushes, serverPushes pushCounter
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		heartbeat := m.heartbeat(interval, &agentPushes, &serverPushes)
+		if err := m.push(ctx, heartbeat); err != nil && ctx.Err() == nil {
+			slog.Warn("failed to push the self monitor", slog.String("monitor_id", m.PushID), slog.String("error", err.Error()))
+		}
+	}
+}
+
+// heartbeat evaluates the health of roselite since the previous interval.
+func (m *SelfMonitor) heartbeat(interval time.Duration, agentPushes *pushCounter, serverPushes *pushCounter) Heartbeat {
+	errorRate := m.UpstreamErrorRate
+	if errorRate <= 0 {
+		errorRate = defaultSelfMonitorUpstreamErrorRate
+	}
+
+	health := selfHealth{status: HeartbeatStatusUp}
+	var summary []string
+
+	if m.Agent != nil {
+		status := m.Agent.SchedulerStatus()
+		summary = append(summary, fmt.Sprintf("%d monitors scheduled", status.Monitors))
+
+		if m.Agent.shutdownCtx.Err() != nil {
+			health.degrade(HeartbeatStatusDown, "agent is shut down")
+		}
+		if len(status.StuckMonitors) > 0 {
+			health.degrade(HeartbeatStatusDown, "checks are stuck for monitors: %s", strings.Join(status.StuckMonitors, ", "))
+		}
+		if len(status.LaggingMonitors) > 0 {
+			health.degrade(HeartbeatStatusPending, "scheduler is lagging by %s for monitors: %s", status.Lag.Round(time.Second), strings.Join(status.LaggingMonitors, ", "))
+		}
+
+		var lastError string
+		if lastUpstreamError := m.Agent.lastUpstreamError.Load(); lastUpstreamError != nil {
+			lastError = lastUpstreamError.message
+		}
+		agentPushes.degrade(&health, "agent", m.Agent.upstreamPushes.Load(), m.Agent.upstreamPushFailures.Load(), errorRate, interval, lastError)
+	}
+
+	if m.Server != nil {
+		var lastError string
+		if err := m.Server.upstreamHealth.snapshot().lastError; err != nil {
+			lastError = err.Error()
+		}
+		serverPushes.degrade(&health, "relayed", m.Server.metrics.upstreamPushes.Load(), m.Server.metrics.upstreamPushFailures.Load(), errorRate, interval, lastError)
+
+		if m.Server.forwardingQueue != nil && m.Server.forwardingQueue.depth() >= m.Server.forwardingQueue.capacity() {
+			health.degrade(HeartbeatStatusPending, "forwarding queue is full")
+		}
+	}
+
+	message := strings.Join(health.causes, "; ")
+	if health.status == HeartbeatStatusUp {
+		message = "healthy"
+		if len(summary) > 0 {
+			message = strings.Join(summary, ", ")
+		}
+	}
+
+	return Heartbeat{Status: health.status, AdditionalMessage: null.StringFrom(message)}
+}
+
+// degrade records the pushes since the previous interval, and degrades the health if too many of them failed.
+func (c *pushCounter) degrade(health *selfHealth, kind string, pushes int64, failures int64, errorRate float64, interval time.Duration, lastError string) {
+	recentPushes, recentFailures := pushes-c.pushes, failures-c.failures
+	c.pushes, c.failures = pushes, failures
+	if recentPushes <= 0 || recentFailures <= 0 {
+		return
+	}
+
+	switch {
+	case recentFailures >= recentPushes:
+		health.degrade(HeartbeatStatusDown, "all %d %s pushes to the upstream failed in the last %s, latest error: %s", recentPushes, kind, interval, lastError)
+	case float64(recentFailures)/float64(recentPushes) >= errorRate:
+		health.degrade(HeartbeatStatusPending, "%d of %d %s pushes to the upstream failed in the last %s, latest error: %s", recentFailures, recentPushes, kind, interval, lastError)
+	}
+}
+
+func (m *SelfMonitor) push(ctx context.Context, heartbeat Heartbeat) error {
+	var upstream *upstreamClient
+	var monitorStore *MonitorStore
+	var region, agent string
+	if m.Agent != nil {
+		upstream, monitorStore = m.Agent.upstream.Load(), m.Agent.monitorStore
+		region, agent = m.Agent.region, m.Agent.identifier
+	} else {
+		upstream, monitorStore = m.Server.upstream.Load(), m.Server.monitorStore
+		agent = m.Server.instanceIdentifier
+	}
+
+	err := callKumaEndpoint(ctx, upstream.address, upstream.requestHeaders, upstream.httpClient, m.PushID, heartbeat)
+	monitorStore.Record(m.PushID, region, agent, heartbeat, err)
+	return err
+}
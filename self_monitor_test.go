@@ -0,0 +1,80 @@
+package roselite_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/teknologi-umum/roselite"
+)
+
+func TestSelfMonitor(t *testing.T) {
+	targetServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(targetServer.Close)
+
+	var upstreamFailing atomic.Bool
+	upstreamFailing.Store(true)
+
+	var mutex sync.Mutex
+	var lastSelfPush url.Values
+	kumaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/push/self" {
+			mutex.Lock()
+			lastSelfPush = r.URL.Query()
+			mutex.Unlock()
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		if upstreamFailing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(kumaServer.Close)
+
+	selfPush := func() url.Values {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return lastSelfPush
+	}
+
+	agent := roselite.NewAgent(roselite.AgentOptions{
+		Monitors: []roselite.Monitor{
+			{ID: "web", MonitorType: roselite.MonitorTypeHTTP, MonitorTarget: targetServer.URL, Interval: time.Millisecond * 20},
+		},
+		UpstreamKumaAddress: kumaServer.URL,
+		AgentIdentifier:     "edge-1",
+	})
+	go func() {
+		_ = agent.Start()
+	}()
+	t.Cleanup(func() { _ = agent.Close() })
+
+	selfMonitor := &roselite.SelfMonitor{PushID: "self", Interval: time.Millisecond * 100, Agent: agent}
+	go selfMonitor.Run(t.Context())
+
+	waitFor(t, "the self monitor to be pushed down", func() bool {
+		return selfPush().Get("status") == "down"
+	})
+	if message := selfPush().Get("msg"); !strings.Contains(message, "agent pushes to the upstream failed") {
+		t.Errorf("expected the message to name the upstream failures, got %q", message)
+	}
+
+	upstreamFailing.Store(false)
+
+	waitFor(t, "the self monitor to be pushed up", func() bool {
+		return selfPush().Get("status") == "up"
+	})
+	if message := selfPush().Get("msg"); message != "1 monitors scheduled" {
+		t.Errorf("unexpected message: %q", message)
+	}
+}
@@ -4,20 +4,46 @@ import (
 	"context"
 	"crypto/tls"
 	"encoding/json"
-	"net"
+	"errors"
+	"fmt"
 	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/getsentry/sentry-go"
 	sentryhttp "github.com/getsentry/sentry-go/http"
-	"github.com/teknologi-umum/roselite/internal/sentryhttpclient"
 )
 
 type Server struct {
-	httpServer             *http.Server
-	upstreamKumaAddress    string
-	upstreamRequestHeaders map[string]string
-	httpClient             *http.Client
+	httpServer            *http.Server
+	upstream              atomic.Pointer[upstreamClient]
+	monitorAliases        map[string]MonitorAlias
+	strictMonitorAliases  bool
+	perMonitorRateLimiter *rateLimiter
+	perClientRateLimiter  *rateLimiter
+	globalRateLimiter     *rateLimiter
+	deduplicator          *heartbeatDeduplicator
+	batchConcurrency      int
+	forwardingQueue       *forwardingQueue
+	metrics               *serverMetrics
+	instanceIdentifier    string
+	maxHops               int
+	monitorStore          *MonitorStore
+	upstreamHealth        *upstreamHealth
+	healthChecks          []HealthCheck
+	distributor           *monitorDistributor
+	fleet                 *fleet
+	fleetOptions          atomic.Pointer[FleetOptions]
+	maintenance           *Maintenance
+	silenceOptions        atomic.Pointer[SilenceOptions]
+	statusOptions         atomic.Pointer[StatusOptions]
+	shuttingDown          atomic.Bool
+	// shutdown is closed once the server starts shutting down, to release the requests held by a long poll.
+	shutdown     chan struct{}
+	shutdownOnce sync.Once
 }
 
 type ServerOptions struct {
@@ -26,8 +52,56 @@ type ServerOptions struct {
 	UpstreamRequestHeaders map[string]string
 	UpstreamTLSConfig      *tls.Config
 	ServerTLSConfig        *tls.Config
+	// MonitorAliases maps the monitor ID received on the push endpoint to the push token of the upstream instance.
+	MonitorAliases map[string]MonitorAlias
+	// StrictMonitorAliases rejects any monitor ID that is not listed on MonitorAliases.
+	StrictMonitorAliases bool
+	// PerMonitorRateLimit limits the amount of pushes for every monitor ID.
+	PerMonitorRateLimit RateLimit
+	// PerClientRateLimit limits the amount of pushes for every client IP address.
+	PerClientRateLimit RateLimit
+	// GlobalRateLimit limits the amount of pushes the server accepts in total.
+	GlobalRateLimit RateLimit
+	// DeduplicationWindow collapses heartbeats with the same status and message for the same monitor ID that arrive
+	// within the window into a single upstream push. Zero disables the deduplication.
+	DeduplicationWindow time.Duration
+	// BatchConcurrency is the amount of heartbeats from a single batch that are pushed upstream at the same time.
+	// Defaults to 8.
+	BatchConcurrency int
+	// AsyncForwarding acknowledges pushes with 202 Accepted as soon as the heartbeat is put into an in-memory queue,
+	// instead of waiting for the upstream instance to respond.
+	AsyncForwarding bool
+	// ForwardingQueueSize is the capacity of the forwarding queue. Defaults to 1024.
+	ForwardingQueueSize int
+	// ForwardingWorkers is the amount of workers draining the forwarding queue. Defaults to 4.
+	ForwardingWorkers int
+	// InstanceIdentifier identifies this server on the hops header when relays are chained. It must be unique across
+	// the chain. Defaults to the hostname.
+	InstanceIdentifier string
+	// MaxHops is the maximum amount of relays a heartbeat can pass through before it is rejected. Defaults to 8.
+	MaxHops int
+	// MonitorStore keeps the last heartbeats of every monitor ID seen on the push endpoint. Share the same store with
+	// an Agent to list its monitors on the status API too. Defaults to a new store.
+	MonitorStore *MonitorStore
+	// HealthChecks are reported on the readiness endpoint, along with the reachability of the upstream instance.
+	HealthChecks []HealthCheck
+	// MonitorDistribution hands out monitors to the agents on the /api/agent/monitors endpoint. Leave it nil to disable
+	// the endpoint.
+	MonitorDistribution *MonitorDistribution
+	// Fleet tracks the agents registered with the server on the /api/agents endpoints. Leave it nil to disable them.
+	Fleet *FleetOptions
+	// Maintenance holds the maintenance windows and the silences the relayed heartbeats are subject to. Share the same
+	// value with an Agent for the silences to apply to its checks too. Defaults to no maintenance.
+	Maintenance *Maintenance
+	// Silences enables the /api/silences endpoints to create ad-hoc silences. Leave it nil to disable them.
+	Silences *SilenceOptions
+	// Status enables the /api/monitors endpoints and the /status page. Leave it nil to disable them.
+	Status *StatusOptions
 }
 
+// maxPushBodySize is the maximum size of a request body accepted by the push endpoint.
+const maxPushBodySize = 1 << 20
+
 type remoteWriteResponse struct {
 	Ok bool `json:"ok"`
 }
@@ -35,24 +109,64 @@ type remoteWriteResponse struct {
 func NewServer(options ServerOptions) *Server {
 	sentryMiddleware := sentryhttp.New(sentryhttp.Options{})
 
-	httpClientTransport := &http.Transport{
-		// Adapted from http.DefaultTransport
-		Proxy: http.ProxyFromEnvironment,
-		DialContext: (&net.Dialer{
-			Timeout:   30 * time.Second,
-			KeepAlive: 30 * time.Second,
-		}).DialContext,
-		ForceAttemptHTTP2:     true,
-		MaxIdleConns:          100,
-		IdleConnTimeout:       90 * time.Second,
-		TLSHandshakeTimeout:   10 * time.Second,
-		ExpectContinueTimeout: 1 * time.Second,
-		TLSClientConfig:       options.UpstreamTLSConfig,
+	batchConcurrency := options.BatchConcurrency
+	if batchConcurrency <= 0 {
+		batchConcurrency = defaultBatchConcurrency
+	}
+
+	instanceIdentifier := options.InstanceIdentifier
+	if instanceIdentifier == "" {
+		instanceIdentifier = defaultInstanceIdentifier()
+	}
+
+	maxHops := options.MaxHops
+	if maxHops <= 0 {
+		maxHops = defaultMaxHops
+	}
+
+	monitorStore := options.MonitorStore
+	if monitorStore == nil {
+		monitorStore = NewMonitorStore(0)
+	}
+
+	maintenance := options.Maintenance
+	if maintenance == nil {
+		maintenance = NewMaintenance(nil)
 	}
 
-	httpClient := &http.Client{
-		Transport: sentryhttpclient.NewSentryRoundTripper(httpClientTransport),
-		Timeout:   time.Minute * 3,
+	monitorAliases := make(map[string]MonitorAlias, len(options.MonitorAliases))
+	for id, alias := range options.MonitorAliases {
+		monitorAliases[id] = alias
+	}
+
+	s := &Server{
+		monitorAliases:        monitorAliases,
+		strictMonitorAliases:  options.StrictMonitorAliases,
+		perMonitorRateLimiter: newRateLimiter(options.PerMonitorRateLimit),
+		perClientRateLimiter:  newRateLimiter(options.PerClientRateLimit),
+		globalRateLimiter:     newRateLimiter(options.GlobalRateLimit),
+		deduplicator:          newHeartbeatDeduplicator(options.DeduplicationWindow),
+		batchConcurrency:      batchConcurrency,
+		metrics:               new(serverMetrics),
+		instanceIdentifier:    instanceIdentifier,
+		maxHops:               maxHops,
+		monitorStore:          monitorStore,
+		upstreamHealth:        new(upstreamHealth),
+		healthChecks:          options.HealthChecks,
+		distributor:           newMonitorDistributor(options.MonitorDistribution),
+		fleet:                 newFleet(),
+		maintenance:           maintenance,
+		shutdown:              make(chan struct{}),
+	}
+	s.fleetOptions.Store(options.Fleet)
+	s.silenceOptions.Store(options.Silences)
+	s.statusOptions.Store(options.Status)
+	s.upstream.Store(newUpstreamClient(options.UpstreamKumaAddress, options.UpstreamRequestHeaders, options.UpstreamTLSConfig))
+	if options.AsyncForwarding {
+		s.forwardingQueue = newForwardingQueue(options.ForwardingQueueSize, options.ForwardingWorkers, func(job forwardingJob) {
+			_ = s.forward(job)
+			job.transaction.Finish()
+		})
 	}
 
 	mux := http.NewServeMux()
@@ -61,59 +175,192 @@ func NewServer(options ServerOptions) *Server {
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("OK"))
 	})
+	mux.HandleFunc("GET /healthz", s.handleLiveness)
+	mux.HandleFunc("GET /readyz", s.handleReadiness)
+	mux.HandleFunc("/api/push/{id}", s.handlePush)
+	mux.HandleFunc("POST /api/push/batch", s.handleBatchPush)
+	mux.HandleFunc("GET /metrics", s.handleMetrics)
+	mux.HandleFunc("GET /api/monitors", s.handleListMonitors)
+	mux.HandleFunc("GET /api/monitors/{id}", s.handleGetMonitor)
+	mux.HandleFunc("GET /status", s.handleStatusPage)
+	mux.HandleFunc("GET /api/agent/monitors", s.handleDistributedMonitors)
+	mux.HandleFunc("POST /api/agents/{id}/heartbeat", s.handleAgentHeartbeat)
+	mux.HandleFunc("GET /api/agents", s.handleListAgents)
+	mux.HandleFunc("GET /api/agents/{id}", s.handleGetAgent)
+	mux.HandleFunc("DELETE /api/agents/{id}", s.handleRemoveAgent)
+	mux.HandleFunc("GET /api/silences", s.handleListSilences)
+	mux.HandleFunc("POST /api/silences", s.handleCreateSilence)
+	mux.HandleFunc("DELETE /api/silences/{id}", s.handleRemoveSilence)
 
-	mux.HandleFunc("/api/push/{id}", func(w http.ResponseWriter, r *http.Request) {
-		if options.UpstreamKumaAddress == "" {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusPreconditionFailed)
-			_ = json.NewEncoder(w).Encode(remoteWriteResponse{Ok: false})
-			return
-		}
+	s.httpServer = &http.Server{
+		Addr:              options.ListeningAddress,
+		Handler:           sentryMiddleware.Handle(mux),
+		TLSConfig:         options.ServerTLSConfig,
+		ReadTimeout:       time.Minute,
+		ReadHeaderTimeout: time.Minute,
+		WriteTimeout:      time.Minute,
+		IdleTimeout:       time.Minute,
+	}
+
+	return s
+}
+
+func (s *Server) handlePush(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxPushBodySize)
+	heartbeat, err := HeartbeatFromRequest(r)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(remoteWriteResponse{Ok: false})
+		return
+	}
+
+	result := s.relay(r.Context(), r.PathValue("id"), relayOriginFromRequest(r), heartbeat)
+	if result.retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.FormatInt(retryAfterSeconds(result.retryAfter), 10))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(result.statusCode)
+	_ = json.NewEncoder(w).Encode(remoteWriteResponse{Ok: result.err == nil})
+}
+
+var (
+	errMonitorIDEmpty        = errors.New("monitor id is empty")
+	errMonitorIDNotAllowed   = errors.New("monitor id is not allowed")
+	errUpstreamNotConfigured = errors.New("upstream address is not configured")
+	errRateLimitExceeded     = errors.New("rate limit exceeded")
+	errUpstreamPushFailed    = errors.New("failed to push heartbeat to upstream")
+	errForwardingQueueFull   = errors.New("forwarding queue is full")
+	errRelayLoopDetected     = errors.New("heartbeat already passed through this relay")
+	errMaxHopsExceeded       = errors.New("heartbeat passed through too many relays")
+	errServerShuttingDown    = errors.New("server is shutting down")
+)
+
+// relayResult describes the outcome of relaying a single heartbeat to the upstream instance.
+// The error is safe to be shown to the client, as it does not contain the upstream address nor the push token.
+type relayResult struct {
+	statusCode int
+	retryAfter time.Duration
+	err        error
+}
+
+// relay resolves the monitor ID, applies the rate limits and the maintenance, then pushes the heartbeat to the upstream
+// instance.
+func (s *Server) relay(ctx context.Context, id string, origin relayOrigin, heartbeat Heartbeat) relayResult {
+	if s.shuttingDown.Load() {
+		return relayResult{statusCode: http.StatusServiceUnavailable, err: errServerShuttingDown}
+	}
+
+	if id == "" {
+		return relayResult{statusCode: http.StatusPreconditionFailed, err: errMonitorIDEmpty}
+	}
+
+	if slices.Contains(origin.hops, s.instanceIdentifier) {
+		return relayResult{statusCode: http.StatusLoopDetected, err: errRelayLoopDetected}
+	}
 
-		id := r.PathValue("id")
-		if id == "" {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusPreconditionFailed)
-			_ = json.NewEncoder(w).Encode(remoteWriteResponse{Ok: false})
-			return
+	if len(origin.hops) >= s.maxHops {
+		return relayResult{statusCode: http.StatusLoopDetected, err: errMaxHopsExceeded}
+	}
+
+	upstream := s.upstream.Load()
+	upstreamKumaAddress, upstreamID, ok := s.resolveMonitorAlias(upstream, id)
+	if !ok {
+		return relayResult{statusCode: http.StatusNotFound, err: errMonitorIDNotAllowed}
+	}
+
+	if upstreamKumaAddress == "" {
+		return relayResult{statusCode: http.StatusPreconditionFailed, err: errUpstreamNotConfigured}
+	}
+
+	now := time.Now()
+	if ok, retryAfter := s.allowPush(id, origin.clientAddress, now); !ok {
+		s.metrics.rateLimitedRequests.Add(1)
+		return relayResult{statusCode: http.StatusTooManyRequests, retryAfter: retryAfter, err: errRateLimitExceeded}
+	}
+
+	heartbeat, ok = s.applyMaintenance(id, heartbeat, now)
+	if !ok {
+		return relayResult{statusCode: http.StatusOK}
+	}
+
+	if !s.deduplicator.reserve(id, heartbeat, now) {
+		s.metrics.deduplicatedRequests.Add(1)
+		return relayResult{statusCode: http.StatusOK}
+	}
+
+	job := forwardingJob{
+		ctx:                 context.WithoutCancel(ctx),
+		id:                  id,
+		origin:              origin,
+		upstreamKumaAddress: upstreamKumaAddress,
+		upstreamID:          upstreamID,
+		requestHeaders:      s.upstreamRequestHeadersFor(upstream, origin),
+		httpClient:          upstream.httpClient,
+		heartbeat:           heartbeat,
+		receivedAt:          now,
+	}
+
+	if s.forwardingQueue != nil {
+		job.ctx, job.transaction = detachedContext(ctx, "Server.forward")
+		if !s.forwardingQueue.enqueue(job) {
+			job.transaction.Finish()
+			s.deduplicator.release(id, heartbeat, now)
+			s.metrics.forwardingQueueDrops.Add(1)
+			return relayResult{statusCode: http.StatusServiceUnavailable, retryAfter: time.Second, err: errForwardingQueueFull}
 		}
 
-		err := callKumaEndpoint(context.WithoutCancel(r.Context()),
-			options.UpstreamKumaAddress,
-			options.UpstreamRequestHeaders,
-			httpClient,
-			id,
-			HeartbeatFromQuery(r.URL.Query()),
-		)
-		if err != nil {
-			sentry.GetHubFromContext(r.Context()).CaptureException(err)
-
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusInternalServerError)
-			_ = json.NewEncoder(w).Encode(remoteWriteResponse{Ok: false})
-			return
+		s.metrics.forwardingQueueQueued.Add(1)
+		return relayResult{statusCode: http.StatusAccepted}
+	}
+
+	if err := s.forward(job); err != nil {
+		return relayResult{statusCode: http.StatusInternalServerError, err: errUpstreamPushFailed}
+	}
+
+	return relayResult{statusCode: http.StatusOK}
+}
+
+// forward pushes the heartbeat of the job to the upstream instance.
+func (s *Server) forward(job forwardingJob) error {
+	err := callKumaEndpoint(job.ctx,
+		job.upstreamKumaAddress,
+		job.requestHeaders,
+		job.httpClient,
+		job.upstreamID,
+		job.heartbeat,
+	)
+	if err != nil {
+		err = fmt.Errorf("%w: %w", errUpstreamPushFailed, err)
+	}
+	s.monitorStore.Record(job.id, job.origin.region, job.origin.agent, job.heartbeat, err)
+	s.upstreamHealth.record(err, time.Now())
+	if err != nil {
+		s.metrics.upstreamPushFailures.Add(1)
+		s.deduplicator.release(job.id, job.heartbeat, job.receivedAt)
+		if hub := sentry.GetHubFromContext(job.ctx); hub != nil {
+			hub.CaptureException(err)
 		}
+		return err
+	}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		_ = json.NewEncoder(w).Encode(remoteWriteResponse{Ok: true})
-	})
-	s := &Server{
-		httpServer: &http.Server{
-			Addr:              options.ListeningAddress,
-			Handler:           sentryMiddleware.Handle(mux),
-			TLSConfig:         options.ServerTLSConfig,
-			ReadTimeout:       time.Minute,
-			ReadHeaderTimeout: time.Minute,
-			WriteTimeout:      time.Minute,
-			IdleTimeout:       time.Minute,
-		},
-		httpClient:             httpClient,
-		upstreamKumaAddress:    options.UpstreamKumaAddress,
-		upstreamRequestHeaders: options.UpstreamRequestHeaders,
+	s.metrics.upstreamPushes.Add(1)
+	return nil
+}
+
+// SetUpstream replaces the default upstream instance and its settings. Pushes that are already being forwarded, or
+// are waiting in the forwarding queue, still use the previous settings.
+func (s *Server) SetUpstream(upstreamKumaAddress string, upstreamRequestHeaders map[string]string, upstreamTLSConfig *tls.Config) {
+	previous := s.upstream.Swap(newUpstreamClient(upstreamKumaAddress, upstreamRequestHeaders, upstreamTLSConfig))
+	if previous != nil {
+		previous.close()
 	}
+}
 
-	return s
+// Handler returns the HTTP handler of the server, including every middleware.
+func (s *Server) Handler() http.Handler {
+	return s.httpServer.Handler
 }
 
 func (s *Server) ListenAndServe() error {
@@ -127,6 +374,19 @@ func (s *Server) ListenAndServeTLS() error {
 	return s.httpServer.ListenAndServeTLS("", "")
 }
 
+// Shutdown stops accepting new pushes and waits for the in-flight requests to complete, then waits for the
+// forwarding queue to be drained. The readiness endpoint reports the server as not ready from this point on.
 func (s *Server) Shutdown(ctx context.Context) error {
-	return s.httpServer.Shutdown(ctx)
+	s.shuttingDown.Store(true)
+	s.shutdownOnce.Do(func() {
+		close(s.shutdown)
+	})
+	err := s.httpServer.Shutdown(ctx)
+	if s.forwardingQueue != nil {
+		if drainErr := s.forwardingQueue.drain(ctx); drainErr != nil {
+			err = errors.Join(err, fmt.Errorf("draining forwarding queue: %w", drainErr))
+		}
+	}
+
+	return err
 }
@@ -0,0 +1,35 @@
+package roselite
+
+// MonitorAlias points a local monitor ID to a push token on the upstream Uptime Kuma instance, so the real
+// push token does not need to be handed to every application.
+type MonitorAlias struct {
+	// UpstreamID is the push token on the upstream instance.
+	UpstreamID string
+	// UpstreamKumaAddress overrides the upstream address for this alias. Empty means the server's default.
+	UpstreamKumaAddress string
+}
+
+// resolveMonitorAlias returns the upstream address and the upstream push token for the given local monitor ID.
+// The returned bool is false if the ID is not known and the server is running in strict mode.
+func (s *Server) resolveMonitorAlias(upstream *upstreamClient, id string) (upstreamKumaAddress string, upstreamID string, ok bool) {
+	alias, found := s.monitorAliases[id]
+	if !found {
+		if s.strictMonitorAliases {
+			return "", "", false
+		}
+
+		return upstream.address, id, true
+	}
+
+	upstreamKumaAddress = upstream.address
+	if alias.UpstreamKumaAddress != "" {
+		upstreamKumaAddress = alias.UpstreamKumaAddress
+	}
+
+	upstreamID = alias.UpstreamID
+	if upstreamID == "" {
+		upstreamID = id
+	}
+
+	return upstreamKumaAddress, upstreamID, true
+}
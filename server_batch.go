@@ -0,0 +1,136 @@
+package roselite
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sync"
+)
+
+const (
+	// maxBatchBodySize is the maximum size of a request body accepted by the batch push endpoint.
+	maxBatchBodySize = 8 << 20
+	// maxBatchItems is the maximum amount of heartbeats accepted in a single batch.
+	maxBatchItems = 1000
+	// defaultBatchConcurrency is the amount of heartbeats from a single batch that are pushed to the upstream
+	// instance at the same time, if ServerOptions.BatchConcurrency is not set.
+	defaultBatchConcurrency = 8
+)
+
+// BatchPushItem is a single heartbeat on the batch push endpoint.
+type BatchPushItem struct {
+	ID        string    `json:"id"`
+	Heartbeat Heartbeat `json:"heartbeat"`
+}
+
+// BatchPushResult is the outcome of relaying a single BatchPushItem, in the same order as the request.
+type BatchPushResult struct {
+	ID         string `json:"id"`
+	Ok         bool   `json:"ok"`
+	StatusCode int    `json:"status_code"`
+	Error      string `json:"error,omitempty"`
+}
+
+type batchPushResponse struct {
+	Ok      bool              `json:"ok"`
+	Results []BatchPushResult `json:"results"`
+}
+
+// decodeBatchPushItems reads the batch from either a JSON array, or newline-delimited JSON objects if the
+// content type is application/x-ndjson.
+func decodeBatchPushItems(r *http.Request) ([]BatchPushItem, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/x-ndjson" {
+		var rawItems []json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&rawItems); err != nil {
+			return nil, fmt.Errorf("decoding json body: %w", err)
+		}
+
+		items := make([]BatchPushItem, len(rawItems))
+		for i, rawItem := range rawItems {
+			items[i] = BatchPushItem{Heartbeat: Heartbeat{Status: HeartbeatStatusUnknown}}
+			if err := json.Unmarshal(rawItem, &items[i]); err != nil {
+				return nil, fmt.Errorf("decoding item %d: %w", i, err)
+			}
+		}
+
+		return items, nil
+	}
+
+	var items []BatchPushItem
+	scanner := bufio.NewScanner(r.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxPushBodySize)
+	for line := 1; scanner.Scan(); line++ {
+		content := bytes.TrimSpace(scanner.Bytes())
+		if len(content) == 0 {
+			continue
+		}
+
+		item := BatchPushItem{Heartbeat: Heartbeat{Status: HeartbeatStatusUnknown}}
+		if err := json.Unmarshal(content, &item); err != nil {
+			return nil, fmt.Errorf("decoding line %d: %w", line, err)
+		}
+
+		items = append(items, item)
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("reading body: %w", err)
+	}
+
+	return items, nil
+}
+
+func (s *Server) handleBatchPush(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBatchBodySize)
+	items, err := decodeBatchPushItems(r)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(items) > maxBatchItems {
+		writeErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("batch exceeds %d items", maxBatchItems))
+		return
+	}
+
+	origin := relayOriginFromRequest(r)
+	results := make([]BatchPushResult, len(items))
+	semaphore := make(chan struct{}, s.batchConcurrency)
+	var wg sync.WaitGroup
+	for i, item := range items {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		ctx, transaction := detachedContext(r.Context(), "Server.handleBatchPush.item")
+		go func(i int, item BatchPushItem) {
+			defer func() {
+				transaction.Finish()
+				<-semaphore
+				wg.Done()
+			}()
+
+			result := s.relay(ctx, item.ID, origin, item.Heartbeat)
+			results[i] = BatchPushResult{
+				ID:         item.ID,
+				Ok:         result.err == nil,
+				StatusCode: result.statusCode,
+			}
+			if result.err != nil {
+				results[i].Error = result.err.Error()
+			}
+		}(i, item)
+	}
+	wg.Wait()
+
+	ok := true
+	for _, result := range results {
+		ok = ok && result.Ok
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(batchPushResponse{Ok: ok, Results: results})
+}
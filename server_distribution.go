@@ -0,0 +1,186 @@
+package roselite
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultDistributionWait is how long a request for the distributed monitors is held when they did not change, if
+	// the agent does not ask for another duration.
+	defaultDistributionWait = time.Second * 30
+	// maximumDistributionWait keeps a held request well within the write timeout of the server.
+	maximumDistributionWait = time.Second * 45
+)
+
+// AgentSelector selects agents by their region and their identity. An empty list selects every agent.
+type AgentSelector struct {
+	Regions []string
+	Agents  []string
+}
+
+// Selects reports whether the agent with the given region and identity is selected.
+func (s AgentSelector) Selects(region string, agent string) bool {
+	return (len(s.Regions) == 0 || slices.Contains(s.Regions, region)) &&
+		(len(s.Agents) == 0 || slices.Contains(s.Agents, agent))
+}
+
+// DistributedMonitor is a monitor the server hands out to the agents it selects.
+type DistributedMonitor struct {
+	AgentSelector
+	// Definition is the monitor encoded the way the agents decode it.
+	Definition json.RawMessage
+}
+
+// MonitorDistribution is the set of monitors the server hands out to the agents on the /api/agent/monitors endpoint.
+type MonitorDistribution struct {
+	Monitors []DistributedMonitor
+	// Tokens are the bearer tokens the agents authenticate with. Leaving it empty lets any client read the monitors.
+	Tokens []string
+}
+
+// distributedMonitorsResponse is the response of the /api/agent/monitors endpoint.
+type distributedMonitorsResponse struct {
+	// Version changes whenever the monitors of the agent change, and is sent back on If-None-Match to wait for it.
+	Version  string            `json:"version"`
+	Monitors []json.RawMessage `json:"monitors"`
+}
+
+// monitorDistributor holds the current distribution, and wakes up the waiting requests when it is replaced.
+type monitorDistributor struct {
+	mutex        sync.Mutex
+	distribution *MonitorDistribution
+	changed      chan struct{}
+}
+
+func newMonitorDistributor(distribution *MonitorDistribution) *monitorDistributor {
+	return &monitorDistributor{distribution: distribution, changed: make(chan struct{})}
+}
+
+func (d *monitorDistributor) set(distribution *MonitorDistribution) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.distribution = distribution
+	close(d.changed)
+	d.changed = make(chan struct{})
+}
+
+func (d *monitorDistributor) get() (*MonitorDistribution, <-chan struct{}) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	return d.distribution, d.changed
+}
+
+// SetMonitorDistribution replaces the monitors handed out to the agents. Agents waiting for a change receive the new
+// monitors right away. A nil distribution disables the endpoint.
+func (s *Server) SetMonitorDistribution(distribution *MonitorDistribution) {
+	s.distributor.set(distribution)
+}
+
+// handleDistributedMonitors responds with the monitors selected by the region and the identity headers of the agent.
+// If the If-None-Match header holds the current version, the request is held until the monitors change, or until
+// the duration of the wait query parameter is over, which ends with 304 Not Modified.
+func (s *Server) handleDistributedMonitors(w http.ResponseWriter, r *http.Request) {
+	wait := defaultDistributionWait
+	if value := r.URL.Query().Get("wait"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		wait = min(parsed, maximumDistributionWait)
+	}
+
+	region, agent := r.Header.Get(regionHeader), r.Header.Get(agentHeader)
+	knownVersion := strings.Trim(r.Header.Get("If-None-Match"), `"`)
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		distribution, changed := s.distributor.get()
+		if distribution == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if !bearerTokenAuthorized(distribution.Tokens, r) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		response := distributedMonitorsFor(distribution, region, agent)
+		if response.Version != knownVersion {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("ETag", `"`+response.Version+`"`)
+			w.WriteHeader(http.StatusOK)
+			_ = json.NewEncoder(w).Encode(response)
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			w.Header().Set("ETag", `"`+response.Version+`"`)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		case <-s.shutdown:
+			// http.Server.Shutdown does not cancel the context of the requests, the agent asks again later.
+			w.Header().Set("ETag", `"`+response.Version+`"`)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// bearerTokenAuthorized reports whether the request is authenticated with one of the bearer tokens. Every request is
+// authorized if there is no token.
+func bearerTokenAuthorized(tokens []string, r *http.Request) bool {
+	if len(tokens) == 0 {
+		return true
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+
+	for _, expected := range tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// distributedMonitorsFor returns the monitors the distribution hands out to the agent, versioned by their content.
+func distributedMonitorsFor(distribution *MonitorDistribution, region string, agent string) distributedMonitorsResponse {
+	monitors := make([]json.RawMessage, 0, len(distribution.Monitors))
+	hash := sha256.New()
+	for _, monitor := range distribution.Monitors {
+		if !monitor.Selects(region, agent) {
+			continue
+		}
+
+		monitors = append(monitors, monitor.Definition)
+		_, _ = hash.Write(monitor.Definition)
+		_, _ = hash.Write([]byte{0})
+	}
+
+	return distributedMonitorsResponse{
+		Version:  hex.EncodeToString(hash.Sum(nil)[:16]),
+		Monitors: monitors,
+	}
+}
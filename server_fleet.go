@@ -0,0 +1,352 @@
+package roselite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/guregu/null/v6"
+)
+
+const (
+	// defaultFleetPushInterval is how often the status of the fleet is pushed if FleetOptions.PushInterval is not set.
+	defaultFleetPushInterval = time.Second * 30
+	// fleetSilenceHeartbeats is the amount of self-heartbeats an agent misses before it is considered silent, if
+	// FleetOptions.SilenceTimeout is not set.
+	fleetSilenceHeartbeats = 3
+	// maxFleetAgents is the maximum amount of agents the fleet tracks. New agents are rejected once it is reached.
+	maxFleetAgents = 1_000
+	// maxAgentIdentifierLength is the maximum length of an agent identifier, which is usually a hostname.
+	maxAgentIdentifierLength = 253
+)
+
+// FleetOptions configures the tracking of the agents registered with the server.
+type FleetOptions struct {
+	// Tokens are the bearer tokens the agents authenticate with, also required to list and remove the agents. Leaving
+	// it empty lets any client register an agent.
+	Tokens []string
+	// SilenceTimeout is how long an agent can go without a self-heartbeat before it is considered silent. Defaults to
+	// three times the interval of the agent.
+	SilenceTimeout time.Duration
+	// PushID is the push token of a monitor on the upstream instance for the whole fleet. It is pushed up while every
+	// agent is reporting, and down with the silent agents otherwise.
+	PushID string
+	// AgentPushIDs maps an agent identifier to the push token of a monitor on the upstream instance for that agent
+	// alone. It is pushed down while the agent is silent, or if it never registered.
+	AgentPushIDs map[string]string
+	// PushInterval is how often the push IDs are pushed. Defaults to 30 seconds.
+	PushInterval time.Duration
+}
+
+// AgentStatus describes an agent registered with the server, as of its last self-heartbeat.
+type AgentStatus struct {
+	ID string `json:"id"`
+	AgentHeartbeat
+	// Address is the IP address the last self-heartbeat came from.
+	Address      string    `json:"address"`
+	RegisteredAt time.Time `json:"registered_at"`
+	LastSeenAt   time.Time `json:"last_seen_at"`
+	// Silent is set once the agent missed its self-heartbeats for longer than the silence timeout.
+	Silent bool `json:"silent"`
+}
+
+type agentListResponse struct {
+	Agents []AgentStatus `json:"agents"`
+}
+
+// fleet holds the agents registered with the server.
+type fleet struct {
+	mutex  sync.Mutex
+	agents map[string]*AgentStatus
+	// silent are the agents that were silent on the last push, to log the changes.
+	silent map[string]bool
+}
+
+func newFleet() *fleet {
+	return &fleet{agents: make(map[string]*AgentStatus), silent: make(map[string]bool)}
+}
+
+// record registers the agent, or updates it with its self-heartbeat. It reports false if the agent is new and the
+// fleet is full.
+func (f *fleet) record(id string, address string, heartbeat AgentHeartbeat, now time.Time) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	agent, ok := f.agents[id]
+	if !ok {
+		if len(f.agents) >= maxFleetAgents {
+			return false
+		}
+
+		agent = &AgentStatus{ID: id, RegisteredAt: now}
+		f.agents[id] = agent
+		slog.Info("agent registered", slog.String("agent", id), slog.String("region", heartbeat.Region))
+	}
+
+	agent.AgentHeartbeat = heartbeat
+	agent.Address = address
+	agent.LastSeenAt = now
+	return true
+}
+
+func (f *fleet) remove(id string) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	_, ok := f.agents[id]
+	delete(f.agents, id)
+	delete(f.silent, id)
+	return ok
+}
+
+// statuses returns every agent sorted by identifier, with their silence as of now.
+func (f *fleet) statuses(options *FleetOptions, now time.Time) []AgentStatus {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	statuses := make([]AgentStatus, 0, len(f.agents))
+	for _, id := range slices.Sorted(maps.Keys(f.agents)) {
+		status := *f.agents[id]
+		status.Silent = now.Sub(status.LastSeenAt) > silenceTimeout(options, status.AgentHeartbeat)
+		statuses = append(statuses, status)
+	}
+
+	return statuses
+}
+
+// silenceTimeout returns how long the agent can go without a self-heartbeat before it is considered silent.
+func silenceTimeout(options *FleetOptions, heartbeat AgentHeartbeat) time.Duration {
+	if options != nil && options.SilenceTimeout > 0 {
+		return options.SilenceTimeout
+	}
+
+	interval := time.Duration(heartbeat.IntervalSeconds * float64(time.Second))
+	if interval <= 0 {
+		interval = defaultRegistrationInterval
+	}
+
+	return interval * fleetSilenceHeartbeats
+}
+
+// SetFleet replaces the options of the fleet. The registered agents are kept. A nil value disables the fleet
+// endpoints and the pushes of the fleet status.
+func (s *Server) SetFleet(options *FleetOptions) {
+	s.fleetOptions.Store(options)
+}
+
+// Agents returns the agents registered with the server, sorted by identifier.
+func (s *Server) Agents() []AgentStatus {
+	return s.fleet.statuses(s.fleetOptions.Load(), time.Now())
+}
+
+func (s *Server) handleAgentHeartbeat(w http.ResponseWriter, r *http.Request) {
+	options := s.fleetOptions.Load()
+	if options == nil {
+		writeErrorResponse(w, http.StatusNotFound, "fleet is not enabled")
+		return
+	}
+
+	if !bearerTokenAuthorized(options.Tokens, r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeErrorResponse(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+
+	id := r.PathValue("id")
+	if id == "" || len(id) > maxAgentIdentifierLength {
+		writeErrorResponse(w, http.StatusBadRequest, "invalid agent identifier")
+		return
+	}
+
+	var heartbeat AgentHeartbeat
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPushBodySize)).Decode(&heartbeat); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "invalid agent heartbeat")
+		return
+	}
+
+	if !s.fleet.record(id, clientAddress(r), heartbeat, time.Now()) {
+		writeErrorResponse(w, http.StatusServiceUnavailable, "too many agents are registered")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(remoteWriteResponse{Ok: true})
+}
+
+func (s *Server) handleListAgents(w http.ResponseWriter, r *http.Request) {
+	options := s.fleetOptions.Load()
+	if options == nil {
+		writeErrorResponse(w, http.StatusNotFound, "fleet is not enabled")
+		return
+	}
+
+	if !bearerTokenAuthorized(options.Tokens, r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeErrorResponse(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(agentListResponse{Agents: s.Agents()})
+}
+
+func (s *Server) handleGetAgent(w http.ResponseWriter, r *http.Request) {
+	options := s.fleetOptions.Load()
+	if options == nil {
+		writeErrorResponse(w, http.StatusNotFound, "fleet is not enabled")
+		return
+	}
+
+	if !bearerTokenAuthorized(options.Tokens, r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeErrorResponse(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+
+	for _, agent := range s.Agents() {
+		if agent.ID == r.PathValue("id") {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			_ = json.NewEncoder(w).Encode(agent)
+			return
+		}
+	}
+
+	writeErrorResponse(w, http.StatusNotFound, "agent not found")
+}
+
+// handleRemoveAgent forgets a decommissioned agent, so it is no longer reported as silent.
+func (s *Server) handleRemoveAgent(w http.ResponseWriter, r *http.Request) {
+	options := s.fleetOptions.Load()
+	if options == nil {
+		writeErrorResponse(w, http.StatusNotFound, "fleet is not enabled")
+		return
+	}
+
+	if !bearerTokenAuthorized(options.Tokens, r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeErrorResponse(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+
+	if !s.fleet.remove(r.PathValue("id")) {
+		writeErrorResponse(w, http.StatusNotFound, "agent not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(remoteWriteResponse{Ok: true})
+}
+
+func writeErrorResponse(w http.ResponseWriter, statusCode int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(errorResponse{Ok: false, Error: message})
+}
+
+// WatchFleet pushes the status of the fleet to the push IDs of FleetOptions on every push interval, until the
+// context is done or the server is shut down. A dead agent is then reported on its own, instead of as every one of
+// its monitors being down.
+func (s *Server) WatchFleet(ctx context.Context) {
+	for {
+		pushInterval := defaultFleetPushInterval
+		if options := s.fleetOptions.Load(); options != nil && options.PushInterval > 0 {
+			pushInterval = options.PushInterval
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(pushInterval):
+		}
+
+		if s.shuttingDown.Load() {
+			return
+		}
+
+		if options := s.fleetOptions.Load(); options != nil {
+			s.pushFleetStatus(ctx, options, time.Now())
+		}
+	}
+}
+
+// pushFleetStatus pushes the heartbeats of the fleet and of every agent with a push ID, and logs the agents that went
+// silent or started reporting again.
+func (s *Server) pushFleetStatus(ctx context.Context, options *FleetOptions, now time.Time) {
+	statuses := s.fleet.statuses(options, now)
+	byID := make(map[string]AgentStatus, len(statuses))
+	var silent []string
+	for _, status := range statuses {
+		byID[status.ID] = status
+		if status.Silent {
+			silent = append(silent, fmt.Sprintf("%s (%s, last seen %s ago)", status.ID, status.Region, now.Sub(status.LastSeenAt).Round(time.Second)))
+		}
+	}
+	s.logFleetChanges(statuses)
+
+	if options.PushID != "" {
+		heartbeat := Heartbeat{Status: HeartbeatStatusUp, AdditionalMessage: null.StringFrom(fmt.Sprintf("%d agents are reporting", len(statuses)))}
+		if len(silent) > 0 {
+			heartbeat = Heartbeat{Status: HeartbeatStatusDown, AdditionalMessage: null.StringFrom(fmt.Sprintf("%d of %d agents are silent: %s", len(silent), len(statuses), strings.Join(silent, ", ")))}
+		}
+		s.pushFleetHeartbeat(ctx, options.PushID, heartbeat)
+	}
+
+	for _, id := range slices.Sorted(maps.Keys(options.AgentPushIDs)) {
+		status, ok := byID[id]
+		var heartbeat Heartbeat
+		switch {
+		case !ok:
+			heartbeat = Heartbeat{Status: HeartbeatStatusDown, AdditionalMessage: null.StringFrom(fmt.Sprintf("agent %s is not registered", id))}
+		case status.Silent:
+			heartbeat = Heartbeat{Status: HeartbeatStatusDown, AdditionalMessage: null.StringFrom(fmt.Sprintf("agent %s is silent since %s", id, status.LastSeenAt.Format(time.RFC3339)))}
+		default:
+			heartbeat = Heartbeat{Status: HeartbeatStatusUp, AdditionalMessage: null.StringFrom(fmt.Sprintf("agent %s is checking %d monitors", id, status.Monitors))}
+		}
+		s.pushFleetHeartbeat(ctx, options.AgentPushIDs[id], heartbeat)
+	}
+}
+
+func (s *Server) pushFleetHeartbeat(ctx context.Context, id string, heartbeat Heartbeat) {
+	upstream := s.upstream.Load()
+	err := callKumaEndpoint(ctx, upstream.address, upstream.requestHeaders, upstream.httpClient, id, heartbeat)
+	s.monitorStore.Record(id, "", s.instanceIdentifier, heartbeat, err)
+	if err != nil {
+		s.metrics.upstreamPushFailures.Add(1)
+		slog.Warn("failed to push the fleet status", slog.String("monitor_id", id), slog.String("error", err.Error()))
+		return
+	}
+
+	s.metrics.upstreamPushes.Add(1)
+}
+
+func (s *Server) logFleetChanges(statuses []AgentStatus) {
+	s.fleet.mutex.Lock()
+	defer s.fleet.mutex.Unlock()
+
+	for _, status := range statuses {
+		if status.Silent == s.fleet.silent[status.ID] {
+			continue
+		}
+
+		if status.Silent {
+			slog.Warn("agent went silent",
+				slog.String("agent", status.ID),
+				slog.String("region", status.Region),
+				slog.Time("last_seen_at", status.LastSeenAt))
+		} else {
+			slog.Info("agent is reporting again", slog.String("agent", status.ID), slog.String("region", status.Region))
+		}
+		s.fleet.silent[status.ID] = status.Silent
+	}
+}
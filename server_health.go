@@ -0,0 +1,125 @@
+package roselite
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	// upstreamResultFreshness is how long the outcome of a push is used to tell whether the upstream instance is
+	// reachable. The upstream instance is probed actively if there is no push within this duration.
+	upstreamResultFreshness = time.Minute * 5
+	// upstreamFailureThreshold is the amount of consecutive failed pushes before the upstream instance is considered
+	// unreachable.
+	upstreamFailureThreshold = 3
+	// upstreamProbeTimeout is the timeout for actively probing the upstream instance.
+	upstreamProbeTimeout = time.Second * 5
+	// healthCheckTimeout is the timeout for a single HealthCheck on the readiness endpoint.
+	healthCheckTimeout = time.Second * 10
+)
+
+// HealthCheck reports the health of a single component on the readiness endpoint.
+type HealthCheck struct {
+	// Name identifies the component on the readiness response.
+	Name string
+	// Check returns a short human-readable message about the component, or an error if it is not healthy.
+	Check func(ctx context.Context) (string, error)
+}
+
+type healthCheckResult struct {
+	Ok      bool   `json:"ok"`
+	Message string `json:"message,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+type readinessResponse struct {
+	// Ready is false if any of the checks fails, or if the server is shutting down.
+	Ready  bool                         `json:"ready"`
+	Checks map[string]healthCheckResult `json:"checks"`
+}
+
+func (s *Server) handleLiveness(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(remoteWriteResponse{Ok: true})
+}
+
+func (s *Server) handleReadiness(w http.ResponseWriter, r *http.Request) {
+	checks := append([]HealthCheck{{Name: "upstream", Check: s.checkUpstream}}, s.healthChecks...)
+
+	response := readinessResponse{Ready: !s.shuttingDown.Load(), Checks: make(map[string]healthCheckResult, len(checks))}
+	for _, check := range checks {
+		ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+		message, err := check.Check(ctx)
+		cancel()
+
+		result := healthCheckResult{Ok: err == nil, Message: message}
+		if err != nil {
+			result.Error = err.Error()
+			response.Ready = false
+		}
+		response.Checks[check.Name] = result
+	}
+
+	statusCode := http.StatusOK
+	if !response.Ready {
+		statusCode = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// checkUpstream reports whether the upstream instance is reachable from the recent pushes, or from an active probe
+// if there is no recent push.
+func (s *Server) checkUpstream(ctx context.Context) (string, error) {
+	upstream := s.upstream.Load()
+	if upstream.address == "" {
+		return "", errUpstreamNotConfigured
+	}
+
+	now := time.Now()
+	snapshot := s.upstreamHealth.snapshot()
+	if lastResultAt := snapshot.lastResultAt(); now.Sub(lastResultAt) < upstreamResultFreshness {
+		if snapshot.consecutiveFailures >= upstreamFailureThreshold {
+			// The readiness endpoint is unauthenticated, the errors themselves are on the status API and in Sentry.
+			return "", fmt.Errorf("last %d pushes failed", snapshot.consecutiveFailures)
+		}
+
+		return fmt.Sprintf("last push was %s ago", now.Sub(lastResultAt).Round(time.Second)), nil
+	}
+
+	if err := probeUpstream(ctx, upstream.httpClient, upstream.address); err != nil {
+		return "", err
+	}
+
+	return "upstream responded to an active probe", nil
+}
+
+// probeUpstream performs a request to the upstream address. Any HTTP response means the upstream is reachable.
+func probeUpstream(ctx context.Context, httpClient *http.Client, upstreamKumaAddress string) error {
+	ctx, cancel := context.WithTimeout(ctx, upstreamProbeTimeout)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, upstreamKumaAddress, nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	request.Header.Set("User-Agent", "Roselite/1.0 (compatible; +https://github.com/teknologi-umum/roselite)")
+
+	response, err := httpClient.Do(request)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return errors.New("probing upstream: timed out")
+		}
+		return fmt.Errorf("probing upstream: %w", err)
+	}
+	_ = response.Body.Close()
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package roselite
+
+import (
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+)
+
+const (
+	// regionHeader carries the region of the agent that produced the heartbeat.
+	regionHeader = "X-Roselite-Region"
+	// agentHeader carries the identity of the agent that produced the heartbeat.
+	agentHeader = "X-Roselite-Agent"
+	// hopsHeader carries the comma-separated instance identifiers of every relay the heartbeat passed through,
+	// in the order of the hops.
+	hopsHeader = "X-Roselite-Hops"
+)
+
+// defaultMaxHops is the maximum amount of relays a heartbeat can pass through if ServerOptions.MaxHops is not set.
+const defaultMaxHops = 8
+
+// defaultInstanceIdentifier returns the hostname of the machine, which is used to identify an agent or a relay
+// if no identifier is configured.
+func defaultInstanceIdentifier() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		return "roselite"
+	}
+
+	return hostname
+}
+
+// relayOrigin describes where a heartbeat received by the server comes from.
+type relayOrigin struct {
+	clientAddress string
+	region        string
+	agent         string
+	hops          []string
+}
+
+func relayOriginFromRequest(r *http.Request) relayOrigin {
+	var hops []string
+	for _, value := range r.Header.Values(hopsHeader) {
+		for _, hop := range strings.Split(value, ",") {
+			hop = strings.TrimSpace(hop)
+			if hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+
+	return relayOrigin{
+		clientAddress: clientAddress(r),
+		region:        r.Header.Get(regionHeader),
+		agent:         r.Header.Get(agentHeader),
+		hops:          hops,
+	}
+}
+
+// upstreamRequestHeadersFor returns the headers sent to the upstream instance for a heartbeat of the given origin.
+// The region and agent identity of the origin are kept as is, and this instance is appended to the hops.
+func (s *Server) upstreamRequestHeadersFor(upstream *upstreamClient, origin relayOrigin) map[string]string {
+	headers := make(map[string]string, len(upstream.requestHeaders)+3)
+	for key, value := range upstream.requestHeaders {
+		headers[key] = value
+	}
+
+	if origin.region != "" {
+		headers[regionHeader] = origin.region
+	}
+
+	if origin.agent != "" {
+		headers[agentHeader] = origin.agent
+	}
+
+	headers[hopsHeader] = strings.Join(append(slices.Clone(origin.hops), s.instanceIdentifier), ",")
+	return headers
+}
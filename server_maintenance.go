@@ -0,0 +1,163 @@
+package roselite
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// SilenceOptions configures the /api/silences endpoints, which create ad-hoc silences.
+type SilenceOptions struct {
+	// Tokens are the bearer tokens allowed to list, create and remove silences. Leaving it empty lets any client do so.
+	Tokens []string
+}
+
+type silenceListResponse struct {
+	Silences []Silence `json:"silences"`
+}
+
+// silenceRequest creates a silence. It ends at EndsAt, or after Duration from its start.
+type silenceRequest struct {
+	MaintenanceSelector
+	StartsAt  *time.Time      `json:"starts_at"`
+	EndsAt    *time.Time      `json:"ends_at"`
+	Duration  string          `json:"duration"`
+	Mode      MaintenanceMode `json:"mode"`
+	Comment   string          `json:"comment"`
+	CreatedBy string          `json:"created_by"`
+}
+
+// SetSilences replaces the options of the silences. The silences are kept. A nil value disables the /api/silences
+// endpoints, while the existing silences still apply until they end.
+func (s *Server) SetSilences(options *SilenceOptions) {
+	s.silenceOptions.Store(options)
+}
+
+// applyMaintenance rewrites the heartbeat of a monitor under maintenance. It reports false if the heartbeat must be
+// dropped instead. The tags of a relayed monitor are only known if it is checked by an agent sharing the store.
+func (s *Server) applyMaintenance(id string, heartbeat Heartbeat, now time.Time) (Heartbeat, bool) {
+	period, ok := s.maintenance.Period(id, s.monitorStore.tags(id), now)
+	if !ok {
+		return heartbeat, true
+	}
+
+	if period.Mode == MaintenanceModeSkip {
+		s.metrics.maintenanceSkipped.Add(1)
+		return heartbeat, false
+	}
+
+	s.metrics.maintenanceRewritten.Add(1)
+	return period.apply(heartbeat), true
+}
+
+func (s *Server) handleListSilences(w http.ResponseWriter, r *http.Request) {
+	options := s.silenceOptions.Load()
+	if options == nil {
+		writeErrorResponse(w, http.StatusNotFound, "silences are not enabled")
+		return
+	}
+
+	if !bearerTokenAuthorized(options.Tokens, r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeErrorResponse(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(silenceListResponse{Silences: s.maintenance.Silences()})
+}
+
+func (s *Server) handleCreateSilence(w http.ResponseWriter, r *http.Request) {
+	options := s.silenceOptions.Load()
+	if options == nil {
+		writeErrorResponse(w, http.StatusNotFound, "silences are not enabled")
+		return
+	}
+
+	if !bearerTokenAuthorized(options.Tokens, r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeErrorResponse(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+
+	var request silenceRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPushBodySize)).Decode(&request); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "invalid silence: "+err.Error())
+		return
+	}
+
+	if len(request.Monitors) == 0 && len(request.Tags) == 0 {
+		writeErrorResponse(w, http.StatusBadRequest, "silence must select monitors or tags")
+		return
+	}
+
+	now := time.Now()
+	silence := Silence{
+		MaintenanceSelector: request.MaintenanceSelector,
+		StartsAt:            now,
+		Mode:                request.Mode,
+		Comment:             request.Comment,
+		CreatedBy:           request.CreatedBy,
+	}
+	if request.StartsAt != nil {
+		silence.StartsAt = *request.StartsAt
+	}
+
+	switch {
+	case request.EndsAt != nil && request.Duration != "":
+		writeErrorResponse(w, http.StatusBadRequest, "silence must set either ends_at or duration, not both")
+		return
+	case request.EndsAt != nil:
+		silence.EndsAt = *request.EndsAt
+	case request.Duration != "":
+		duration, err := time.ParseDuration(request.Duration)
+		if err != nil || duration <= 0 {
+			writeErrorResponse(w, http.StatusBadRequest, "invalid duration, expected a positive duration such as \"2h\"")
+			return
+		}
+		silence.EndsAt = silence.StartsAt.Add(duration)
+	default:
+		writeErrorResponse(w, http.StatusBadRequest, "silence must set ends_at or duration")
+		return
+	}
+
+	if !silence.EndsAt.After(silence.StartsAt) || !silence.EndsAt.After(now) {
+		writeErrorResponse(w, http.StatusBadRequest, "silence must end after it starts, and in the future")
+		return
+	}
+
+	silence, ok := s.maintenance.AddSilence(silence)
+	if !ok {
+		writeErrorResponse(w, http.StatusServiceUnavailable, "too many silences are active")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(silence)
+}
+
+// handleRemoveSilence ends a silence before its time.
+func (s *Server) handleRemoveSilence(w http.ResponseWriter, r *http.Request) {
+	options := s.silenceOptions.Load()
+	if options == nil {
+		writeErrorResponse(w, http.StatusNotFound, "silences are not enabled")
+		return
+	}
+
+	if !bearerTokenAuthorized(options.Tokens, r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeErrorResponse(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+
+	if !s.maintenance.RemoveSilence(r.PathValue("id")) {
+		writeErrorResponse(w, http.StatusNotFound, "silence not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(remoteWriteResponse{Ok: true})
+}
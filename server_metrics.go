@@ -0,0 +1,98 @@
+package roselite
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// serverMetrics holds the counters exposed on the metrics endpoint. Every field is safe for concurrent use.
+type serverMetrics struct {
+	upstreamPushes        atomic.Int64
+	upstreamPushFailures  atomic.Int64
+	rateLimitedRequests   atomic.Int64
+	deduplicatedRequests  atomic.Int64
+	forwardingQueueDrops  atomic.Int64
+	forwardingQueueQueued atomic.Int64
+	maintenanceRewritten  atomic.Int64
+	maintenanceSkipped    atomic.Int64
+}
+
+// writePrometheusMetric writes a single metric without any labels in the Prometheus text exposition format.
+func writePrometheusMetric(w io.Writer, name string, metricType string, help string, value int64) {
+	_, _ = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %d\n", name, help, name, metricType, name, value)
+}
+
+// prometheusSample is a single value of a metric, with its label names and values in pairs.
+type prometheusSample struct {
+	labels []string
+	value  float64
+}
+
+var prometheusLabelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// writePrometheusSamples writes a metric with labels in the Prometheus text exposition format.
+func writePrometheusSamples(w io.Writer, name string, metricType string, help string, samples []prometheusSample) {
+	_, _ = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+	for _, sample := range samples {
+		labels := make([]string, 0, len(sample.labels)/2)
+		for i := 0; i+1 < len(sample.labels); i += 2 {
+			labels = append(labels, fmt.Sprintf(`%s="%s"`, sample.labels[i], prometheusLabelValueReplacer.Replace(sample.labels[i+1])))
+		}
+		_, _ = fmt.Fprintf(w, "%s{%s} %s\n", name, strings.Join(labels, ","), strconv.FormatFloat(sample.value, 'g', -1, 64))
+	}
+}
+
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+
+	writePrometheusMetric(w, "roselite_upstream_pushes_total", "counter", "Total number of heartbeats pushed to the upstream instance.", s.metrics.upstreamPushes.Load())
+	writePrometheusMetric(w, "roselite_upstream_push_failures_total", "counter", "Total number of heartbeats that failed to be pushed to the upstream instance.", s.metrics.upstreamPushFailures.Load())
+	writePrometheusMetric(w, "roselite_rate_limited_requests_total", "counter", "Total number of pushes rejected by the rate limiter.", s.metrics.rateLimitedRequests.Load())
+	writePrometheusMetric(w, "roselite_deduplicated_requests_total", "counter", "Total number of pushes collapsed into a previous identical heartbeat.", s.metrics.deduplicatedRequests.Load())
+	writePrometheusMetric(w, "roselite_maintenance_rewritten_requests_total", "counter", "Total number of pushes relayed with the maintenance status.", s.metrics.maintenanceRewritten.Load())
+	writePrometheusMetric(w, "roselite_maintenance_skipped_requests_total", "counter", "Total number of pushes dropped because their monitor is under maintenance.", s.metrics.maintenanceSkipped.Load())
+
+	if s.forwardingQueue != nil {
+		writePrometheusMetric(w, "roselite_forwarding_queue_depth", "gauge", "Number of heartbeats waiting in the forwarding queue.", int64(s.forwardingQueue.depth()))
+		writePrometheusMetric(w, "roselite_forwarding_queue_capacity", "gauge", "Maximum number of heartbeats the forwarding queue can hold.", int64(s.forwardingQueue.capacity()))
+		writePrometheusMetric(w, "roselite_forwarding_queue_enqueued_total", "counter", "Total number of heartbeats accepted into the forwarding queue.", s.metrics.forwardingQueueQueued.Load())
+		writePrometheusMetric(w, "roselite_forwarding_queue_dropped_total", "counter", "Total number of heartbeats dropped because the forwarding queue was full or closed.", s.metrics.forwardingQueueDrops.Load())
+	}
+
+	if s.fleetOptions.Load() != nil {
+		s.writeFleetMetrics(w)
+	}
+}
+
+func (s *Server) writeFleetMetrics(w io.Writer) {
+	agents := s.Agents()
+	var silent int64
+	var up, monitors, schedulerLag, lastSeen, info []prometheusSample
+	for _, agent := range agents {
+		labels := []string{"agent", agent.ID, "region", agent.Region}
+		upValue := 1.0
+		if agent.Silent {
+			silent++
+			upValue = 0
+		}
+
+		up = append(up, prometheusSample{labels: labels, value: upValue})
+		monitors = append(monitors, prometheusSample{labels: labels, value: float64(agent.Monitors)})
+		schedulerLag = append(schedulerLag, prometheusSample{labels: labels, value: agent.SchedulerLagSeconds})
+		lastSeen = append(lastSeen, prometheusSample{labels: labels, value: float64(agent.LastSeenAt.Unix())})
+		info = append(info, prometheusSample{labels: append(labels, "version", agent.Version), value: 1})
+	}
+
+	writePrometheusMetric(w, "roselite_fleet_agents", "gauge", "Number of agents registered with the server.", int64(len(agents)))
+	writePrometheusMetric(w, "roselite_fleet_silent_agents", "gauge", "Number of registered agents that stopped sending their self-heartbeat.", silent)
+	writePrometheusSamples(w, "roselite_agent_up", "gauge", "Whether the agent is sending its self-heartbeat.", up)
+	writePrometheusSamples(w, "roselite_agent_monitors", "gauge", "Number of monitors the agent is checking.", monitors)
+	writePrometheusSamples(w, "roselite_agent_scheduler_lag_seconds", "gauge", "Longest delay of a check of the agent past its scheduled time.", schedulerLag)
+	writePrometheusSamples(w, "roselite_agent_last_seen_timestamp_seconds", "gauge", "Time of the last self-heartbeat of the agent.", lastSeen)
+	writePrometheusSamples(w, "roselite_agent_info", "gauge", "Version of roselite the agent is running.", info)
+}
@@ -0,0 +1,131 @@
+package roselite
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+)
+
+const (
+	// defaultForwardingQueueSize is the capacity of the forwarding queue if ServerOptions.ForwardingQueueSize is not set.
+	defaultForwardingQueueSize = 1024
+	// defaultForwardingWorkers is the amount of workers draining the forwarding queue if
+	// ServerOptions.ForwardingWorkers is not set.
+	defaultForwardingWorkers = 4
+)
+
+// forwardingJob is a heartbeat waiting to be pushed to the upstream instance.
+type forwardingJob struct {
+	// ctx carries the Sentry hub of the originating request, without its cancellation.
+	ctx context.Context
+	// transaction is finished once the job is handled. It is nil if the job is forwarded synchronously.
+	transaction         *sentry.Span
+	id                  string
+	origin              relayOrigin
+	upstreamKumaAddress string
+	upstreamID          string
+	requestHeaders      map[string]string
+	httpClient          *http.Client
+	heartbeat           Heartbeat
+	receivedAt          time.Time
+}
+
+// forwardingQueue is a bounded in-memory queue drained by a fixed pool of workers.
+type forwardingQueue struct {
+	jobs   chan forwardingJob
+	mutex  sync.RWMutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+func newForwardingQueue(size int, workers int, handle func(forwardingJob)) *forwardingQueue {
+	if size <= 0 {
+		size = defaultForwardingQueueSize
+	}
+
+	if workers <= 0 {
+		workers = defaultForwardingWorkers
+	}
+
+	q := &forwardingQueue{jobs: make(chan forwardingJob, size)}
+	for i := 0; i < workers; i++ {
+		q.wg.Add(1)
+		go func() {
+			defer q.wg.Done()
+			for job := range q.jobs {
+				handle(job)
+			}
+		}()
+	}
+
+	return q
+}
+
+// enqueue adds the job into the queue without blocking. It returns false if the queue is full or already closed.
+func (q *forwardingQueue) enqueue(job forwardingJob) bool {
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+
+	if q.closed {
+		return false
+	}
+
+	select {
+	case q.jobs <- job:
+		return true
+	default:
+		return false
+	}
+}
+
+func (q *forwardingQueue) depth() int {
+	return len(q.jobs)
+}
+
+func (q *forwardingQueue) capacity() int {
+	return cap(q.jobs)
+}
+
+// drain stops accepting new jobs and waits until every queued job is handled, or until the context is done.
+func (q *forwardingQueue) drain(ctx context.Context) error {
+	q.mutex.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.jobs)
+	}
+	q.mutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// detachedContext returns a context that outlives ctx, with a clone of its Sentry hub and a new transaction that
+// continues the trace of ctx. Spans are not safe to be shared across goroutines, hence any work that continues on
+// another goroutine must use a detached context. The returned transaction must be finished by the caller.
+func detachedContext(ctx context.Context, name string) (context.Context, *sentry.Span) {
+	hub := sentry.GetHubFromContext(ctx)
+	if hub == nil {
+		hub = sentry.CurrentHub()
+	}
+
+	options := []sentry.SpanOption{sentry.WithOpName("function")}
+	if span := sentry.SpanFromContext(ctx); span != nil {
+		options = append(options, sentry.ContinueFromHeaders(span.ToSentryTrace(), span.ToBaggage()))
+	}
+
+	transaction := sentry.StartTransaction(sentry.SetHubOnContext(context.Background(), hub.Clone()), name, options...)
+	return transaction.Context(), transaction
+}
@@ -0,0 +1,238 @@
+package roselite
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RateLimit configures a token bucket. A zero or negative RequestsPerSecond disables the limit.
+type RateLimit struct {
+	// RequestsPerSecond is the rate in which tokens are added into the bucket.
+	RequestsPerSecond float64
+	// Burst is the maximum amount of tokens the bucket can hold. Defaults to 1 if the value is less than 1.
+	Burst int
+}
+
+func (r RateLimit) enabled() bool {
+	return r.RequestsPerSecond > 0
+}
+
+// rateLimiterSweepThreshold is the amount of buckets a rateLimiter holds before it starts to remove idle buckets.
+const rateLimiterSweepThreshold = 10_000
+
+type tokenBucket struct {
+	tokens      float64
+	lastUpdated time.Time
+}
+
+// rateLimiter keeps a token bucket for every key. It is safe for concurrent use.
+type rateLimiter struct {
+	limit   RateLimit
+	burst   float64
+	mutex   sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func newRateLimiter(limit RateLimit) *rateLimiter {
+	if !limit.enabled() {
+		return nil
+	}
+
+	burst := float64(limit.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &rateLimiter{
+		limit:   limit,
+		burst:   burst,
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// bucket returns the refilled bucket of the given key, creating it if needed. The caller must hold the mutex.
+func (l *rateLimiter) bucket(key string, now time.Time) *tokenBucket {
+	bucket, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= rateLimiterSweepThreshold {
+			l.sweep(now)
+		}
+
+		bucket = &tokenBucket{tokens: l.burst, lastUpdated: now}
+		l.buckets[key] = bucket
+	}
+
+	l.refill(bucket, now)
+	return bucket
+}
+
+// take takes a token from the bucket. If there is no token left, it returns false along with the duration until the
+// next token is available. The caller must hold the mutex.
+func (l *rateLimiter) take(bucket *tokenBucket) (bool, time.Duration) {
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+
+	missing := 1 - bucket.tokens
+	return false, time.Duration(missing / l.limit.RequestsPerSecond * float64(time.Second))
+}
+
+func (l *rateLimiter) refill(bucket *tokenBucket, now time.Time) {
+	elapsed := now.Sub(bucket.lastUpdated)
+	if elapsed <= 0 {
+		return
+	}
+
+	bucket.tokens = math.Min(l.burst, bucket.tokens+elapsed.Seconds()*l.limit.RequestsPerSecond)
+	bucket.lastUpdated = now
+}
+
+// sweep removes every bucket that is already full, as it behaves the same as a newly created bucket.
+// The caller must hold the mutex.
+func (l *rateLimiter) sweep(now time.Time) {
+	for key, bucket := range l.buckets {
+		l.refill(bucket, now)
+		if bucket.tokens >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// retryAfterSeconds converts the duration into the value of the Retry-After header, which is at least 1 second.
+func retryAfterSeconds(duration time.Duration) int64 {
+	seconds := int64(math.Ceil(duration.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	return seconds
+}
+
+type deduplicationEntry struct {
+	fingerprint string
+	pushedAt    time.Time
+}
+
+// heartbeatDeduplicator remembers the last heartbeat that was pushed upstream for every monitor ID, so identical
+// heartbeats that arrive within the window can be collapsed into a single upstream push. It is safe for concurrent use.
+type heartbeatDeduplicator struct {
+	window  time.Duration
+	mutex   sync.Mutex
+	entries map[string]deduplicationEntry
+}
+
+func newHeartbeatDeduplicator(window time.Duration) *heartbeatDeduplicator {
+	if window <= 0 {
+		return nil
+	}
+
+	return &heartbeatDeduplicator{
+		window:  window,
+		entries: make(map[string]deduplicationEntry),
+	}
+}
+
+// deduplicationFingerprint identifies the heartbeats that are collapsed together. The latency differs on every check,
+// so only the status and the message are compared.
+func deduplicationFingerprint(heartbeat Heartbeat) string {
+	return heartbeat.Status.String() + "\x00" + heartbeat.AdditionalMessage.ValueOrZero()
+}
+
+// reserve reports whether the heartbeat must be pushed upstream, which is the case unless an identical heartbeat was
+// pushed, or is being pushed, for the monitor ID within the window. The heartbeat is then marked as pushed, so
+// identical heartbeats arriving at the same time are collapsed too. Call release if the push fails.
+func (d *heartbeatDeduplicator) reserve(id string, heartbeat Heartbeat, now time.Time) bool {
+	if d == nil {
+		return true
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	fingerprint := deduplicationFingerprint(heartbeat)
+	if entry, ok := d.entries[id]; ok && entry.fingerprint == fingerprint && now.Sub(entry.pushedAt) < d.window {
+		return false
+	}
+
+	if len(d.entries) >= rateLimiterSweepThreshold {
+		for key, entry := range d.entries {
+			if now.Sub(entry.pushedAt) >= d.window {
+				delete(d.entries, key)
+			}
+		}
+	}
+
+	d.entries[id] = deduplicationEntry{fingerprint: fingerprint, pushedAt: now}
+	return true
+}
+
+// release forgets the heartbeat reserved at the given time, as it was not pushed upstream. The next identical heartbeat
+// is pushed again.
+func (d *heartbeatDeduplicator) release(id string, heartbeat Heartbeat, reservedAt time.Time) {
+	if d == nil {
+		return
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if entry, ok := d.entries[id]; ok && entry.fingerprint == deduplicationFingerprint(heartbeat) && entry.pushedAt.Equal(reservedAt) {
+		delete(d.entries, id)
+	}
+}
+
+// allowPush checks every configured rate limit for a push request. If any of the limits is exceeded, it returns
+// false along with the duration the client should wait before retrying, and no token is taken from any of the
+// buckets. Otherwise a token is taken from every bucket.
+func (s *Server) allowPush(id string, clientAddress string, now time.Time) (bool, time.Duration) {
+	type limitedBucket struct {
+		limiter *rateLimiter
+		bucket  *tokenBucket
+	}
+
+	var buckets []limitedBucket
+	for _, limit := range []struct {
+		limiter *rateLimiter
+		key     string
+	}{
+		{limiter: s.perClientRateLimiter, key: clientAddress},
+		{limiter: s.perMonitorRateLimiter, key: id},
+		{limiter: s.globalRateLimiter, key: ""},
+	} {
+		if limit.limiter == nil {
+			continue
+		}
+
+		// Every limiter is locked until the tokens are taken, so concurrent pushes can not take the same tokens. They
+		// are always locked in the same order.
+		limit.limiter.mutex.Lock()
+		defer limit.limiter.mutex.Unlock()
+
+		bucket := limit.limiter.bucket(limit.key, now)
+		if bucket.tokens < 1 {
+			return limit.limiter.take(bucket)
+		}
+
+		buckets = append(buckets, limitedBucket{limiter: limit.limiter, bucket: bucket})
+	}
+
+	for _, limited := range buckets {
+		limited.limiter.take(limited.bucket)
+	}
+
+	return true, 0
+}
+
+// clientAddress returns the IP address of the client without the port.
+func clientAddress(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
@@ -0,0 +1,123 @@
+package roselite
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// StatusOptions configures the /api/monitors endpoints and the /status page.
+type StatusOptions struct {
+	// Tokens are the bearer tokens allowed to read the status of the monitors. Leaving it empty lets any client do so.
+	Tokens []string
+}
+
+type monitorListResponse struct {
+	Monitors []MonitorSummary `json:"monitors"`
+}
+
+type monitorDetailResponse struct {
+	Monitor    MonitorSummary    `json:"monitor"`
+	Heartbeats []HeartbeatRecord `json:"heartbeats"`
+}
+
+type errorResponse struct {
+	Ok    bool   `json:"ok"`
+	Error string `json:"error"`
+}
+
+// SetStatus replaces the options of the status API and the status page. A nil value disables them.
+func (s *Server) SetStatus(options *StatusOptions) {
+	s.statusOptions.Store(options)
+}
+
+// publicMonitorID returns the ID a monitor is shown with on the status API and the status page. Monitor IDs are the
+// push tokens of the upstream instance, anyone holding one can push heartbeats for the monitor. Only the aliases,
+// which are meant to be handed out, are shown as is, any other ID is replaced by a digest of it.
+func (s *Server) publicMonitorID(id string) string {
+	if _, ok := s.monitorAliases[id]; ok {
+		return id
+	}
+
+	digest := sha256.Sum256([]byte(id))
+	return "masked-" + hex.EncodeToString(digest[:6])
+}
+
+// publicMonitors returns the monitors of the store with their public ID.
+func (s *Server) publicMonitors() []MonitorSummary {
+	monitors := s.monitorStore.Monitors()
+	for i := range monitors {
+		monitors[i].ID = s.publicMonitorID(monitors[i].ID)
+	}
+
+	return monitors
+}
+
+// authorizeStatus reports whether the status of the monitors can be read by the request, and writes the response
+// otherwise.
+func (s *Server) authorizeStatus(w http.ResponseWriter, r *http.Request) bool {
+	options := s.statusOptions.Load()
+	if options == nil {
+		writeErrorResponse(w, http.StatusNotFound, "status is not enabled")
+		return false
+	}
+
+	if !bearerTokenAuthorized(options.Tokens, r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeErrorResponse(w, http.StatusUnauthorized, "invalid token")
+		return false
+	}
+
+	return true
+}
+
+func (s *Server) handleListMonitors(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeStatus(w, r) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(monitorListResponse{Monitors: s.publicMonitors()})
+}
+
+func (s *Server) handleGetMonitor(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeStatus(w, r) {
+		return
+	}
+
+	var limit int
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(errorResponse{Ok: false, Error: "limit must be a positive integer"})
+			return
+		}
+		limit = parsed
+	}
+
+	var summary MonitorSummary
+	var heartbeats []HeartbeatRecord
+	var ok bool
+	for _, monitor := range s.monitorStore.Monitors() {
+		if s.publicMonitorID(monitor.ID) == r.PathValue("id") {
+			summary, heartbeats, ok = s.monitorStore.Monitor(monitor.ID, limit)
+			break
+		}
+	}
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(errorResponse{Ok: false, Error: "monitor not found"})
+		return
+	}
+
+	summary.ID = s.publicMonitorID(summary.ID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(monitorDetailResponse{Monitor: summary, Heartbeats: heartbeats})
+}
@@ -0,0 +1,141 @@
+package roselite
+
+import (
+	_ "embed"
+	"html/template"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed templates/status.gohtml
+var statusPageTemplateContent string
+
+var statusPageTemplate = template.Must(template.New("status").Parse(statusPageTemplateContent))
+
+// tlsExpiryWarningThreshold is the remaining validity of a TLS certificate in which the status page highlights it.
+const tlsExpiryWarningThreshold = time.Hour * 24 * 14
+
+type statusPage struct {
+	GeneratedAt string
+	Groups      []statusPageGroup
+}
+
+type statusPageGroup struct {
+	Region   string
+	Tag      string
+	Monitors []statusPageMonitor
+}
+
+type statusPageMonitor struct {
+	ID              string
+	Source          MonitorSource
+	MonitorType     string
+	Interval        string
+	Status          string
+	Latency         string
+	SparklinePoints string
+	TLSExpiry       string
+	TLSExpiringSoon bool
+	LastSeen        string
+	LastError       string
+}
+
+// sparklinePoints converts the latency of the heartbeats, the newest first, into the points of an SVG polyline
+// on a 100x20 view box, the oldest on the left.
+func sparklinePoints(heartbeats []HeartbeatRecord) string {
+	if len(heartbeats) < 2 {
+		return ""
+	}
+
+	var maxLatency int64
+	for _, heartbeat := range heartbeats {
+		maxLatency = max(maxLatency, heartbeat.Latency)
+	}
+
+	points := make([]string, 0, len(heartbeats))
+	for i := range heartbeats {
+		heartbeat := heartbeats[len(heartbeats)-1-i]
+		x := float64(i) * 100 / float64(len(heartbeats)-1)
+		y := 19.0
+		if maxLatency > 0 {
+			y = 19 - float64(heartbeat.Latency)*18/float64(maxLatency)
+		}
+		points = append(points, strconv.FormatFloat(x, 'f', 1, 64)+","+strconv.FormatFloat(y, 'f', 1, 64))
+	}
+
+	return strings.Join(points, " ")
+}
+
+func (s *Server) buildStatusPage(now time.Time) statusPage {
+	groups := make(map[[2]string]*statusPageGroup)
+	for _, summary := range s.monitorStore.Monitors() {
+		_, heartbeats, _ := s.monitorStore.Monitor(summary.ID, 0)
+
+		monitor := statusPageMonitor{
+			ID:              s.publicMonitorID(summary.ID),
+			Source:          summary.Source,
+			MonitorType:     summary.MonitorType,
+			Interval:        summary.Interval,
+			Status:          "unknown",
+			SparklinePoints: sparklinePoints(heartbeats),
+			LastError:       summary.LastError,
+		}
+		if last := summary.LastHeartbeat; last != nil {
+			if status := last.Status.String(); status != "" {
+				monitor.Status = status
+			}
+			monitor.Latency = strconv.FormatInt(last.Latency, 10) + "s"
+			monitor.LastSeen = last.ReceivedAt.UTC().Format(time.RFC3339)
+			if last.TLSExpiryDate.Valid {
+				monitor.TLSExpiry = last.TLSExpiryDate.Time.UTC().Format(time.DateOnly)
+				monitor.TLSExpiringSoon = last.TLSExpiryDate.Time.Sub(now) < tlsExpiryWarningThreshold
+			}
+		}
+
+		region := summary.Region
+		if region == "" {
+			region = "Unknown region"
+		}
+
+		tags := summary.Tags
+		if len(tags) == 0 {
+			tags = []string{"Untagged"}
+		}
+
+		for _, tag := range tags {
+			key := [2]string{region, tag}
+			group, ok := groups[key]
+			if !ok {
+				group = &statusPageGroup{Region: region, Tag: tag}
+				groups[key] = group
+			}
+			group.Monitors = append(group.Monitors, monitor)
+		}
+	}
+
+	page := statusPage{GeneratedAt: now.UTC().Format(time.RFC3339)}
+	for _, group := range groups {
+		page.Groups = append(page.Groups, *group)
+	}
+	slices.SortFunc(page.Groups, func(a, b statusPageGroup) int {
+		if c := strings.Compare(a.Region, b.Region); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Tag, b.Tag)
+	})
+
+	return page
+}
+
+func (s *Server) handleStatusPage(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeStatus(w, r) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_ = statusPageTemplate.Execute(w, s.buildStatusPage(time.Now()))
+}
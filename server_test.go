@@ -1,14 +1,20 @@
 package roselite_test
 
 import (
+	"context"
 	"crypto/tls"
+	"encoding/json"
 	"errors"
 	"io"
 	"math/rand/v2"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -396,3 +402,1420 @@ func TestServer_InvalidUpstreamCertificateAuthority(t *testing.T) {
 		}
 	})
 }
+
+func TestServer_MonitorAliases(t *testing.T) {
+	var mutex sync.Mutex
+	var requestedPaths []string
+	kumaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		requestedPaths = append(requestedPaths, r.URL.Path)
+		mutex.Unlock()
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("OK"))
+	}))
+	t.Cleanup(func() {
+		kumaServer.Close()
+	})
+
+	testCases := []struct {
+		name               string
+		strict             bool
+		path               string
+		expectedStatusCode int
+		expectedPath       string
+	}{
+		{
+			name:               "Alias is rewritten",
+			strict:             false,
+			path:               "/api/push/billing-api?status=up&ping=0",
+			expectedStatusCode: http.StatusOK,
+			expectedPath:       "/api/push/Eq15E23yc3",
+		},
+		{
+			name:               "Unknown ID is passed through",
+			strict:             false,
+			path:               "/api/push/12?status=up&ping=0",
+			expectedStatusCode: http.StatusOK,
+			expectedPath:       "/api/push/12",
+		},
+		{
+			name:               "Alias is rewritten on strict mode",
+			strict:             true,
+			path:               "/api/push/billing-api?status=up&ping=0",
+			expectedStatusCode: http.StatusOK,
+			expectedPath:       "/api/push/Eq15E23yc3",
+		},
+		{
+			name:               "Unknown ID is rejected on strict mode",
+			strict:             true,
+			path:               "/api/push/12?status=up&ping=0",
+			expectedStatusCode: http.StatusNotFound,
+			expectedPath:       "",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			mutex.Lock()
+			requestedPaths = nil
+			mutex.Unlock()
+
+			server := roselite.NewServer(roselite.ServerOptions{
+				UpstreamKumaAddress: kumaServer.URL,
+				MonitorAliases: map[string]roselite.MonitorAlias{
+					"billing-api": {UpstreamID: "Eq15E23yc3"},
+				},
+				StrictMonitorAliases: testCase.strict,
+			})
+			httpServer := httptest.NewServer(server.Handler())
+			t.Cleanup(httpServer.Close)
+
+			response, err := http.Get(httpServer.URL + testCase.path)
+			if err != nil {
+				t.Fatalf("failed to perform request: %v", err)
+			}
+			_ = response.Body.Close()
+
+			if response.StatusCode != testCase.expectedStatusCode {
+				t.Errorf("unexpected status code: %d", response.StatusCode)
+			}
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			if testCase.expectedPath == "" {
+				if len(requestedPaths) != 0 {
+					t.Errorf("expected no upstream request, got %v", requestedPaths)
+				}
+				return
+			}
+
+			if len(requestedPaths) != 1 || requestedPaths[0] != testCase.expectedPath {
+				t.Errorf("expected upstream request to %s, got %v", testCase.expectedPath, requestedPaths)
+			}
+		})
+	}
+}
+
+func TestServer_MonitorAliasesOnUpstreamErrors(t *testing.T) {
+	// A closed server refuses the connection, which fails the push with an error holding the push URL.
+	kumaServer := KumaServer()
+	kumaServer.Close()
+
+	server := roselite.NewServer(roselite.ServerOptions{
+		UpstreamKumaAddress: kumaServer.URL,
+		MonitorAliases: map[string]roselite.MonitorAlias{
+			"billing": {UpstreamID: "SECRETTOKEN"},
+		},
+		Status: &roselite.StatusOptions{},
+	})
+	httpServer := httptest.NewServer(server.Handler())
+	t.Cleanup(httpServer.Close)
+
+	response, err := http.Get(httpServer.URL + "/api/push/billing?status=up&ping=0")
+	if err != nil {
+		t.Fatalf("failed to perform request: %v", err)
+	}
+	_ = response.Body.Close()
+
+	if response.StatusCode != http.StatusInternalServerError {
+		t.Errorf("unexpected status code: %d", response.StatusCode)
+	}
+
+	for _, path := range []string{"/api/monitors", "/api/monitors/billing", "/status", "/readyz"} {
+		response, err := http.Get(httpServer.URL + path)
+		if err != nil {
+			t.Fatalf("failed to perform request: %v", err)
+		}
+
+		body, err := io.ReadAll(response.Body)
+		_ = response.Body.Close()
+		if err != nil {
+			t.Fatalf("failed to read response body: %v", err)
+		}
+
+		if strings.Contains(string(body), "SECRETTOKEN") {
+			t.Errorf("expected %s not to expose the push token, got %s", path, body)
+		}
+	}
+}
+
+func TestServer_RateLimit(t *testing.T) {
+	kumaServer := KumaServer()
+	t.Cleanup(func() {
+		kumaServer.Close()
+	})
+
+	server := roselite.NewServer(roselite.ServerOptions{
+		UpstreamKumaAddress: kumaServer.URL,
+		PerMonitorRateLimit: roselite.RateLimit{RequestsPerSecond: 0.001, Burst: 2},
+	})
+	httpServer := httptest.NewServer(server.Handler())
+	t.Cleanup(httpServer.Close)
+
+	for i, expectedStatusCode := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		response, err := http.Get(httpServer.URL + "/api/push/12?status=up&ping=0")
+		if err != nil {
+			t.Fatalf("failed to perform request: %v", err)
+		}
+		_ = response.Body.Close()
+
+		if response.StatusCode != expectedStatusCode {
+			t.Errorf("request %d: unexpected status code: %d", i, response.StatusCode)
+		}
+
+		if expectedStatusCode == http.StatusTooManyRequests && response.Header.Get("Retry-After") == "" {
+			t.Errorf("request %d: expected Retry-After header to be set", i)
+		}
+	}
+
+	// Other monitor IDs must not be affected.
+	response, err := http.Get(httpServer.URL + "/api/push/13?status=up&ping=0")
+	if err != nil {
+		t.Fatalf("failed to perform request: %v", err)
+	}
+	_ = response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("unexpected status code: %d", response.StatusCode)
+	}
+}
+
+func TestServer_RateLimitRejectedPushesAreNotCharged(t *testing.T) {
+	kumaServer := KumaServer()
+	t.Cleanup(kumaServer.Close)
+
+	server := roselite.NewServer(roselite.ServerOptions{
+		UpstreamKumaAddress: kumaServer.URL,
+		PerMonitorRateLimit: roselite.RateLimit{RequestsPerSecond: 0.001, Burst: 1},
+		PerClientRateLimit:  roselite.RateLimit{RequestsPerSecond: 0.001, Burst: 2},
+	})
+	httpServer := httptest.NewServer(server.Handler())
+	t.Cleanup(httpServer.Close)
+
+	// The second push is rejected by the monitor limit, and leaves the token of the client for the third one.
+	for i, push := range []struct {
+		id                 string
+		expectedStatusCode int
+	}{
+		{id: "12", expectedStatusCode: http.StatusOK},
+		{id: "12", expectedStatusCode: http.StatusTooManyRequests},
+		{id: "13", expectedStatusCode: http.StatusOK},
+		{id: "14", expectedStatusCode: http.StatusTooManyRequests},
+	} {
+		response, err := http.Get(httpServer.URL + "/api/push/" + push.id + "?status=up&ping=0")
+		if err != nil {
+			t.Fatalf("failed to perform request: %v", err)
+		}
+		_ = response.Body.Close()
+
+		if response.StatusCode != push.expectedStatusCode {
+			t.Errorf("request %d: unexpected status code: %d", i, response.StatusCode)
+		}
+	}
+}
+
+func TestServer_DeduplicationWindow(t *testing.T) {
+	var upstreamCalls atomic.Int64
+	kumaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Slow enough for concurrent pushes to arrive while the first one is being pushed.
+		time.Sleep(time.Millisecond * 50)
+		upstreamCalls.Add(1)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("OK"))
+	}))
+	t.Cleanup(func() {
+		kumaServer.Close()
+	})
+
+	server := roselite.NewServer(roselite.ServerOptions{
+		UpstreamKumaAddress: kumaServer.URL,
+		DeduplicationWindow: time.Minute,
+	})
+	httpServer := httptest.NewServer(server.Handler())
+	t.Cleanup(httpServer.Close)
+
+	// Heartbeats that only differ in latency are identical.
+	for _, path := range []string{
+		"/api/push/12?status=up&ping=0",
+		"/api/push/12?status=up&ping=7",
+		"/api/push/12?status=down&ping=0&msg=timeout",
+	} {
+		response, err := http.Get(httpServer.URL + path)
+		if err != nil {
+			t.Fatalf("failed to perform request: %v", err)
+		}
+		_ = response.Body.Close()
+
+		if response.StatusCode != http.StatusOK {
+			t.Errorf("unexpected status code: %d", response.StatusCode)
+		}
+	}
+
+	if upstreamCalls.Load() != 2 {
+		t.Errorf("expected 2 upstream calls, got %d", upstreamCalls.Load())
+	}
+
+	// Identical heartbeats arriving at the same time are collapsed too.
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			response, err := http.Get(httpServer.URL + "/api/push/13?status=up&ping=0")
+			if err != nil {
+				t.Errorf("failed to perform request: %v", err)
+				return
+			}
+			_ = response.Body.Close()
+		}()
+	}
+	wg.Wait()
+
+	if upstreamCalls.Load() != 3 {
+		t.Errorf("expected 3 upstream calls, got %d", upstreamCalls.Load())
+	}
+}
+
+func TestServer_DeduplicationWindowAfterFailedPush(t *testing.T) {
+	var upstreamCalls atomic.Int64
+	kumaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if upstreamCalls.Add(1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(kumaServer.Close)
+
+	server := roselite.NewServer(roselite.ServerOptions{
+		UpstreamKumaAddress: kumaServer.URL,
+		DeduplicationWindow: time.Minute,
+	})
+	httpServer := httptest.NewServer(server.Handler())
+	t.Cleanup(httpServer.Close)
+
+	// The first push fails, so the identical heartbeat that follows is pushed again.
+	for i, expectedStatusCode := range []int{http.StatusInternalServerError, http.StatusOK, http.StatusOK} {
+		response, err := http.Get(httpServer.URL + "/api/push/12?status=up&ping=0")
+		if err != nil {
+			t.Fatalf("failed to perform request: %v", err)
+		}
+		_ = response.Body.Close()
+
+		if response.StatusCode != expectedStatusCode {
+			t.Errorf("request %d: unexpected status code: %d", i, response.StatusCode)
+		}
+	}
+
+	if upstreamCalls.Load() != 2 {
+		t.Errorf("expected 2 upstream calls, got %d", upstreamCalls.Load())
+	}
+}
+
+func TestServer_PushJSONBody(t *testing.T) {
+	upstreamQueries := make(chan url.Values, 1)
+	kumaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamQueries <- r.URL.Query()
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("OK"))
+	}))
+	t.Cleanup(func() {
+		kumaServer.Close()
+	})
+
+	server := roselite.NewServer(roselite.ServerOptions{
+		UpstreamKumaAddress: kumaServer.URL,
+	})
+	httpServer := httptest.NewServer(server.Handler())
+	t.Cleanup(httpServer.Close)
+
+	t.Run("Valid body", func(t *testing.T) {
+		response, err := http.Post(httpServer.URL+"/api/push/12", "application/json", strings.NewReader(`{"status":"down","latency":3,"additional_message":"connection refused"}`))
+		if err != nil {
+			t.Fatalf("failed to perform request: %v", err)
+		}
+		_ = response.Body.Close()
+
+		if response.StatusCode != http.StatusOK {
+			t.Errorf("unexpected status code: %d", response.StatusCode)
+		}
+
+		query := <-upstreamQueries
+		if query.Get("status") != "down" || query.Get("ping") != "3" || query.Get("msg") != "connection refused" {
+			t.Errorf("unexpected upstream query: %s", query.Encode())
+		}
+	})
+
+	t.Run("Invalid body", func(t *testing.T) {
+		response, err := http.Post(httpServer.URL+"/api/push/12", "application/json", strings.NewReader(`{"status":`))
+		if err != nil {
+			t.Fatalf("failed to perform request: %v", err)
+		}
+		_ = response.Body.Close()
+
+		if response.StatusCode != http.StatusBadRequest {
+			t.Errorf("unexpected status code: %d", response.StatusCode)
+		}
+	})
+}
+
+func TestServer_BatchPush(t *testing.T) {
+	var upstreamCalls atomic.Int64
+	kumaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamCalls.Add(1)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("OK"))
+	}))
+	t.Cleanup(func() {
+		kumaServer.Close()
+	})
+
+	server := roselite.NewServer(roselite.ServerOptions{
+		UpstreamKumaAddress: kumaServer.URL,
+		MonitorAliases: map[string]roselite.MonitorAlias{
+			"billing-api": {UpstreamID: "Eq15E23yc3"},
+			"payment-api": {UpstreamID: "Pq15E23yc3"},
+		},
+		StrictMonitorAliases: true,
+		BatchConcurrency:     2,
+	})
+	httpServer := httptest.NewServer(server.Handler())
+	t.Cleanup(httpServer.Close)
+
+	testCases := []struct {
+		name        string
+		contentType string
+		body        string
+	}{
+		{
+			name:        "JSON array",
+			contentType: "application/json",
+			body:        `[{"id":"billing-api","heartbeat":{"status":"up","latency":1}},{"id":"unknown","heartbeat":{"status":"up"}},{"id":"payment-api","heartbeat":{"status":"down"}}]`,
+		},
+		{
+			name:        "NDJSON",
+			contentType: "application/x-ndjson",
+			body:        "{\"id\":\"billing-api\",\"heartbeat\":{\"status\":\"up\",\"latency\":1}}\n{\"id\":\"unknown\",\"heartbeat\":{\"status\":\"up\"}}\n\n{\"id\":\"payment-api\",\"heartbeat\":{\"status\":\"down\"}}\n",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			upstreamCalls.Store(0)
+
+			response, err := http.Post(httpServer.URL+"/api/push/batch", testCase.contentType, strings.NewReader(testCase.body))
+			if err != nil {
+				t.Fatalf("failed to perform request: %v", err)
+			}
+			defer func() {
+				_ = response.Body.Close()
+			}()
+
+			if response.StatusCode != http.StatusOK {
+				t.Errorf("unexpected status code: %d", response.StatusCode)
+			}
+
+			var body struct {
+				Ok      bool                       `json:"ok"`
+				Results []roselite.BatchPushResult `json:"results"`
+			}
+			if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+				t.Fatalf("failed to decode response body: %v", err)
+			}
+
+			if body.Ok {
+				t.Errorf("expected ok to be false")
+			}
+
+			if len(body.Results) != 3 {
+				t.Fatalf("expected 3 results, got %d", len(body.Results))
+			}
+
+			expectedStatusCodes := []int{http.StatusOK, http.StatusNotFound, http.StatusOK}
+			for i, result := range body.Results {
+				if result.StatusCode != expectedStatusCodes[i] {
+					t.Errorf("result %d: expected status code %d, got %d", i, expectedStatusCodes[i], result.StatusCode)
+				}
+			}
+
+			if upstreamCalls.Load() != 2 {
+				t.Errorf("expected 2 upstream calls, got %d", upstreamCalls.Load())
+			}
+		})
+	}
+
+	for _, testCase := range []struct {
+		name          string
+		body          string
+		expectedError string
+	}{
+		{
+			name:          "Malformed body",
+			body:          `{"id":"billing-api"}`,
+			expectedError: "decoding json body",
+		},
+		{
+			name:          "Too many items",
+			body:          "[" + strings.Repeat(`{"id":"billing-api"},`, 1000) + `{"id":"billing-api"}]`,
+			expectedError: "batch exceeds 1000 items",
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			response, err := http.Post(httpServer.URL+"/api/push/batch", "application/json", strings.NewReader(testCase.body))
+			if err != nil {
+				t.Fatalf("failed to perform request: %v", err)
+			}
+			defer func() {
+				_ = response.Body.Close()
+			}()
+
+			if response.StatusCode != http.StatusBadRequest {
+				t.Errorf("unexpected status code: %d", response.StatusCode)
+			}
+
+			var body struct {
+				Ok    bool   `json:"ok"`
+				Error string `json:"error"`
+			}
+			if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+				t.Fatalf("failed to decode response body: %v", err)
+			}
+			if body.Ok || !strings.Contains(body.Error, testCase.expectedError) {
+				t.Errorf("expected the error to contain %q, got %+v", testCase.expectedError, body)
+			}
+		})
+	}
+}
+
+func TestServer_AsyncForwarding(t *testing.T) {
+	release := make(chan struct{})
+	var upstreamCalls atomic.Int64
+	kumaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		upstreamCalls.Add(1)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("OK"))
+	}))
+	t.Cleanup(func() {
+		kumaServer.Close()
+	})
+
+	server := roselite.NewServer(roselite.ServerOptions{
+		UpstreamKumaAddress: kumaServer.URL,
+		AsyncForwarding:     true,
+		ForwardingQueueSize: 1,
+		ForwardingWorkers:   1,
+	})
+	httpServer := httptest.NewServer(server.Handler())
+	t.Cleanup(httpServer.Close)
+
+	// The first push is picked up by the only worker, the second one fills the queue, the third one is dropped.
+	// The worker might not have picked up the first push yet, so the second one may be dropped too.
+	var accepted, dropped int
+	for i := 0; i < 3; i++ {
+		response, err := http.Get(httpServer.URL + "/api/push/12?status=up&ping=" + strconv.Itoa(i))
+		if err != nil {
+			t.Fatalf("failed to perform request: %v", err)
+		}
+		_ = response.Body.Close()
+
+		switch response.StatusCode {
+		case http.StatusAccepted:
+			accepted++
+		case http.StatusServiceUnavailable:
+			dropped++
+		default:
+			t.Errorf("unexpected status code: %d", response.StatusCode)
+		}
+
+		if i == 0 {
+			time.Sleep(time.Millisecond * 100)
+		}
+	}
+
+	if accepted < 1 || dropped < 1 {
+		t.Errorf("expected at least one accepted and one dropped push, got %d accepted and %d dropped", accepted, dropped)
+	}
+
+	response, err := http.Get(httpServer.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("failed to perform request: %v", err)
+	}
+	metrics, _ := io.ReadAll(response.Body)
+	_ = response.Body.Close()
+
+	if !strings.Contains(string(metrics), "roselite_forwarding_queue_dropped_total "+strconv.Itoa(dropped)) {
+		t.Errorf("expected dropped metric to be %d, got:\n%s", dropped, string(metrics))
+	}
+
+	close(release)
+	if err := server.Shutdown(t.Context()); err != nil {
+		t.Errorf("failed to shutdown server: %v", err)
+	}
+
+	if upstreamCalls.Load() != int64(accepted) {
+		t.Errorf("expected %d upstream calls after draining the queue, got %d", accepted, upstreamCalls.Load())
+	}
+}
+
+func TestServer_ShutdownDuringLongPoll(t *testing.T) {
+	var upstreamCalls atomic.Int64
+	kumaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Millisecond * 100)
+		upstreamCalls.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(kumaServer.Close)
+
+	serverAddress := "127.0.0.1:" + strconv.Itoa(10_000+rand.IntN(50_000))
+	server := roselite.NewServer(roselite.ServerOptions{
+		ListeningAddress:    serverAddress,
+		UpstreamKumaAddress: kumaServer.URL,
+		AsyncForwarding:     true,
+		MonitorDistribution: &roselite.MonitorDistribution{
+			Monitors: []roselite.DistributedMonitor{distributedMonitor("everywhere", "http://127.0.0.1:1/a", roselite.AgentSelector{})},
+		},
+	})
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			t.Errorf("failed to start server: %v", err)
+		}
+	}()
+
+	waitFor(t, "the server to listen", func() bool {
+		response, err := http.Get("http://" + serverAddress + "/ping")
+		if err != nil {
+			return false
+		}
+		_ = response.Body.Close()
+		return true
+	})
+
+	response, err := http.Get("http://" + serverAddress + "/api/agent/monitors")
+	if err != nil {
+		t.Fatalf("failed to perform request: %v", err)
+	}
+	_ = response.Body.Close()
+	etag := response.Header.Get("ETag")
+
+	// The agent already has the current monitors, the request is held until they change.
+	held := make(chan int, 1)
+	go func() {
+		request, _ := http.NewRequest(http.MethodGet, "http://"+serverAddress+"/api/agent/monitors?wait=1m", nil)
+		request.Header.Set("If-None-Match", etag)
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			held <- 0
+			return
+		}
+		_ = response.Body.Close()
+		held <- response.StatusCode
+	}()
+
+	response, err = http.Get("http://" + serverAddress + "/api/push/12?status=up&ping=0")
+	if err != nil {
+		t.Fatalf("failed to perform request: %v", err)
+	}
+	_ = response.Body.Close()
+	if response.StatusCode != http.StatusAccepted {
+		t.Fatalf("unexpected status code: %d", response.StatusCode)
+	}
+
+	time.Sleep(time.Millisecond * 50)
+
+	ctx, cancel := context.WithTimeout(t.Context(), time.Second*3)
+	defer cancel()
+	started := time.Now()
+	if err := server.Shutdown(ctx); err != nil {
+		t.Errorf("failed to shutdown server: %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("expected the held request not to delay the shutdown, took %s", elapsed)
+	}
+
+	if statusCode := <-held; statusCode != http.StatusNotModified {
+		t.Errorf("expected the held request to end with 304 Not Modified, got %d", statusCode)
+	}
+	if upstreamCalls.Load() != 1 {
+		t.Errorf("expected the queued heartbeat to be forwarded, got %d upstream calls", upstreamCalls.Load())
+	}
+}
+
+func TestServer_MultiHop(t *testing.T) {
+	upstreamHeaders := make(chan http.Header, 1)
+	kumaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamHeaders <- r.Header.Clone()
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("OK"))
+	}))
+	t.Cleanup(func() {
+		kumaServer.Close()
+	})
+
+	centralServer := roselite.NewServer(roselite.ServerOptions{
+		UpstreamKumaAddress: kumaServer.URL,
+		InstanceIdentifier:  "central",
+		MaxHops:             3,
+	})
+	centralHttpServer := httptest.NewServer(centralServer.Handler())
+	t.Cleanup(centralHttpServer.Close)
+
+	edgeServer := roselite.NewServer(roselite.ServerOptions{
+		UpstreamKumaAddress: centralHttpServer.URL,
+		InstanceIdentifier:  "edge",
+	})
+	edgeHttpServer := httptest.NewServer(edgeServer.Handler())
+	t.Cleanup(edgeHttpServer.Close)
+
+	push := func(t *testing.T, hops string) int {
+		request, err := http.NewRequestWithContext(t.Context(), http.MethodGet, edgeHttpServer.URL+"/api/push/12?status=up&ping=0", nil)
+		if err != nil {
+			t.Fatalf("failed to create request: %v", err)
+		}
+		request.Header.Set("X-Roselite-Region", "ap-southeast-1")
+		request.Header.Set("X-Roselite-Agent", "agent-1")
+		if hops != "" {
+			request.Header.Set("X-Roselite-Hops", hops)
+		}
+
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("failed to perform request: %v", err)
+		}
+		_ = response.Body.Close()
+		return response.StatusCode
+	}
+
+	t.Run("Chained relays", func(t *testing.T) {
+		if statusCode := push(t, ""); statusCode != http.StatusOK {
+			t.Fatalf("unexpected status code: %d", statusCode)
+		}
+
+		headers := <-upstreamHeaders
+		if headers.Get("X-Roselite-Region") != "ap-southeast-1" {
+			t.Errorf("expected region to be kept, got %q", headers.Get("X-Roselite-Region"))
+		}
+		if headers.Get("X-Roselite-Agent") != "agent-1" {
+			t.Errorf("expected agent to be kept, got %q", headers.Get("X-Roselite-Agent"))
+		}
+		if headers.Get("X-Roselite-Hops") != "edge,central" {
+			t.Errorf("expected hops to be edge,central, got %q", headers.Get("X-Roselite-Hops"))
+		}
+	})
+
+	t.Run("Loop", func(t *testing.T) {
+		// The edge relay forwards it to the central relay, which rejects it, so the edge relay fails too.
+		if statusCode := push(t, "central"); statusCode != http.StatusInternalServerError {
+			t.Errorf("unexpected status code: %d", statusCode)
+		}
+
+		if statusCode := push(t, "edge"); statusCode != http.StatusLoopDetected {
+			t.Errorf("unexpected status code: %d", statusCode)
+		}
+	})
+
+	t.Run("Max hops", func(t *testing.T) {
+		if statusCode := push(t, "first,second"); statusCode != http.StatusInternalServerError {
+			t.Errorf("unexpected status code: %d", statusCode)
+		}
+	})
+
+	select {
+	case headers := <-upstreamHeaders:
+		t.Errorf("unexpected upstream request with hops %q", headers.Get("X-Roselite-Hops"))
+	default:
+	}
+}
+
+func TestServer_StatusAPI(t *testing.T) {
+	kumaServer := KumaServer()
+	t.Cleanup(func() {
+		kumaServer.Close()
+	})
+
+	monitorStore := roselite.NewMonitorStore(10)
+	monitorStore.RegisterMonitor(roselite.Monitor{ID: "agent-monitor", MonitorType: roselite.MonitorTypeICMP, Interval: time.Minute}, "default", "agent-1")
+
+	server := roselite.NewServer(roselite.ServerOptions{
+		UpstreamKumaAddress: kumaServer.URL,
+		MonitorAliases: map[string]roselite.MonitorAlias{
+			"billing": {UpstreamID: "Eq15E23yc3"},
+		},
+		MonitorStore: monitorStore,
+		Status:       &roselite.StatusOptions{Tokens: []string{"status-token"}},
+	})
+	httpServer := httptest.NewServer(server.Handler())
+	t.Cleanup(httpServer.Close)
+
+	for _, path := range []string{"/api/push/billing?status=up&ping=1", "/api/push/billing?status=down&ping=2&msg=timeout"} {
+		response, err := http.Get(httpServer.URL + path)
+		if err != nil {
+			t.Fatalf("failed to perform request: %v", err)
+		}
+		_ = response.Body.Close()
+	}
+
+	get := func(t *testing.T, path string, token string) *http.Response {
+		t.Helper()
+		request, _ := http.NewRequest(http.MethodGet, httpServer.URL+path, nil)
+		request.Header.Set("Authorization", "Bearer "+token)
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("failed to perform request: %v", err)
+		}
+		t.Cleanup(func() {
+			_ = response.Body.Close()
+		})
+		return response
+	}
+
+	var maskedID string
+	t.Run("List monitors", func(t *testing.T) {
+		response := get(t, "/api/monitors", "status-token")
+
+		var body struct {
+			Monitors []roselite.MonitorSummary `json:"monitors"`
+		}
+		if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+			t.Fatalf("failed to decode response body: %v", err)
+		}
+
+		if len(body.Monitors) != 2 {
+			t.Fatalf("expected 2 monitors, got %d", len(body.Monitors))
+		}
+
+		// The push token of the agent monitor is masked, while the alias is shown as is.
+		ids := []string{body.Monitors[0].ID, body.Monitors[1].ID}
+		slices.Sort(ids)
+		if ids[0] != "billing" || !strings.HasPrefix(ids[1], "masked-") {
+			t.Errorf("unexpected monitors: %+v", body.Monitors)
+		}
+		maskedID = ids[1]
+	})
+
+	t.Run("Get monitor", func(t *testing.T) {
+		response := get(t, "/api/monitors/billing?limit=1", "status-token")
+
+		var body struct {
+			Monitor    roselite.MonitorSummary    `json:"monitor"`
+			Heartbeats []roselite.HeartbeatRecord `json:"heartbeats"`
+		}
+		if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+			t.Fatalf("failed to decode response body: %v", err)
+		}
+
+		if len(body.Heartbeats) != 1 {
+			t.Fatalf("expected 1 heartbeat, got %d", len(body.Heartbeats))
+		}
+		if body.Heartbeats[0].Status != roselite.HeartbeatStatusDown || body.Heartbeats[0].AdditionalMessage.ValueOrZero() != "timeout" {
+			t.Errorf("unexpected heartbeat: %+v", body.Heartbeats[0])
+		}
+	})
+
+	t.Run("Get masked monitor", func(t *testing.T) {
+		response := get(t, "/api/monitors/"+maskedID, "status-token")
+
+		var body struct {
+			Monitor roselite.MonitorSummary `json:"monitor"`
+		}
+		if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+			t.Fatalf("failed to decode response body: %v", err)
+		}
+
+		if body.Monitor.ID != maskedID || body.Monitor.Agent != "agent-1" {
+			t.Errorf("unexpected monitor: %+v", body.Monitor)
+		}
+
+		if response := get(t, "/api/monitors/agent-monitor", "status-token"); response.StatusCode != http.StatusNotFound {
+			t.Errorf("expected the monitor not to be found by its push token, got status code %d", response.StatusCode)
+		}
+	})
+
+	t.Run("Unknown monitor", func(t *testing.T) {
+		if response := get(t, "/api/monitors/unknown", "status-token"); response.StatusCode != http.StatusNotFound {
+			t.Errorf("unexpected status code: %d", response.StatusCode)
+		}
+	})
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		for _, path := range []string{"/api/monitors", "/api/monitors/billing", "/status"} {
+			if response := get(t, path, "wrong-token"); response.StatusCode != http.StatusUnauthorized {
+				t.Errorf("expected %s to be unauthorized, got status code %d", path, response.StatusCode)
+			}
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		server.SetStatus(nil)
+		t.Cleanup(func() {
+			server.SetStatus(&roselite.StatusOptions{Tokens: []string{"status-token"}})
+		})
+
+		for _, path := range []string{"/api/monitors", "/api/monitors/billing", "/status"} {
+			if response := get(t, path, "status-token"); response.StatusCode != http.StatusNotFound {
+				t.Errorf("expected %s to be disabled, got status code %d", path, response.StatusCode)
+			}
+		}
+	})
+}
+
+func TestServer_StatusPage(t *testing.T) {
+	kumaServer := KumaServer()
+	t.Cleanup(func() {
+		kumaServer.Close()
+	})
+
+	monitorStore := roselite.NewMonitorStore(10)
+	monitorStore.RegisterMonitor(roselite.Monitor{
+		ID:          "blog",
+		MonitorType: roselite.MonitorTypeHTTP,
+		Interval:    time.Minute,
+		Tags:        []string{"public", "web"},
+	}, "ap-southeast-1", "agent-1")
+	monitorStore.Record("blog", "", "", roselite.Heartbeat{Status: roselite.HeartbeatStatusUp, Latency: 1}, nil)
+	monitorStore.Record("blog", "", "", roselite.Heartbeat{Status: roselite.HeartbeatStatusDown, Latency: 3}, errors.New("connection refused"))
+
+	server := roselite.NewServer(roselite.ServerOptions{
+		UpstreamKumaAddress: kumaServer.URL,
+		MonitorAliases: map[string]roselite.MonitorAlias{
+			"<script>": {UpstreamID: "Eq15E23yc3"},
+		},
+		MonitorStore: monitorStore,
+		Status:       &roselite.StatusOptions{},
+	})
+	httpServer := httptest.NewServer(server.Handler())
+	t.Cleanup(httpServer.Close)
+
+	response, err := http.Get(httpServer.URL + "/api/push/%3Cscript%3E?status=up&ping=0")
+	if err != nil {
+		t.Fatalf("failed to perform request: %v", err)
+	}
+	_ = response.Body.Close()
+
+	response, err = http.Get(httpServer.URL + "/status")
+	if err != nil {
+		t.Fatalf("failed to perform request: %v", err)
+	}
+	body, _ := io.ReadAll(response.Body)
+	_ = response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("unexpected status code: %d", response.StatusCode)
+	}
+
+	if !strings.HasPrefix(response.Header.Get("Content-Type"), "text/html") {
+		t.Errorf("unexpected content type: %s", response.Header.Get("Content-Type"))
+	}
+
+	for _, expected := range []string{
+		"ap-southeast-1 &middot; public",
+		"ap-southeast-1 &middot; web",
+		"Unknown region &middot; Untagged",
+		"status-down",
+		"connection refused",
+		"<polyline points=",
+		"&lt;script&gt;",
+	} {
+		if !strings.Contains(string(body), expected) {
+			t.Errorf("expected status page to contain %q", expected)
+		}
+	}
+
+	if strings.Contains(string(body), "blog") {
+		t.Errorf("expected the push token of the monitor to be masked")
+	}
+
+	if strings.Contains(string(body), "<script>") {
+		t.Errorf("expected monitor ID to be escaped")
+	}
+}
+
+func TestServer_Readiness(t *testing.T) {
+	kumaServer := KumaServer()
+	t.Cleanup(func() {
+		kumaServer.Close()
+	})
+
+	unreachableKumaServer := KumaServer()
+	unreachableKumaServer.Close()
+
+	testCases := []struct {
+		name                string
+		upstreamKumaAddress string
+		healthChecks        []roselite.HealthCheck
+		pushes              int
+		expectedStatusCode  int
+		expectedFailedCheck string
+		expectedError       string
+	}{
+		{
+			name:                "Ready",
+			upstreamKumaAddress: kumaServer.URL,
+			healthChecks: []roselite.HealthCheck{
+				{Name: "configuration", Check: func(context.Context) (string, error) { return "loaded", nil }},
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:                "Unreachable upstream",
+			upstreamKumaAddress: unreachableKumaServer.URL,
+			expectedStatusCode:  http.StatusServiceUnavailable,
+			expectedFailedCheck: "upstream",
+		},
+		{
+			name:                "Failing pushes",
+			upstreamKumaAddress: unreachableKumaServer.URL,
+			pushes:              3,
+			expectedStatusCode:  http.StatusServiceUnavailable,
+			expectedFailedCheck: "upstream",
+			expectedError:       "last 3 pushes failed",
+		},
+		{
+			name:                "Failing health check",
+			upstreamKumaAddress: kumaServer.URL,
+			healthChecks: []roselite.HealthCheck{
+				{Name: "scheduler", Check: func(context.Context) (string, error) { return "", errors.New("lagging") }},
+			},
+			expectedStatusCode:  http.StatusServiceUnavailable,
+			expectedFailedCheck: "scheduler",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			server := roselite.NewServer(roselite.ServerOptions{
+				UpstreamKumaAddress: testCase.upstreamKumaAddress,
+				HealthChecks:        testCase.healthChecks,
+			})
+			httpServer := httptest.NewServer(server.Handler())
+			t.Cleanup(httpServer.Close)
+
+			response, err := http.Get(httpServer.URL + "/healthz")
+			if err != nil {
+				t.Fatalf("failed to perform request: %v", err)
+			}
+			_ = response.Body.Close()
+
+			if response.StatusCode != http.StatusOK {
+				t.Errorf("unexpected liveness status code: %d", response.StatusCode)
+			}
+
+			for range testCase.pushes {
+				response, err := http.Get(httpServer.URL + "/api/push/12?status=up&ping=0")
+				if err != nil {
+					t.Fatalf("failed to perform request: %v", err)
+				}
+				_ = response.Body.Close()
+			}
+
+			response, err = http.Get(httpServer.URL + "/readyz")
+			if err != nil {
+				t.Fatalf("failed to perform request: %v", err)
+			}
+			defer func() {
+				_ = response.Body.Close()
+			}()
+
+			if response.StatusCode != testCase.expectedStatusCode {
+				t.Errorf("unexpected readiness status code: %d", response.StatusCode)
+			}
+
+			var body struct {
+				Ready  bool `json:"ready"`
+				Checks map[string]struct {
+					Ok    bool   `json:"ok"`
+					Error string `json:"error"`
+				} `json:"checks"`
+			}
+			if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+				t.Fatalf("failed to decode response body: %v", err)
+			}
+
+			if len(body.Checks) != len(testCase.healthChecks)+1 {
+				t.Errorf("expected %d checks, got %d", len(testCase.healthChecks)+1, len(body.Checks))
+			}
+
+			if testCase.expectedFailedCheck != "" {
+				if body.Ready || body.Checks[testCase.expectedFailedCheck].Ok || body.Checks[testCase.expectedFailedCheck].Error == "" {
+					t.Errorf("expected %s check to fail, got %+v", testCase.expectedFailedCheck, body)
+				}
+			}
+
+			if testCase.expectedError != "" && body.Checks[testCase.expectedFailedCheck].Error != testCase.expectedError {
+				t.Errorf("expected %s check to fail with %q, got %q", testCase.expectedFailedCheck, testCase.expectedError, body.Checks[testCase.expectedFailedCheck].Error)
+			}
+		})
+	}
+}
+
+func TestServer_SetUpstream(t *testing.T) {
+	var firstUpstreamCalls, secondUpstreamCalls atomic.Int64
+	firstKumaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		firstUpstreamCalls.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(firstKumaServer.Close)
+
+	secondUpstreamHeaders := make(chan http.Header, 1)
+	secondKumaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secondUpstreamCalls.Add(1)
+		select {
+		case secondUpstreamHeaders <- r.Header.Clone():
+		default:
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(secondKumaServer.Close)
+
+	server := roselite.NewServer(roselite.ServerOptions{
+		UpstreamKumaAddress: firstKumaServer.URL,
+	})
+	httpServer := httptest.NewServer(server.Handler())
+	t.Cleanup(httpServer.Close)
+
+	push := func() {
+		response, err := http.Get(httpServer.URL + "/api/push/12?status=up")
+		if err != nil {
+			t.Fatalf("failed to perform request: %v", err)
+		}
+		_ = response.Body.Close()
+
+		if response.StatusCode != http.StatusOK {
+			t.Errorf("unexpected status code: %d", response.StatusCode)
+		}
+	}
+
+	push()
+	server.SetUpstream(secondKumaServer.URL, map[string]string{"Authorization": "Bearer token"}, nil)
+	push()
+
+	if firstUpstreamCalls.Load() != 1 || secondUpstreamCalls.Load() != 1 {
+		t.Errorf("expected 1 call to each upstream, got %d and %d", firstUpstreamCalls.Load(), secondUpstreamCalls.Load())
+	}
+
+	if headers := <-secondUpstreamHeaders; headers.Get("Authorization") != "Bearer token" {
+		t.Errorf("expected the new request headers to be sent, got %v", headers)
+	}
+}
+
+func TestServer_Fleet(t *testing.T) {
+	var mutex sync.Mutex
+	lastPushes := make(map[string]url.Values)
+	kumaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		lastPushes[strings.TrimPrefix(r.URL.Path, "/api/push/")] = r.URL.Query()
+		mutex.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(kumaServer.Close)
+
+	lastPush := func(id string) url.Values {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return lastPushes[id]
+	}
+
+	server := roselite.NewServer(roselite.ServerOptions{
+		UpstreamKumaAddress: kumaServer.URL,
+		Fleet: &roselite.FleetOptions{
+			Tokens:         []string{"agent-token"},
+			SilenceTimeout: time.Millisecond * 300,
+			PushID:         "fleet",
+			AgentPushIDs:   map[string]string{"edge-2": "edge-2-token"},
+			PushInterval:   time.Millisecond * 20,
+		},
+	})
+	httpServer := httptest.NewServer(server.Handler())
+	t.Cleanup(httpServer.Close)
+	go server.WatchFleet(t.Context())
+
+	agent := roselite.NewAgent(roselite.AgentOptions{
+		Monitors: []roselite.Monitor{
+			{ID: "web", MonitorType: roselite.MonitorTypeHTTP, MonitorTarget: "http://127.0.0.1:1", Interval: time.Hour},
+		},
+		UpstreamKumaAddress: kumaServer.URL,
+		RegionIdentifier:    "jakarta",
+		AgentIdentifier:     "edge-1",
+		Version:             "1.2.3",
+	})
+	go agent.RunRegistration(t.Context(), roselite.RegistrationOptions{
+		ServerAddress: httpServer.URL,
+		Token:         "agent-token",
+		Interval:      time.Millisecond * 20,
+	})
+
+	waitFor(t, "the agent to register", func() bool {
+		return len(server.Agents()) == 1
+	})
+	waitFor(t, "the fleet to be reported up", func() bool {
+		return lastPush("fleet").Get("status") == "up"
+	})
+
+	t.Run("Agent status", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodGet, httpServer.URL+"/api/agents/edge-1", nil)
+		request.Header.Set("Authorization", "Bearer agent-token")
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("failed to perform request: %v", err)
+		}
+		defer func() {
+			_ = response.Body.Close()
+		}()
+
+		var status roselite.AgentStatus
+		if err := json.NewDecoder(response.Body).Decode(&status); err != nil {
+			t.Fatalf("failed to decode response body: %v", err)
+		}
+
+		if status.Region != "jakarta" || status.Version != "1.2.3" || status.Monitors != 1 || status.Silent {
+			t.Errorf("unexpected agent status: %+v", status)
+		}
+	})
+
+	t.Run("Metrics", func(t *testing.T) {
+		response, err := http.Get(httpServer.URL + "/metrics")
+		if err != nil {
+			t.Fatalf("failed to perform request: %v", err)
+		}
+		defer func() {
+			_ = response.Body.Close()
+		}()
+
+		body, _ := io.ReadAll(response.Body)
+		for _, expected := range []string{
+			"roselite_fleet_agents 1\n",
+			`roselite_agent_up{agent="edge-1",region="jakarta"} 1` + "\n",
+			`roselite_agent_monitors{agent="edge-1",region="jakarta"} 1` + "\n",
+			`roselite_agent_info{agent="edge-1",region="jakarta",version="1.2.3"} 1` + "\n",
+		} {
+			if !strings.Contains(string(body), expected) {
+				t.Errorf("expected metrics to contain %q, got:\n%s", expected, body)
+			}
+		}
+	})
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		response, err := http.Post(httpServer.URL+"/api/agents/intruder/heartbeat", "application/json", strings.NewReader(`{"region":"jakarta"}`))
+		if err != nil {
+			t.Fatalf("failed to perform request: %v", err)
+		}
+		_ = response.Body.Close()
+
+		if response.StatusCode != http.StatusUnauthorized {
+			t.Errorf("unexpected status code: %d", response.StatusCode)
+		}
+
+		for _, path := range []string{"/api/agents", "/api/agents/edge-1"} {
+			response, err := http.Get(httpServer.URL + path)
+			if err != nil {
+				t.Fatalf("failed to perform request: %v", err)
+			}
+			_ = response.Body.Close()
+
+			if response.StatusCode != http.StatusUnauthorized {
+				t.Errorf("expected %s to be unauthorized, got status code %d", path, response.StatusCode)
+			}
+		}
+	})
+
+	if push := lastPush("edge-2-token"); push.Get("status") != "down" || !strings.Contains(push.Get("msg"), "not registered") {
+		t.Errorf("expected the unregistered agent to be pushed down, got %v", push)
+	}
+
+	// The agent dies, and stops sending its self-heartbeat.
+	_ = agent.Close()
+
+	waitFor(t, "the fleet to be reported down", func() bool {
+		return lastPush("fleet").Get("status") == "down"
+	})
+	if message := lastPush("fleet").Get("msg"); !strings.Contains(message, "edge-1 (jakarta") {
+		t.Errorf("expected the silent agent to be named, got %q", message)
+	}
+
+	// Once the dead agent is removed, the fleet is up again.
+	request, _ := http.NewRequest(http.MethodDelete, httpServer.URL+"/api/agents/edge-1", nil)
+	request.Header.Set("Authorization", "Bearer agent-token")
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("failed to perform request: %v", err)
+	}
+	_ = response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status code: %d", response.StatusCode)
+	}
+
+	waitFor(t, "the fleet to be reported up again", func() bool {
+		return lastPush("fleet").Get("status") == "up"
+	})
+}
+
+func TestServer_Maintenance(t *testing.T) {
+	var mutex sync.Mutex
+	pushes := make(map[string][]url.Values)
+	kumaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		id := strings.TrimPrefix(r.URL.Path, "/api/push/")
+		pushes[id] = append(pushes[id], r.URL.Query())
+		mutex.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(kumaServer.Close)
+
+	pushesOf := func(id string) []url.Values {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return pushes[id]
+	}
+
+	server := roselite.NewServer(roselite.ServerOptions{
+		UpstreamKumaAddress: kumaServer.URL,
+		Silences:            &roselite.SilenceOptions{Tokens: []string{"silence-token"}},
+		Status:              &roselite.StatusOptions{},
+	})
+	httpServer := httptest.NewServer(server.Handler())
+	t.Cleanup(httpServer.Close)
+
+	createSilence := func(t *testing.T, token string, body string) (*http.Response, roselite.Silence) {
+		t.Helper()
+		request, _ := http.NewRequest(http.MethodPost, httpServer.URL+"/api/silences", strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("Authorization", "Bearer "+token)
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("failed to perform request: %v", err)
+		}
+		defer func() {
+			_ = response.Body.Close()
+		}()
+
+		var silence roselite.Silence
+		_ = json.NewDecoder(response.Body).Decode(&silence)
+		return response, silence
+	}
+
+	push := func(t *testing.T, id string) {
+		t.Helper()
+		response, err := http.Get(httpServer.URL + "/api/push/" + id + "?status=down&msg=connection+refused")
+		if err != nil {
+			t.Fatalf("failed to perform request: %v", err)
+		}
+		_ = response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			t.Fatalf("unexpected status code: %d", response.StatusCode)
+		}
+	}
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		response, _ := createSilence(t, "wrong-token", `{"monitors":["orders-db"],"duration":"1h"}`)
+		if response.StatusCode != http.StatusUnauthorized {
+			t.Errorf("unexpected status code: %d", response.StatusCode)
+		}
+	})
+
+	t.Run("Invalid silence", func(t *testing.T) {
+		for _, body := range []string{
+			`{"duration":"1h"}`,
+			`{"monitors":["orders-db"]}`,
+			`{"monitors":["orders-db"],"duration":"1h","mode":"mute"}`,
+			`{"monitors":["orders-db"],"ends_at":"2000-01-01T00:00:00Z"}`,
+		} {
+			if response, _ := createSilence(t, "silence-token", body); response.StatusCode != http.StatusBadRequest {
+				t.Errorf("expected %s to be rejected, got status code %d", body, response.StatusCode)
+			}
+		}
+	})
+
+	response, silence := createSilence(t, "silence-token", `{"monitors":["orders-db"],"duration":"1h","comment":"migration","created_by":"ops"}`)
+	if response.StatusCode != http.StatusCreated || silence.ID == "" || silence.Mode != roselite.MaintenanceModePush {
+		t.Fatalf("unexpected silence: %d %+v", response.StatusCode, silence)
+	}
+
+	response, _ = createSilence(t, "silence-token", `{"monitors":["cache"],"duration":"1h","mode":"skip"}`)
+	if response.StatusCode != http.StatusCreated {
+		t.Fatalf("unexpected status code: %d", response.StatusCode)
+	}
+
+	t.Run("List silences", func(t *testing.T) {
+		response, err := http.Get(httpServer.URL + "/api/silences")
+		if err != nil {
+			t.Fatalf("failed to perform request: %v", err)
+		}
+		_ = response.Body.Close()
+		if response.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected listing the silences without a token to be unauthorized, got status code %d", response.StatusCode)
+		}
+
+		request, _ := http.NewRequest(http.MethodGet, httpServer.URL+"/api/silences", nil)
+		request.Header.Set("Authorization", "Bearer silence-token")
+		response, err = http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("failed to perform request: %v", err)
+		}
+		defer func() {
+			_ = response.Body.Close()
+		}()
+
+		var body struct {
+			Silences []roselite.Silence `json:"silences"`
+		}
+		if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+			t.Fatalf("failed to decode response body: %v", err)
+		}
+		if len(body.Silences) != 2 {
+			t.Errorf("expected 2 silences, got %+v", body.Silences)
+		}
+	})
+
+	t.Run("Pushed with the maintenance status", func(t *testing.T) {
+		push(t, "orders-db")
+
+		relayed := pushesOf("orders-db")
+		if len(relayed) != 1 {
+			t.Fatalf("expected 1 push, got %v", relayed)
+		}
+		// Uptime Kuma treats anything but up as down.
+		if relayed[0].Get("status") != "up" || !strings.Contains(relayed[0].Get("msg"), "migration") || !strings.Contains(relayed[0].Get("msg"), "check is down: connection refused") {
+			t.Errorf("unexpected push: %v", relayed[0])
+		}
+
+		response, err := http.Get(httpServer.URL + "/api/monitors")
+		if err != nil {
+			t.Fatalf("failed to perform request: %v", err)
+		}
+		defer func() {
+			_ = response.Body.Close()
+		}()
+
+		body, _ := io.ReadAll(response.Body)
+		if !strings.Contains(string(body), `"status":"maintenance"`) {
+			t.Errorf("expected the monitor to be recorded under maintenance, got %s", body)
+		}
+	})
+
+	t.Run("Skipped", func(t *testing.T) {
+		push(t, "cache")
+
+		if relayed := pushesOf("cache"); len(relayed) != 0 {
+			t.Errorf("expected no push, got %v", relayed)
+		}
+	})
+
+	t.Run("Removed silence", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodDelete, httpServer.URL+"/api/silences/"+silence.ID, nil)
+		request.Header.Set("Authorization", "Bearer silence-token")
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("failed to perform request: %v", err)
+		}
+		_ = response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			t.Fatalf("unexpected status code: %d", response.StatusCode)
+		}
+
+		push(t, "orders-db")
+		relayed := pushesOf("orders-db")
+		if len(relayed) != 2 || relayed[1].Get("status") != "down" {
+			t.Errorf("expected the heartbeat to be relayed as is, got %v", relayed)
+		}
+	})
+}
@@ -0,0 +1,55 @@
+package roselite
+
+import (
+	"crypto/tls"
+	"maps"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/teknologi-umum/roselite/internal/sentryhttpclient"
+)
+
+// upstreamClient holds the settings used to push heartbeats to the upstream instance. It is replaced as a whole when
+// the upstream settings change, so a push never sees a mix of the old and the new settings.
+type upstreamClient struct {
+	address        string
+	requestHeaders map[string]string
+	httpClient     *http.Client
+	transport      *http.Transport
+}
+
+func newUpstreamClient(address string, requestHeaders map[string]string, tlsConfig *tls.Config) *upstreamClient {
+	httpClientTransport := &http.Transport{
+		// Adapted from http.DefaultTransport
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		TLSClientConfig:       tlsConfig,
+	}
+
+	headers := make(map[string]string, len(requestHeaders))
+	maps.Copy(headers, requestHeaders)
+
+	return &upstreamClient{
+		address:        address,
+		requestHeaders: headers,
+		httpClient: &http.Client{
+			Transport: sentryhttpclient.NewSentryRoundTripper(httpClientTransport),
+			Timeout:   time.Minute * 3,
+		},
+		transport: httpClientTransport,
+	}
+}
+
+// close releases the idle connections of the previous client once it is replaced. In-flight requests are not affected.
+func (u *upstreamClient) close() {
+	u.transport.CloseIdleConnections()
+}
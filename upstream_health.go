@@ -0,0 +1,59 @@
+package roselite
+
+import (
+	"sync"
+	"time"
+)
+
+// upstreamHealth keeps the outcome of the recent pushes to the upstream instance. It is safe for concurrent use.
+type upstreamHealth struct {
+	mutex               sync.Mutex
+	lastSuccessAt       time.Time
+	lastFailureAt       time.Time
+	lastError           error
+	consecutiveFailures int
+}
+
+func (u *upstreamHealth) record(err error, now time.Time) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	if err != nil {
+		u.consecutiveFailures++
+		u.lastFailureAt = now
+		u.lastError = err
+		return
+	}
+
+	u.consecutiveFailures = 0
+	u.lastSuccessAt = now
+}
+
+// upstreamHealthSnapshot is a copy of upstreamHealth at a point in time.
+type upstreamHealthSnapshot struct {
+	lastSuccessAt       time.Time
+	lastFailureAt       time.Time
+	lastError           error
+	consecutiveFailures int
+}
+
+func (u *upstreamHealth) snapshot() upstreamHealthSnapshot {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	return upstreamHealthSnapshot{
+		lastSuccessAt:       u.lastSuccessAt,
+		lastFailureAt:       u.lastFailureAt,
+		lastError:           u.lastError,
+		consecutiveFailures: u.consecutiveFailures,
+	}
+}
+
+// lastResultAt returns the time of the latest push, whether it succeeded or not.
+func (s upstreamHealthSnapshot) lastResultAt() time.Time {
+	if s.lastFailureAt.After(s.lastSuccessAt) {
+		return s.lastFailureAt
+	}
+
+	return s.lastSuccessAt
+}